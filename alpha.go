@@ -0,0 +1,75 @@
+package qoi
+
+import (
+	"image"
+	"io"
+)
+
+// An AlphaReport describes how an image uses its alpha channel, to help
+// choose between RGB and RGBA.
+type AlphaReport struct {
+	Pixels      int64 // pixels in the image
+	Translucent int64 // pixels that are not fully opaque
+	Transparent int64 // pixels that are fully transparent
+
+	// RGBASize and RGBSize are the sizes of the image encoded with
+	// Channels set to RGBA and RGB.
+	RGBASize, RGBSize int64
+}
+
+// Opaque reports whether every pixel is fully opaque, so that the image can
+// be encoded as RGB without changing it.
+func (r *AlphaReport) Opaque() bool { return r.Translucent == 0 }
+
+// Savings returns the number of bytes encoding the image as RGB saves. It
+// is usually small: alpha costs little when it only changes at edges.
+func (r *AlphaReport) Savings() int64 { return r.RGBASize - r.RGBSize }
+
+// AnalyzeAlpha scans m and reports how it uses its alpha channel. If opts
+// is not nil, its options other than Channels and AutoChannels apply to the
+// sizes reported.
+func AnalyzeAlpha(m image.Image, opts *Encoder) (*AlphaReport, error) {
+	var enc Encoder
+	if opts != nil {
+		enc = *opts
+	}
+	enc.AutoChannels = false
+	enc.Progress, enc.Stats = nil, nil
+
+	b := m.Bounds()
+	r := &AlphaReport{Pixels: int64(b.Dx()) * int64(b.Dy())}
+	var err error
+	enc.Channels = RGBA
+	if r.RGBASize, err = EstimateEncodedSize(m, &enc); err != nil {
+		return nil, err
+	}
+	enc.Channels = RGB
+	if r.RGBSize, err = EstimateEncodedSize(m, &enc); err != nil {
+		return nil, err
+	}
+
+	row := make([]byte, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		readRow(m, y, row)
+		for i := 3; i < len(row); i += 4 {
+			if a := row[i]; a != 0xff {
+				r.Translucent++
+				if a == 0 {
+					r.Transparent++
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// AnalyzeAlphaStream decodes the QOI image in r and reports how it uses its
+// alpha channel, with the sizes Encode would produce for each channel
+// count.
+func AnalyzeAlphaStream(r io.Reader) (*AlphaReport, error) {
+	m, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeAlpha(m, nil)
+}
@@ -0,0 +1,70 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAnalyzeAlpha(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i * 13)
+	}
+	for y := range 10 {
+		for x := range 20 {
+			c := m.NRGBAAt(x, y)
+			c.A = 0xff
+			switch {
+			case x == 0:
+				c.A = 0
+			case x == 1 && y < 5:
+				c.A = 0x80
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+
+	r, err := AnalyzeAlpha(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AlphaReport{
+		Pixels:      200,
+		Translucent: 15,
+		Transparent: 10,
+		RGBASize:    int64(len(encodeBytes(t, m, nil))),
+		RGBSize:     int64(len(encodeBytes(t, m, &Encoder{Channels: RGB}))),
+	}
+	if *r != want {
+		t.Errorf("got %+v, want %+v", *r, want)
+	}
+	if r.Opaque() {
+		t.Error("Opaque reported true")
+	}
+	if r.Savings() != want.RGBASize-want.RGBSize {
+		t.Errorf("got savings %d", r.Savings())
+	}
+
+	r2, err := AnalyzeAlphaStream(bytes.NewReader(encodeBytes(t, m, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *r2 != *r {
+		t.Errorf("AnalyzeAlphaStream: got %+v, want %+v", *r2, *r)
+	}
+
+	opaque := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 0xff
+	}
+	opaque.Set(1, 1, color.RGBA{1, 2, 3, 0xff})
+	r, err = AnalyzeAlpha(opaque, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Opaque() || r.Savings() < 0 {
+		t.Errorf("opaque image: got %+v", *r)
+	}
+}
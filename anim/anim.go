@@ -0,0 +1,215 @@
+// Package anim implements a container for sequences of QOI images.
+//
+// A container starts with the 4-byte magic "qoia" followed by the frame count
+// as a 4-byte big-endian integer. Each frame is a 4-byte big-endian length n
+// followed by n bytes holding a complete QOI stream. A length of zero marks a
+// frame that repeats the previous one.
+//
+// Containers with timing start with the magic "qoid" instead. The frame count
+// is followed by the loop count as a 4-byte big-endian signed integer, and
+// each frame's length is preceded by its delay as a 4-byte big-endian
+// integer.
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"github.com/clfs/qoi"
+)
+
+const (
+	magic      = "qoia"
+	timedMagic = "qoid"
+)
+
+// Animation is a sequence of frames, with the same timing fields as
+// gif.GIF.
+type Animation struct {
+	Image []image.Image
+
+	// Delay holds the successive delay times, one per frame, in 100ths of
+	// a second. It may be nil when encoding, in which case every delay is
+	// zero.
+	Delay []int
+
+	// LoopCount controls the number of times an animation will be
+	// restarted during display. A LoopCount of 0 means to loop forever, a
+	// LoopCount of -1 means to show each frame only once, and otherwise
+	// the animation is looped LoopCount+1 times.
+	LoopCount int
+}
+
+// Encoder configures encoding animations.
+type Encoder struct {
+	// Encoder configures how each frame is encoded.
+	qoi.Encoder
+
+	// DedupeFrames stores a frame that is pixel-identical to the one before
+	// it as a zero-length repeat marker.
+	DedupeFrames bool
+}
+
+// A FormatError reports that the input is not a valid animation.
+type FormatError string
+
+func (e FormatError) Error() string { return "anim: invalid format: " + string(e) }
+
+// EncodeAll writes the frames of a to w.
+func EncodeAll(w io.Writer, a *Animation) error {
+	var e Encoder
+	return e.EncodeAll(w, a)
+}
+
+// EncodeAll writes the frames of a to w.
+func (enc *Encoder) EncodeAll(w io.Writer, a *Animation) error {
+	if len(a.Image) == 0 {
+		return errors.New("anim: no frames")
+	}
+	if uint64(len(a.Image)) >= 1<<32 {
+		return errors.New("anim: too many frames")
+	}
+	if a.Delay != nil && len(a.Delay) != len(a.Image) {
+		return errors.New("anim: mismatched image and delay lengths")
+	}
+	for _, d := range a.Delay {
+		if d < 0 || uint64(d) >= 1<<32 {
+			return errors.New("anim: invalid delay")
+		}
+	}
+	if a.LoopCount < -1 || a.LoopCount > math.MaxInt32 {
+		return errors.New("anim: invalid loop count")
+	}
+
+	// Containers without timing keep the original layout, so that readers
+	// that predate timing can still read them.
+	timed := a.Delay != nil || a.LoopCount != 0
+	hdr := make([]byte, 0, 12)
+	if timed {
+		hdr = append(hdr, timedMagic...)
+		hdr = binary.BigEndian.AppendUint32(hdr, uint32(len(a.Image)))
+		hdr = binary.BigEndian.AppendUint32(hdr, uint32(int32(a.LoopCount)))
+	} else {
+		hdr = append(hdr, magic...)
+		hdr = binary.BigEndian.AppendUint32(hdr, uint32(len(a.Image)))
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	// Identical encodings decode to identical pixels, so comparing the
+	// encoded bytes is enough to detect repeated frames.
+	var cur, prev bytes.Buffer
+	for i, m := range a.Image {
+		cur.Reset()
+		if err := enc.Encoder.Encode(&cur, m); err != nil {
+			return err
+		}
+		frame := cur.Bytes()
+		if enc.DedupeFrames && i > 0 && bytes.Equal(frame, prev.Bytes()) {
+			frame = nil
+		}
+		if uint64(len(frame)) >= 1<<32 {
+			return errors.New("anim: frame too large")
+		}
+		hdr = hdr[:0]
+		if timed {
+			var d int
+			if a.Delay != nil {
+				d = a.Delay[i]
+			}
+			hdr = binary.BigEndian.AppendUint32(hdr, uint32(d))
+		}
+		hdr = binary.BigEndian.AppendUint32(hdr, uint32(len(frame)))
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		if frame != nil {
+			cur, prev = prev, cur
+		}
+	}
+	return nil
+}
+
+// DecodeAll reads an animation from r. Repeated frames share the image of
+// the frame they repeat. Delay is always set, and is all zeros for
+// containers without timing.
+func DecodeAll(r io.Reader) (*Animation, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	var timed bool
+	switch string(hdr[:4]) {
+	case magic:
+	case timedMagic:
+		timed = true
+	default:
+		return nil, FormatError("not an animation")
+	}
+	n := binary.BigEndian.Uint32(hdr[4:])
+	if n == 0 {
+		return nil, FormatError("no frames")
+	}
+
+	a := &Animation{}
+	if timed {
+		if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+			return nil, noEOF(err)
+		}
+		a.LoopCount = int(int32(binary.BigEndian.Uint32(hdr[:4])))
+		if a.LoopCount < -1 {
+			return nil, FormatError("bad loop count")
+		}
+	}
+	for i := uint32(0); i < n; i++ {
+		if timed {
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				return nil, noEOF(err)
+			}
+			a.Delay = append(a.Delay, int(binary.BigEndian.Uint32(hdr[:4])))
+			copy(hdr[:4], hdr[4:])
+		} else {
+			if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+				return nil, noEOF(err)
+			}
+			a.Delay = append(a.Delay, 0)
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		if size == 0 {
+			if i == 0 {
+				return nil, FormatError("first frame repeats nothing")
+			}
+			a.Image = append(a.Image, a.Image[i-1])
+			continue
+		}
+		lr := &io.LimitedReader{R: r, N: size}
+		m, err := qoi.Decode(lr)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		// Skip whatever the decoder left unread, such as the end marker.
+		if _, err := io.Copy(io.Discard, lr); err != nil {
+			return nil, err
+		}
+		if lr.N != 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		a.Image = append(a.Image, m)
+	}
+	return a, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package anim
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"slices"
+	"testing"
+)
+
+func frame(c uint8) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 24, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 24; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 10), uint8(y * 15), c, 0xff})
+		}
+	}
+	return m
+}
+
+func TestDedupeFrames(t *testing.T) {
+	a, b := frame(1), frame(2)
+	anim := &Animation{Image: []image.Image{a, a, b, b, b, a}}
+
+	var plain, deduped bytes.Buffer
+	if err := EncodeAll(&plain, anim); err != nil {
+		t.Fatal(err)
+	}
+	enc := Encoder{DedupeFrames: true}
+	if err := enc.EncodeAll(&deduped, anim); err != nil {
+		t.Fatal(err)
+	}
+	if deduped.Len() >= plain.Len() {
+		t.Errorf("deduped size %d, want less than %d", deduped.Len(), plain.Len())
+	}
+
+	for _, buf := range []*bytes.Buffer{&plain, &deduped} {
+		got, err := DecodeAll(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Image) != len(anim.Image) {
+			t.Fatalf("got %d frames, want %d", len(got.Image), len(anim.Image))
+		}
+		for i, m := range got.Image {
+			if !bytes.Equal(m.(*image.NRGBA).Pix, anim.Image[i].(*image.NRGBA).Pix) {
+				t.Errorf("frame %d differs", i)
+			}
+		}
+	}
+}
+
+func TestTiming(t *testing.T) {
+	a, b := frame(1), frame(2)
+	for _, anim := range []*Animation{
+		{Image: []image.Image{a, b, a}, Delay: []int{10, 25, 0}, LoopCount: 3},
+		{Image: []image.Image{a, b}, LoopCount: -1},
+		{Image: []image.Image{a}, Delay: []int{1<<32 - 1}},
+	} {
+		var buf bytes.Buffer
+		if err := EncodeAll(&buf, anim); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String()[:4]; got != timedMagic {
+			t.Errorf("magic %q, want %q", got, timedMagic)
+		}
+		got, err := DecodeAll(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := anim.Delay
+		if want == nil {
+			want = make([]int, len(anim.Image))
+		}
+		if !slices.Equal(got.Delay, want) || got.LoopCount != anim.LoopCount {
+			t.Errorf("got delays %v loop count %d, want %v %d", got.Delay, got.LoopCount, want, anim.LoopCount)
+		}
+		if len(got.Image) != len(anim.Image) {
+			t.Errorf("got %d frames, want %d", len(got.Image), len(anim.Image))
+		}
+	}
+}
+
+func TestTimingUntimed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeAll(&buf, &Animation{Image: []image.Image{frame(1), frame(2)}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String()[:4]; got != magic {
+		t.Errorf("magic %q, want %q", got, magic)
+	}
+	got, err := DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.Delay, []int{0, 0}) || got.LoopCount != 0 {
+		t.Errorf("got delays %v loop count %d", got.Delay, got.LoopCount)
+	}
+}
+
+func TestTimingInvalid(t *testing.T) {
+	m := frame(1)
+	for _, anim := range []*Animation{
+		{Image: []image.Image{m, m}, Delay: []int{1}},
+		{Image: []image.Image{m}, Delay: []int{-1}},
+		{Image: []image.Image{m}, LoopCount: -2},
+	} {
+		if err := EncodeAll(io.Discard, anim); err == nil {
+			t.Errorf("EncodeAll(%d frames, delays %v, loop count %d) succeeded", len(anim.Image), anim.Delay, anim.LoopCount)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package qoi
+
+import (
+	"context"
+	"image"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// An EncodeJob is an image for EncodeBatch to encode and where to write it.
+type EncodeJob struct {
+	W     io.Writer
+	Image image.Image
+}
+
+// EncodeBatch encodes each job's image to its writer, running up to
+// concurrency encodes at a time; zero or less means runtime.GOMAXPROCS(0).
+// If opts is nil, the defaults of Encode are used, and if opts.BufferPool is
+// nil, the encodes share buffers through an internal pool.
+//
+// EncodeBatch stops starting new jobs at the first failure or when ctx is
+// done, and cancels the jobs in progress. The error is then a *BatchError
+// for the first job that failed, or ctx.Err().
+func EncodeBatch(ctx context.Context, jobs []EncodeJob, concurrency int, opts *Encoder) error {
+	enc := Encoder{}
+	if opts != nil {
+		enc = *opts
+	}
+	if enc.BufferPool == nil {
+		enc.BufferPool = &syncEncoderPool{}
+	}
+	return runBatch(ctx, len(jobs), concurrency, func(ctx context.Context, i int) error {
+		_, err := enc.encode(ctx, jobs[i].W, nil, jobs[i].Image)
+		return err
+	})
+}
+
+// DecodeBatch decodes the image in each reader, running up to concurrency
+// decodes at a time; zero or less means runtime.GOMAXPROCS(0). It returns the
+// images in the order of the readers. If opts is nil, the defaults of Decode
+// are used, and if opts.BufferPool is nil, the decodes share buffers through
+// an internal pool.
+//
+// DecodeBatch stops as EncodeBatch does. On error, the images that were
+// decoded are still returned, and the others are nil.
+func DecodeBatch(ctx context.Context, readers []io.Reader, concurrency int, opts *DecodeOptions) ([]image.Image, error) {
+	o := DecodeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.BufferPool == nil {
+		o.BufferPool = &syncDecoderPool{}
+	}
+	images := make([]image.Image, len(readers))
+	err := runBatch(ctx, len(readers), concurrency, func(ctx context.Context, i int) error {
+		m, err := o.DecodeContext(ctx, readers[i])
+		if err == nil {
+			images[i] = m
+		}
+		return err
+	})
+	return images, err
+}
+
+// A TranscodeJob is a conversion for TranscodeBatch to run, with the
+// arguments of Transcode.
+type TranscodeJob struct {
+	Dst      io.Writer
+	Src      io.Reader
+	From, To Format
+}
+
+// TranscodeBatch runs Transcode for each job, running up to concurrency
+// conversions at a time; zero or less means runtime.GOMAXPROCS(0). If opts
+// is nil, the defaults of Transcode are used.
+//
+// Unlike EncodeBatch, TranscodeBatch does not stop at a failed job, since
+// conversions of separate files do not depend on each other. It returns the
+// error of each job, nil if it succeeded. Jobs that were not started because
+// ctx was done get ctx.Err().
+func TranscodeBatch(ctx context.Context, jobs []TranscodeJob, concurrency int, opts *TranscodeOptions) []error {
+	errs := make([]error, len(jobs))
+	ran := make([]bool, len(jobs))
+	runBatch(ctx, len(jobs), concurrency, func(ctx context.Context, i int) error {
+		ran[i] = true
+		if errs[i] = ctx.Err(); errs[i] == nil {
+			j := &jobs[i]
+			errs[i] = Transcode(j.Dst, j.Src, j.From, j.To, opts)
+		}
+		return nil
+	})
+	for i := range errs {
+		if !ran[i] {
+			errs[i] = ctx.Err()
+		}
+	}
+	return errs
+}
+
+// runBatch calls fn for each of n items on up to concurrency goroutines.
+func runBatch(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	items := make(chan int)
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				if err := fn(ctx, i); err != nil && ctx.Err() == nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = &BatchError{Index: i, Err: err}
+					}
+					mu.Unlock()
+					cancel()
+				}
+			}
+		}()
+	}
+feed:
+	for i := range n {
+		select {
+		case items <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// syncEncoderPool and syncDecoderPool are buffer pools that are safe for
+// concurrent use.
+type (
+	syncEncoderPool struct{ p sync.Pool }
+	syncDecoderPool struct{ p sync.Pool }
+)
+
+func (p *syncEncoderPool) Get() *EncoderBuffer {
+	b, _ := p.p.Get().(*EncoderBuffer)
+	return b
+}
+
+func (p *syncEncoderPool) Put(b *EncoderBuffer) { p.p.Put(b) }
+
+func (p *syncDecoderPool) Get() *DecoderBuffer {
+	b, _ := p.p.Get().(*DecoderBuffer)
+	return b
+}
+
+func (p *syncDecoderPool) Put(b *DecoderBuffer) { p.p.Put(b) }
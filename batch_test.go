@@ -0,0 +1,91 @@
+package qoi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestEncodeDecodeBatch(t *testing.T) {
+	var images []image.Image
+	for i := range 20 {
+		images = append(images, testImage(10+i, 30-i))
+	}
+	bufs := make([]bytes.Buffer, len(images))
+	jobs := make([]EncodeJob, len(images))
+	for i, m := range images {
+		jobs[i] = EncodeJob{W: &bufs[i], Image: m}
+	}
+	if err := EncodeBatch(context.Background(), jobs, 3, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	readers := make([]io.Reader, len(images))
+	for i := range bufs {
+		if want := encodeBytes(t, images[i], nil); !bytes.Equal(bufs[i].Bytes(), want) {
+			t.Errorf("image %d encoded differently", i)
+		}
+		readers[i] = bytes.NewReader(bufs[i].Bytes())
+	}
+	got, err := DecodeBatch(context.Background(), readers, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range got {
+		samePixels(t, m, images[i])
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	good := encodeBytes(t, testImage(8, 8), nil)
+	readers := []io.Reader{
+		bytes.NewReader(good),
+		bytes.NewReader(good[:20]),
+		bytes.NewReader(good),
+	}
+	_, err := DecodeBatch(context.Background(), readers, 1, nil)
+	var be *BatchError
+	if !errors.As(err, &be) || be.Index != 1 || !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, want a *BatchError for item 1 matching ErrTruncated", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = EncodeBatch(ctx, []EncodeJob{{io.Discard, testImage(8, 8)}}, 1, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled batch: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTranscodeBatch(t *testing.T) {
+	m := testImage(8, 8)
+	good := encodeBytes(t, m, nil)
+	srcs := [][]byte{good, good[:20], good}
+	bufs := make([]bytes.Buffer, len(srcs))
+	jobs := make([]TranscodeJob, len(srcs))
+	for i, src := range srcs {
+		jobs[i] = TranscodeJob{Dst: &bufs[i], Src: bytes.NewReader(src), From: FormatQOI, To: FormatPNG}
+	}
+	errs := TranscodeBatch(context.Background(), jobs, 2, nil)
+	if errs[0] != nil || errs[2] != nil || !errors.Is(errs[1], ErrTruncated) {
+		t.Fatalf("got errors %v, want only item 1 to fail with ErrTruncated", errs)
+	}
+	for _, i := range []int{0, 2} {
+		got, err := png.Decode(&bufs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, got, m)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	jobs = []TranscodeJob{{Dst: io.Discard, Src: bytes.NewReader(good), From: FormatQOI, To: FormatPNG}}
+	if errs := TranscodeBatch(ctx, jobs, 1, nil); !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("canceled batch: got %v, want %v", errs[0], context.Canceled)
+	}
+}
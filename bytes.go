@@ -0,0 +1,100 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+)
+
+// DecodeBytes decodes the QOI image in data, returning an *image.NRGBA as
+// Decode does. Reading chunks straight from the slice avoids the overhead of
+// an io.Reader, which matters when the whole file is already in memory.
+// Data following the image's end marker is ignored.
+func DecodeBytes(data []byte) (image.Image, error) {
+	return Limits{}.DecodeBytes(data)
+}
+
+// DecodeBytes is like the package-level DecodeBytes.
+func (l Limits) DecodeBytes(data []byte) (image.Image, error) {
+	d := newDecoder(bytes.NewReader(data))
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := l.check(d.hdr); err != nil {
+		return nil, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, d.hdr.Width, d.hdr.Height))
+	if err := d.decodeBytes(data, img.Pix); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// decodeBytes decodes the chunks of the image in data, whose header has
+// been read, into pix, then checks for the end marker. Errors are reported
+// as decoding from a reader would report them.
+func (d *decoder) decodeBytes(data, pix []byte) error {
+	i := headerLen
+	px := startPixel
+	var index [64]color.NRGBA
+
+	for o := 0; o < len(pix); {
+		if i >= len(data) {
+			return io.ErrUnexpectedEOF
+		}
+		tag := data[i]
+		switch {
+		case tag == opRGB:
+			if len(data)-i < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			s := data[i+1 : i+4 : i+4]
+			px.R, px.G, px.B = s[0], s[1], s[2]
+			i += 4
+		case tag == opRGBA:
+			if len(data)-i < 5 {
+				return io.ErrUnexpectedEOF
+			}
+			s := data[i+1 : i+5 : i+5]
+			px = color.NRGBA{s[0], s[1], s[2], s[3]}
+			i += 5
+		case tag&opMask == opIndex:
+			px = index[tag]
+			i++
+		case tag&opMask == opDiff:
+			px.R += (tag>>4)&0x03 - 2
+			px.G += (tag>>2)&0x03 - 2
+			px.B += tag&0x03 - 2
+			i++
+		case tag&opMask == opLuma:
+			if len(data)-i < 2 {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[i+1]
+			dg := tag&0x3f - 32
+			px.R += dg + b>>4 - 8
+			px.G += dg
+			px.B += dg + b&0x0f - 8
+			i += 2
+		default:
+			// A run may continue past the last pixel, which Decode
+			// tolerates too.
+			n := min(int(tag&0x3f)+1, (len(pix)-o)/4)
+			index[hash(px)] = px
+			fillPixel(pix[o:o+4*n], px)
+			o += 4 * n
+			i++
+			continue
+		}
+		index[hash(px)] = px
+		p := pix[o : o+4 : o+4]
+		p[0], p[1], p[2], p[3] = px.R, px.G, px.B, px.A
+		o += 4
+	}
+
+	if len(data)-i < endLen {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
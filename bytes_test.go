@@ -0,0 +1,69 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	runs := image.NewNRGBA(image.Rect(0, 0, 100, 3))
+	for x := range 100 {
+		runs.SetNRGBA(x, 1, color.NRGBA{0, 0, 0, 0xff})
+	}
+	for _, m := range []image.Image{testImage(37, 23), runs} {
+		for _, enc := range []*Encoder{nil, {Channels: RGB}} {
+			data := encodeBytes(t, m, enc)
+			want, err := Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeBytes(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			samePixels(t, got, want)
+
+			// Truncated streams fail the same way.
+			for n := range len(data) {
+				_, want := Decode(bytes.NewReader(data[:n]))
+				_, got := DecodeBytes(data[:n])
+				if got == nil || got.Error() != want.Error() {
+					t.Fatalf("%d bytes: got %v, want %v", n, got, want)
+				}
+			}
+		}
+	}
+}
+
+func FuzzDecodeBytes(f *testing.F) {
+	f.Add(encodeBytes(f, testImage(5, 4), nil))
+	f.Add([]byte("qoif\x00\x00\x00\x02\x00\x00\x00\x02\x04\x00\xfd\x00\x00\x00\x00\x00\x00\x00\x01"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if h, err := DecodeHeader(bytes.NewReader(data)); err == nil && h.Width*h.Height > 1<<20 {
+			return
+		}
+		want, wantErr := Decode(bytes.NewReader(data))
+		got, err := DecodeBytes(data)
+		if (err == nil) != (wantErr == nil) || err != nil && err.Error() != wantErr.Error() {
+			t.Fatalf("got error %v, want %v", err, wantErr)
+		}
+		if err == nil {
+			samePixels(t, got, want)
+		}
+	})
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	m := testImage(512, 512)
+	data := encodeBytes(b, m, nil)
+	b.SetBytes(int64(len(m.Pix)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// A "csum" extension block holds a CRC-32 (IEEE) of the image's pixels as a
+// decoder reproduces them, in row order, with as many bytes per pixel as the
+// header's channel count: R, G, B and, for RGBA images, A. The checksum is
+// stored big-endian.
+
+// updateCRC returns crc updated with the NRGBA pixels in pix, as stored in
+// an image with c channels. buf is scratch space for packing RGB pixels.
+func updateCRC(crc uint32, pix []byte, c Channels, buf *[]byte) uint32 {
+	if c == RGBA {
+		return crc32.Update(crc, crc32.IEEETable, pix)
+	}
+	const chunk = 4 << 10
+	if *buf == nil {
+		*buf = make([]byte, 3*chunk/4)
+	}
+	for len(pix) > 0 {
+		n := min(len(pix), chunk)
+		packRGB(*buf, pix[:n])
+		crc = crc32.Update(crc, crc32.IEEETable, (*buf)[:3*n/4])
+		pix = pix[n:]
+	}
+	return crc
+}
+
+// writeChecksum appends the checksum block to e.buf.
+func (e *encoder) writeChecksum() {
+	e.buf = appendBlock(e.buf, "csum", string(binary.BigEndian.AppendUint32(nil, e.crc)))
+}
+
+// verifyChecksum reads the extension blocks after the end marker into d.md,
+// and reports whether they hold a checksum that matches the decoded pixels.
+func (d *decoder) verifyChecksum() error {
+	md, err := d.readMetadata()
+	if err != nil {
+		return err
+	}
+	d.md = md
+	if !d.hasSum {
+		return &kindError{ErrChecksum, FormatError("missing pixel checksum")}
+	}
+	if d.sum != d.crc {
+		return &kindError{ErrChecksum, FormatError("pixel checksum mismatch")}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	p := image.NewPaletted(image.Rect(0, 0, 9, 7), palette.Plan9)
+	for i := range p.Pix {
+		p.Pix[i] = uint8(i * 7)
+	}
+	verify := &DecodeOptions{VerifyChecksum: true}
+	for _, tc := range []struct {
+		name string
+		m    image.Image
+		enc  Encoder
+		opts DecodeOptions
+	}{
+		{"RGBA", testImage(13, 5), Encoder{}, DecodeOptions{}},
+		{"RGB", testImage(13, 5), Encoder{Channels: RGB}, DecodeOptions{}},
+		{"Paletted", p, Encoder{}, DecodeOptions{}},
+		{"restarts", testImage(8, 20), Encoder{RestartInterval: 3}, DecodeOptions{}},
+		{"metadata", testImage(4, 4), Encoder{Metadata: &Metadata{Text: map[string]string{"k": "v"}}}, DecodeOptions{}},
+		{"PackRGB", testImage(6, 6), Encoder{Channels: RGB}, DecodeOptions{PackRGB: true}},
+		{"Premultiply", testImage(6, 6), Encoder{}, DecodeOptions{Premultiply: true, Orientation: OrientRightTop}},
+		{"ConvertColorSpace", testImage(6, 6), Encoder{}, DecodeOptions{ConvertColorSpace: true, ColorSpace: Linear}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.enc.Checksum = true
+			data := encodeBytes(t, tc.m, &tc.enc)
+			want, err := tc.opts.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.opts.VerifyChecksum = true
+			got, err := tc.opts.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			samePixels(t, got, want)
+
+			// Corrupting the checksum itself is caught as a mismatch.
+			bad := bytes.Clone(data)
+			i := bytes.LastIndex(bad, []byte(blockMagic+"csum")) + blockHdrLen
+			bad[i] ^= 1
+			if _, err := verify.Decode(bytes.NewReader(bad)); !errors.Is(err, ErrChecksum) {
+				t.Errorf("corrupt checksum: got %v, want ErrChecksum", err)
+			}
+		})
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	// Splice the checksum of one image onto another of the same size.
+	a := testImage(10, 10)
+	b := image.NewNRGBA(a.Rect)
+	copy(b.Pix, a.Pix)
+	b.SetNRGBA(3, 4, color.NRGBA{1, 2, 3, 4})
+	da := encodeBytes(t, a, &Encoder{Checksum: true})
+	db := encodeBytes(t, b, nil)
+	data := append(db, da[bytes.LastIndex(da, endMarker[:])+endLen:]...)
+
+	verify := &DecodeOptions{VerifyChecksum: true}
+	if _, err := verify.Decode(bytes.NewReader(data)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("mismatched pixels: got %v, want ErrChecksum", err)
+	}
+	if _, err := verify.Decode(bytes.NewReader(db)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("missing checksum: got %v, want ErrChecksum", err)
+	}
+	if _, err := Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("Decode without VerifyChecksum: %v", err)
+	}
+}
+
+func TestChecksumWriter(t *testing.T) {
+	m := testImage(5, 3)
+	var buf bytes.Buffer
+	qw, err := NewWriter(&buf, 5, 3, &Encoder{Checksum: true, Channels: RGB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qw.Write(m.Pix); err != nil {
+		t.Fatal(err)
+	}
+	if err := qw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verify := &DecodeOptions{VerifyChecksum: true}
+	if _, err := verify.Decode(&buf); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,227 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"io"
+)
+
+// A Chunk is one token of the chunk stream that follows a QOI header. Which
+// fields are used depends on Type:
+//
+//   - ChunkRGB: Color.R, Color.G and Color.B.
+//   - ChunkRGBA: Color.
+//   - ChunkIndex: Index, the position in the index of previously seen
+//     pixels, 0 to 63.
+//   - ChunkDiff: DR, DG and DB, the differences from the previous pixel's
+//     channels, each -2 to 1.
+//   - ChunkLuma: DR, DG and DB as for ChunkDiff. DG is -32 to 31, and
+//     DR-DG and DB-DG are -8 to 7.
+//   - ChunkRun: Run, the number of repeats of the previous pixel, 1 to 62.
+type Chunk struct {
+	Type       ChunkType
+	Color      color.NRGBA
+	Index      int
+	DR, DG, DB int
+	Run        int
+}
+
+// pixels returns the number of pixels c produces.
+func (c Chunk) pixels() int64 {
+	if c.Type == ChunkRun {
+		return int64(c.Run)
+	}
+	return 1
+}
+
+// appendChunk appends the encoding of c to b.
+func appendChunk(b []byte, c Chunk) ([]byte, error) {
+	in := func(v, lo, hi int) bool { return lo <= v && v <= hi }
+	switch c.Type {
+	case ChunkRGB:
+		return append(b, opRGB, c.Color.R, c.Color.G, c.Color.B), nil
+	case ChunkRGBA:
+		return append(b, opRGBA, c.Color.R, c.Color.G, c.Color.B, c.Color.A), nil
+	case ChunkIndex:
+		if in(c.Index, 0, 63) {
+			return append(b, opIndex|byte(c.Index)), nil
+		}
+	case ChunkDiff:
+		if in(c.DR, -2, 1) && in(c.DG, -2, 1) && in(c.DB, -2, 1) {
+			return append(b, opDiff|byte(c.DR+2)<<4|byte(c.DG+2)<<2|byte(c.DB+2)), nil
+		}
+	case ChunkLuma:
+		drg, dbg := c.DR-c.DG, c.DB-c.DG
+		if in(c.DG, -32, 31) && in(drg, -8, 7) && in(dbg, -8, 7) {
+			return append(b, opLuma|byte(c.DG+32), byte(drg+8)<<4|byte(dbg+8)), nil
+		}
+	case ChunkRun:
+		if in(c.Run, 1, maxRun) {
+			return append(b, opRun|byte(c.Run-1)), nil
+		}
+	default:
+		return b, errors.New("qoi: invalid chunk type " + c.Type.String())
+	}
+	return b, errors.New("qoi: " + c.Type.String() + " chunk field out of range")
+}
+
+// A ChunkReader reads the chunks of a QOI stream as tokens, without
+// decoding pixels, for tools that inspect or rewrite the chunk stream.
+type ChunkReader struct {
+	d    *decoder
+	left int64 // pixels not yet covered by chunks
+	err  error
+}
+
+// NewChunkReader reads the header of the QOI stream in r and returns a
+// ChunkReader for the chunks that follow it.
+func NewChunkReader(r io.Reader) (*ChunkReader, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	return &ChunkReader{d: d, left: int64(d.hdr.Width) * int64(d.hdr.Height)}, nil
+}
+
+// Header returns the stream's header.
+func (cr *ChunkReader) Header() Header { return cr.d.hdr }
+
+// InputOffset returns the offset of the next chunk in the stream.
+func (cr *ChunkReader) InputOffset() int64 { return cr.d.n }
+
+// Next returns the next chunk. After the chunk that covers the last pixel,
+// it reads the end marker and returns io.EOF.
+func (cr *ChunkReader) Next() (Chunk, error) {
+	if cr.err != nil {
+		return Chunk{}, cr.err
+	}
+	c, err := cr.next()
+	cr.err = err
+	return c, err
+}
+
+func (cr *ChunkReader) next() (Chunk, error) {
+	d := cr.d
+	if cr.left <= 0 {
+		if err := d.readEnd(); err != nil {
+			return Chunk{}, err
+		}
+		return Chunk{}, io.EOF
+	}
+	d.chunkOff = d.n
+	tag, err := d.readByte()
+	if err != nil {
+		return Chunk{}, d.errorAt(-1, err)
+	}
+
+	var c Chunk
+	switch {
+	case tag == opRGB:
+		if err := d.readFull(d.tmp[:3]); err != nil {
+			return Chunk{}, d.errorAt(int(tag), err)
+		}
+		c = Chunk{Type: ChunkRGB, Color: color.NRGBA{d.tmp[0], d.tmp[1], d.tmp[2], 0}}
+	case tag == opRGBA:
+		if err := d.readFull(d.tmp[:4]); err != nil {
+			return Chunk{}, d.errorAt(int(tag), err)
+		}
+		c = Chunk{Type: ChunkRGBA, Color: color.NRGBA{d.tmp[0], d.tmp[1], d.tmp[2], d.tmp[3]}}
+	case tag&opMask == opIndex:
+		c = Chunk{Type: ChunkIndex, Index: int(tag)}
+	case tag&opMask == opDiff:
+		c = Chunk{Type: ChunkDiff, DR: int(tag>>4&3) - 2, DG: int(tag>>2&3) - 2, DB: int(tag&3) - 2}
+	case tag&opMask == opLuma:
+		b, err := d.readByte()
+		if err != nil {
+			return Chunk{}, d.errorAt(int(tag), err)
+		}
+		dg := int(tag&0x3f) - 32
+		c = Chunk{Type: ChunkLuma, DR: dg + int(b>>4) - 8, DG: dg, DB: dg + int(b&0x0f) - 8}
+	default:
+		c = Chunk{Type: ChunkRun, Run: int(tag&0x3f) + 1}
+	}
+	cr.left -= c.pixels()
+	d.pixel += c.pixels()
+	return c, nil
+}
+
+// A ChunkWriter writes a QOI stream from chunk tokens. It checks that each
+// chunk can be encoded and that the chunks cover the image exactly, but not
+// that they are the ones an encoder would choose, so that variants can be
+// experimented with.
+type ChunkWriter struct {
+	w      io.Writer
+	buf    []byte
+	left   int64 // pixels not yet covered by chunks
+	closed bool
+	err    error
+}
+
+// NewChunkWriter writes the header h to w and returns a ChunkWriter for the
+// chunks that follow it.
+func NewChunkWriter(w io.Writer, h Header) (*ChunkWriter, error) {
+	if err := checkSize(int64(h.Width), int64(h.Height)); err != nil {
+		return nil, err
+	}
+	if err := checkHeaderFields(h.Channels, h.ColorSpace); err != nil {
+		return nil, err
+	}
+	cw := &ChunkWriter{w: w, left: int64(h.Width) * int64(h.Height)}
+	cw.buf = make([]byte, 0, flushSize+maxChunkLen)
+	cw.buf = append(cw.buf, magic...)
+	cw.buf = binary.BigEndian.AppendUint32(cw.buf, uint32(h.Width))
+	cw.buf = binary.BigEndian.AppendUint32(cw.buf, uint32(h.Height))
+	cw.buf = append(cw.buf, byte(h.Channels), byte(h.ColorSpace))
+	return cw, nil
+}
+
+// WriteChunk writes c.
+func (cw *ChunkWriter) WriteChunk(c Chunk) error {
+	if cw.closed {
+		return errors.New("qoi: write to closed ChunkWriter")
+	}
+	if cw.err != nil {
+		return cw.err
+	}
+	if c.pixels() > cw.left {
+		return errors.New("qoi: chunk extends past the last pixel")
+	}
+	buf, err := appendChunk(cw.buf, c)
+	if err != nil {
+		return err
+	}
+	cw.buf = buf
+	cw.left -= c.pixels()
+	if len(cw.buf) >= flushSize {
+		cw.flush()
+	}
+	return cw.err
+}
+
+func (cw *ChunkWriter) flush() {
+	if cw.err == nil && len(cw.buf) > 0 {
+		_, cw.err = cw.w.Write(cw.buf)
+	}
+	cw.buf = cw.buf[:0]
+}
+
+// Close writes the end marker. It reports an error, and leaves the output
+// without an end marker, if the chunks do not cover every pixel. Close does
+// not close the underlying writer.
+func (cw *ChunkWriter) Close() error {
+	if cw.closed {
+		return cw.err
+	}
+	cw.closed = true
+	if cw.err == nil && cw.left > 0 {
+		cw.flush()
+		if cw.err == nil {
+			cw.err = errors.New("qoi: Close before the chunks covered the image")
+		}
+		return cw.err
+	}
+	cw.buf = append(cw.buf, endMarker[:]...)
+	cw.flush()
+	return cw.err
+}
@@ -0,0 +1,114 @@
+package qoi
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestChunkReader(t *testing.T) {
+	data := []byte("qoif\x00\x00\x00\x07\x00\x00\x00\x01\x04\x00" +
+		"\xfe\x10\x80\xf0" + "\xff\x01\x02\x03\x04" + "\xc1" + "\x05" + "\x7f" + "\xa0\x9f" +
+		"\x00\x00\x00\x00\x00\x00\x00\x01")
+	want := []Chunk{
+		{Type: ChunkRGB, Color: color.NRGBA{0x10, 0x80, 0xf0, 0}},
+		{Type: ChunkRGBA, Color: color.NRGBA{1, 2, 3, 4}},
+		{Type: ChunkRun, Run: 2},
+		{Type: ChunkIndex, Index: 5},
+		{Type: ChunkDiff, DR: 1, DG: 1, DB: 1},
+		{Type: ChunkLuma, DR: 1, DG: 0, DB: 7},
+	}
+	cr, err := NewChunkReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := cr.Header(); h.Width != 7 || h.Height != 1 {
+		t.Errorf("got header %+v", h)
+	}
+	var got []Chunk
+	for {
+		c, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, c)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if cr.InputOffset() != int64(len(data)) {
+		t.Errorf("stopped at offset %d, want %d", cr.InputOffset(), len(data))
+	}
+}
+
+func TestChunkRoundtrip(t *testing.T) {
+	data := encodeBytes(t, testImage(23, 19), nil)
+	cr, err := NewChunkReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	cw, err := NewChunkWriter(&buf, cr.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		c, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.WriteChunk(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("copying the chunks changed the stream")
+	}
+}
+
+func TestChunkWriterInvalid(t *testing.T) {
+	h := Header{Width: 2, Height: 1, Channels: RGBA}
+	for _, c := range []Chunk{
+		{Type: ChunkIndex, Index: 64},
+		{Type: ChunkDiff, DR: 2},
+		{Type: ChunkLuma, DG: 32},
+		{Type: ChunkLuma, DR: 8},
+		{Type: ChunkRun, Run: 0},
+		{Type: ChunkRun, Run: 3},
+		{Type: 17},
+	} {
+		cw, err := NewChunkWriter(io.Discard, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.WriteChunk(c); err == nil {
+			t.Errorf("WriteChunk(%+v) succeeded", c)
+		}
+	}
+
+	var buf bytes.Buffer
+	cw, err := NewChunkWriter(&buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.WriteChunk(Chunk{Type: ChunkRGB}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Close(); err == nil {
+		t.Error("Close succeeded before the chunks covered the image")
+	}
+	if bytes.HasSuffix(buf.Bytes(), endMarker[:]) {
+		t.Error("Close wrote the end marker for an incomplete image")
+	}
+}
@@ -0,0 +1,220 @@
+// Qoibench measures how fast this package encodes and decodes a corpus of
+// images, and how small it makes them.
+//
+// Usage:
+//
+//	qoibench [flags] dir...
+//
+// Qoibench reads every PNG, JPEG, GIF and QOI file under the directories and
+// reports, for each image, the time per encode and per decode, the
+// throughput in megabytes of raw RGBA samples per second, and the encoded
+// size in bytes per pixel. The output is in the format of "go test -bench",
+// so that runs can be compared with benchstat. The flags are:
+//
+//	-png
+//		also measure image/png, for comparison
+//	-count n
+//		run each benchmark n times (default 1)
+//	-benchtime d
+//		run each benchmark for at least d (default 1s)
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoibench:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// A codec is an image format to measure.
+type codec struct {
+	name   string
+	encode func(w io.Writer, m image.Image) error
+	decode func(r io.Reader) (image.Image, error)
+}
+
+var (
+	qoiCodec = codec{"qoi", func(w io.Writer, m image.Image) error { return qoi.Encode(w, m) }, qoi.Decode}
+	pngCodec = codec{"png", png.Encode, png.Decode}
+)
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fset := flag.NewFlagSet("qoibench", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoibench [flags] dir...")
+		fset.PrintDefaults()
+	}
+	var (
+		withPNG   = fset.Bool("png", false, "also measure image/png")
+		count     = fset.Int("count", 1, "run each benchmark `n` times")
+		benchtime = fset.Duration("benchtime", time.Second, "run each benchmark for at least `d`")
+	)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() == 0 || *count < 1 {
+		fset.Usage()
+		return flag.ErrHelp
+	}
+	codecs := []codec{qoiCodec}
+	if *withPNG {
+		codecs = append(codecs, pngCodec)
+	}
+
+	var names []string
+	for _, dir := range fset.Args() {
+		err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(name)) {
+			case ".png", ".jpg", ".jpeg", ".gif", ".qoi":
+				if d.Type().IsRegular() {
+					names = append(names, name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if names == nil {
+		return errors.New("no images found")
+	}
+
+	fmt.Fprintf(stdout, "goos: %s\ngoarch: %s\npkg: github.com/clfs/qoi\n", runtime.GOOS, runtime.GOARCH)
+	for _, name := range names {
+		m, err := load(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, c := range codecs {
+			for range *count {
+				if err := bench(stdout, c, benchName(name), m, *benchtime); err != nil {
+					return fmt.Errorf("%s: %s: %v", name, c.name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// load decodes the image in the named file and converts it to
+// *image.NRGBA, so that every codec starts from the same pixels.
+func load(name string) (*image.NRGBA, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := src.(*image.NRGBA); ok {
+		return m, nil
+	}
+	m := image.NewNRGBA(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, src.Bounds().Min, draw.Src)
+	return m, nil
+}
+
+// benchName turns a file name into a benchmark name component, which must
+// not contain spaces, and in which slashes separate sub-benchmarks.
+func benchName(name string) string {
+	name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '/' || r == '-' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// bench measures encoding and decoding m with c and writes a result line
+// for each.
+func bench(w io.Writer, c codec, name string, m *image.NRGBA, d time.Duration) error {
+	var buf bytes.Buffer
+	if err := c.encode(&buf, m); err != nil {
+		return err
+	}
+	data := bytes.Clone(buf.Bytes())
+	pixels := int64(m.Rect.Dx()) * int64(m.Rect.Dy())
+
+	n, t, err := measure(d, func() error {
+		buf.Reset()
+		return c.encode(&buf, m)
+	})
+	if err != nil {
+		return err
+	}
+	report(w, "Encode/"+c.name+"/"+name, n, t, pixels, len(data))
+
+	n, t, err = measure(d, func() error {
+		_, err := c.decode(bytes.NewReader(data))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	report(w, "Decode/"+c.name+"/"+name, n, t, pixels, len(data))
+	return nil
+}
+
+// measure calls f, doubling the number of calls until they take at least
+// d, and returns the number of calls made in the last round and their
+// total time.
+func measure(d time.Duration, f func() error) (int, time.Duration, error) {
+	for n := 1; ; n *= 2 {
+		start := time.Now()
+		for range n {
+			if err := f(); err != nil {
+				return 0, 0, err
+			}
+		}
+		if t := time.Since(start); t >= d || n >= 1<<30 {
+			return n, t, nil
+		}
+	}
+}
+
+// report writes a result line like the testing package's, with the
+// throughput counted in raw RGBA bytes.
+func report(w io.Writer, name string, n int, t time.Duration, pixels int64, size int) {
+	ns := float64(t.Nanoseconds()) / float64(n)
+	mbs := 0.0
+	if ns > 0 {
+		mbs = float64(4*pixels) / ns * 1e3
+	}
+	bpp := 0.0
+	if pixels > 0 {
+		bpp = float64(size) / float64(pixels)
+	}
+	fmt.Fprintf(w, "Benchmark%s-%d\t%8d\t%12.0f ns/op\t%8.2f MB/s\t%8.4f bytes/pixel\n",
+		name, runtime.GOMAXPROCS(0), n, ns, mbs, bpp)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	m := image.NewNRGBA(image.Rect(0, 0, 32, 16))
+	for y := range 16 {
+		for x := range 32 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 16), 0x40, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "my image.png"), buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skipped"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-png", "-count", "2", "-benchtime", "1ms", dir}, &stdout, &stderr); err != nil {
+		t.Fatalf("run: %v\n%s", err, stderr.Bytes())
+	}
+	line := regexp.MustCompile(`^Benchmark(En|De)code/(qoi|png)/my_image-\d+\t +\d+\t +\d+ ns/op\t +[\d.]+ MB/s\t +[\d.]+ bytes/pixel$`)
+	results := 0
+	for _, l := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if strings.HasPrefix(l, "Benchmark") {
+			results++
+			if !line.MatchString(l) {
+				t.Errorf("malformed result line %q", l)
+			}
+		}
+	}
+	if results != 8 {
+		t.Errorf("got %d result lines, want 8:\n%s", results, stdout.Bytes())
+	}
+}
+
+func TestRunNoImages(t *testing.T) {
+	if err := run([]string{t.TempDir()}, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("run succeeded on an empty directory")
+	}
+}
@@ -0,0 +1,281 @@
+// Qoiconv converts images between QOI and PNG, JPEG or GIF.
+//
+// Usage:
+//
+//	qoiconv [flags] file...
+//
+// Each argument is a file name or a glob pattern. By default, QOI files are
+// converted to PNG and other files to QOI, each written next to its source
+// with the extension changed. The flags are:
+//
+//	-to format
+//		write this format (qoi, png, jpeg or gif) for every file
+//	-o dir
+//		write the converted files to dir instead
+//	-j n
+//		convert up to n files at a time (default: the number of CPUs)
+//	-channels 3|4|auto
+//		channel count of QOI output; auto writes 3 for opaque images
+//	-colorspace srgb|linear
+//		colorspace of QOI output
+//	-f
+//		overwrite existing files
+//
+// Qoiconv refuses to start if an output file would replace one of the
+// inputs, or if two inputs would be written to the same output file. Each
+// output is written to a temporary file that is renamed into place once it
+// is complete, so a failed conversion leaves existing files as they were.
+// Qoiconv keeps converting the other files when one fails, and exits with
+// status 1 if any did.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoiconv:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// A job is one file to convert.
+type job struct {
+	src, dst string
+	to       qoi.Format
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("qoiconv", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoiconv [flags] file...")
+		fs.PrintDefaults()
+	}
+	var (
+		to         = fs.String("to", "", "output `format`: qoi, png, jpeg or gif")
+		outDir     = fs.String("o", "", "output `dir`ectory")
+		workers    = fs.Int("j", runtime.GOMAXPROCS(0), "number of files to convert at a time")
+		channels   = fs.String("channels", "4", "QOI channel count: 3, 4 or auto")
+		colorspace = fs.String("colorspace", "srgb", "QOI colorspace: srgb or linear")
+		force      = fs.Bool("f", false, "overwrite existing files")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var opts qoi.TranscodeOptions
+	switch *channels {
+	case "3":
+		opts.Encoder.Channels = qoi.RGB
+	case "4":
+		opts.Encoder.Channels = qoi.RGBA
+	case "auto":
+		opts.Encoder.AutoChannels = true
+	default:
+		return fmt.Errorf("invalid channel count %q", *channels)
+	}
+	switch *colorspace {
+	case "srgb":
+		opts.Encoder.ColorSpace = qoi.SRGB
+	case "linear":
+		opts.Encoder.ColorSpace = qoi.Linear
+	default:
+		return fmt.Errorf("invalid colorspace %q", *colorspace)
+	}
+	format := qoi.FormatAuto
+	if *to != "" {
+		var ok bool
+		if format, ok = parseFormat(*to); !ok {
+			return fmt.Errorf("invalid format %q", *to)
+		}
+	}
+
+	var jobs []job
+	for _, arg := range fs.Args() {
+		names, err := filepath.Glob(arg)
+		if err != nil {
+			return err
+		}
+		if names == nil {
+			return fmt.Errorf("%s: no such file", arg)
+		}
+		for _, name := range names {
+			j := job{src: name, to: format}
+			if j.to == qoi.FormatAuto {
+				j.to = qoi.FormatQOI
+				if strings.EqualFold(filepath.Ext(name), qoi.Extension) {
+					j.to = qoi.FormatPNG
+				}
+			}
+			j.dst = strings.TrimSuffix(name, filepath.Ext(name)) + j.to.Extension()
+			if *outDir != "" {
+				j.dst = filepath.Join(*outDir, filepath.Base(j.dst))
+			}
+			jobs = append(jobs, j)
+		}
+	}
+	if err := checkJobs(jobs); err != nil {
+		return err
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o777); err != nil {
+			return err
+		}
+	}
+
+	// Files are opened a batch at a time, so that long lists of files do
+	// not run out of file descriptors.
+	n := *workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	failed := 0
+	for batch := range slices.Chunk(jobs, 4*n) {
+		var (
+			convs []*conversion
+			tjobs []qoi.TranscodeJob
+		)
+		for _, j := range batch {
+			c, err := start(j, *force)
+			if err != nil {
+				fmt.Fprintf(stderr, "qoiconv: %s: %v\n", j.src, err)
+				failed++
+				continue
+			}
+			convs = append(convs, c)
+			tjobs = append(tjobs, qoi.TranscodeJob{Dst: c.out, Src: c.in, From: qoi.FormatAuto, To: j.to})
+		}
+		errs := qoi.TranscodeBatch(context.Background(), tjobs, n, &opts)
+		for i, c := range convs {
+			if err := c.finish(errs[i], *force); err != nil {
+				fmt.Fprintf(stderr, "qoiconv: %s: %v\n", c.src, err)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(jobs))
+	}
+	return nil
+}
+
+// checkJobs rejects jobs that would write over one of the inputs, or over
+// the output of another job, before any file is written.
+func checkJobs(jobs []job) error {
+	srcs := make(map[string]string, len(jobs))
+	for _, j := range jobs {
+		abs, err := filepath.Abs(j.src)
+		if err != nil {
+			return err
+		}
+		srcs[abs] = j.src
+	}
+	dsts := make(map[string]string, len(jobs))
+	for _, j := range jobs {
+		abs, err := filepath.Abs(j.dst)
+		if err != nil {
+			return err
+		}
+		if src, ok := srcs[abs]; ok {
+			return fmt.Errorf("%s: output %s would replace input %s", j.src, j.dst, src)
+		}
+		// Names that differ can still be the same file, on a
+		// case-insensitive file system or through links.
+		if di, err := os.Stat(j.dst); err == nil {
+			if si, err := os.Stat(j.src); err == nil && os.SameFile(si, di) {
+				return fmt.Errorf("%s: output %s is the input file", j.src, j.dst)
+			}
+		}
+		if other, ok := dsts[abs]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", other, j.src, j.dst)
+		}
+		dsts[abs] = j.src
+	}
+	return nil
+}
+
+// A conversion is a job whose files are open. The output goes to a
+// temporary file in the destination directory, which finish renames into
+// place.
+type conversion struct {
+	job
+	in, out *os.File
+}
+
+// start opens the files of j.
+func start(j job, force bool) (*conversion, error) {
+	if !force {
+		if _, err := os.Lstat(j.dst); err == nil {
+			return nil, &fs.PathError{Op: "create", Path: j.dst, Err: fs.ErrExist}
+		}
+	}
+	in, err := os.Open(j.src)
+	if err != nil {
+		return nil, err
+	}
+	out, err := os.CreateTemp(filepath.Dir(j.dst), "."+filepath.Base(j.dst)+".*")
+	if err != nil {
+		in.Close()
+		return nil, err
+	}
+	return &conversion{j, in, out}, nil
+}
+
+// finish closes the files of c and, if err is nil, renames the output into
+// place. Otherwise, or if that fails, it removes the output.
+func (c *conversion) finish(err error, force bool) error {
+	c.in.Close()
+	if err == nil {
+		// CreateTemp makes files only their owner can read.
+		err = c.out.Chmod(0o644)
+	}
+	if cerr := c.out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && !force {
+		if _, lerr := os.Lstat(c.dst); lerr == nil {
+			err = &fs.PathError{Op: "create", Path: c.dst, Err: fs.ErrExist}
+		}
+	}
+	if err == nil {
+		err = os.Rename(c.out.Name(), c.dst)
+	}
+	if err != nil {
+		os.Remove(c.out.Name())
+	}
+	return err
+}
+
+func parseFormat(s string) (qoi.Format, bool) {
+	switch strings.ToLower(s) {
+	case "qoi":
+		return qoi.FormatQOI, true
+	case "png":
+		return qoi.FormatPNG, true
+	case "jpeg", "jpg":
+		return qoi.FormatJPEG, true
+	case "gif":
+		return qoi.FormatGIF, true
+	}
+	return 0, false
+}
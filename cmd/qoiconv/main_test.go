@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func testImage() *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := range 8 {
+		for x := range 16 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 16), uint8(y * 32), 0x80, 0xff})
+		}
+	}
+	return m
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	m := testImage()
+	for _, name := range []string{"a.png", "b.png"} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	var stderr bytes.Buffer
+	args := []string{"-o", out, "-channels", "auto", "-colorspace", "linear", filepath.Join(dir, "*.png")}
+	if err := run(args, &stderr); err != nil {
+		t.Fatalf("run: %v\n%s", err, stderr.Bytes())
+	}
+	for _, name := range []string{"a.qoi", "b.qoi"} {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := qoi.DecodeHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Channels != qoi.RGB || h.ColorSpace != qoi.Linear {
+			t.Errorf("%s: header %+v", name, h)
+		}
+		got, err := qoi.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.(*image.NRGBA).Pix, m.Pix) {
+			t.Errorf("%s: pixels differ", name)
+		}
+	}
+
+	// Converting back writes PNG next to the sources, and refuses to
+	// overwrite files without -f.
+	if err := run([]string{filepath.Join(out, "a.qoi")}, &stderr); err != nil {
+		t.Fatalf("run: %v\n%s", err, stderr.Bytes())
+	}
+	if _, err := os.Stat(filepath.Join(out, "a.png")); err != nil {
+		t.Error(err)
+	}
+	stderr.Reset()
+	if err := run([]string{filepath.Join(out, "a.qoi")}, &stderr); err == nil {
+		t.Error("run overwrote an existing file")
+	}
+	if err := run([]string{"-f", filepath.Join(out, "a.qoi")}, &stderr); err != nil {
+		t.Errorf("run -f: %v", err)
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-to", "bmp", "x.png"},
+		{"-channels", "5", "x.png"},
+		{"-colorspace", "p3", "x.png"},
+		{filepath.Join(t.TempDir(), "missing.png")},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
+
+func TestRunClobber(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.png", "a.gif", "sub/a.png"} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, buf.Bytes(), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(dir, "a.png")
+
+	for _, args := range [][]string{
+		{"-to", "png", "-f", a},
+		{"-f", a, filepath.Join(dir, "a.gif")},
+		{"-o", filepath.Join(dir, "sub"), "-to", "png", "-f", filepath.Join(dir, "sub", "a.png")},
+		{"-o", filepath.Join(dir, "out"), a, filepath.Join(dir, "sub", "a.png")},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+
+	// Nothing was written or removed.
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.gif", "a.png", "sub"}; len(names) != len(want) {
+		t.Errorf("files after failed runs: %q, want %q", names, want)
+	}
+	if data, err := os.ReadFile(a); err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("input changed: %v", err)
+	}
+}
+
+func TestRunFailureKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.qoi")
+	if err := os.WriteFile(src, []byte("qoif\x00\x00\x00\x10"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(dst, []byte("old"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-f", src}, &bytes.Buffer{}); err == nil {
+		t.Fatal("run converted a truncated file")
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "old" {
+		t.Errorf("existing output was replaced by a failed conversion: %q, %v", data, err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".*")); len(names) > 0 {
+		t.Errorf("temporary files left behind: %q", names)
+	}
+}
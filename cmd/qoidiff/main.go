@@ -0,0 +1,168 @@
+// Qoidiff compares the pixels of two images.
+//
+// Usage:
+//
+//	qoidiff [flags] a b
+//
+// The images may be QOI or PNG files, in any combination. Qoidiff reports
+// whether they are identical and, if not, how many pixels differ, the
+// rectangle holding them, the largest difference between two samples, and
+// the first few differing pixels. Like cmp, it exits with status 0 if the
+// images are identical, 1 if they differ, and 2 if either cannot be read.
+// The flags are:
+//
+//	-o file
+//		write an image highlighting the differing pixels in red over a
+//		dimmed gray copy of a, in QOI or PNG format by the file's extension
+//	-n count
+//		list up to count differing pixels (default 10)
+//	-q
+//		print nothing, only set the exit status
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clfs/qoi"
+)
+
+// errDiffer reports that the images differ.
+var errDiffer = errors.New("images differ")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+	case errors.Is(err, errDiffer):
+		os.Exit(1)
+	default:
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoidiff:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("qoidiff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoidiff [flags] a b")
+		fs.PrintDefaults()
+	}
+	var (
+		out   = fs.String("o", "", "write a highlighted diff image to `file`")
+		count = fs.Int("n", 10, "list up to `count` differing pixels")
+		quiet = fs.Bool("q", false, "print nothing")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *quiet {
+		stdout = io.Discard
+	}
+	an, bn := fs.Arg(0), fs.Arg(1)
+	a, err := load(an)
+	if err != nil {
+		return err
+	}
+	b, err := load(bn)
+	if err != nil {
+		return err
+	}
+	if a.Bounds().Size() != b.Bounds().Size() {
+		fmt.Fprintf(stdout, "%s is %v but %s is %v\n", an, a.Bounds().Size(), bn, b.Bounds().Size())
+		return errDiffer
+	}
+	d, err := qoi.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	if d.Equal() {
+		fmt.Fprintf(stdout, "%s and %s are identical\n", an, bn)
+		return nil
+	}
+
+	size := a.Bounds().Size()
+	total := int64(size.X) * int64(size.Y)
+	fmt.Fprintf(stdout, "%s and %s differ in %d of %d pixels (%.2f%%)\n", an, bn, d.Count, total, 100*float64(d.Count)/float64(total))
+	fmt.Fprintf(stdout, "  within %v, largest sample difference %d\n", d.Bounds, d.MaxDelta)
+	ob := b.Bounds().Min.Sub(a.Bounds().Min)
+	for i, p := range d.Points {
+		if i == *count {
+			break
+		}
+		ca := color.NRGBAModel.Convert(a.At(p.X, p.Y)).(color.NRGBA)
+		cb := color.NRGBAModel.Convert(b.At(p.X+ob.X, p.Y+ob.Y)).(color.NRGBA)
+		fmt.Fprintf(stdout, "  %v: %s != %s\n", p, hex(ca), hex(cb))
+	}
+	if int64(*count) < d.Count && *count > 0 {
+		fmt.Fprintf(stdout, "  ...\n")
+	}
+	if *out != "" {
+		if err := writeHighlight(*out, a, d.Heatmap); err != nil {
+			return err
+		}
+	}
+	return errDiffer
+}
+
+// load decodes the QOI or PNG image in the named file.
+func load(name string) (image.Image, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return m, nil
+}
+
+func hex(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// writeHighlight writes to the named file a dimmed gray copy of a with the
+// pixels that heat marks in red, brighter for larger differences.
+func writeHighlight(name string, a image.Image, heat *image.Gray) error {
+	r := heat.Rect
+	m := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if v := heat.GrayAt(x, y).Y; v > 0 {
+				m.SetNRGBA(x, y, color.NRGBA{v, 0, 0, 0xff})
+				continue
+			}
+			g := color.GrayModel.Convert(a.At(x, y)).(color.Gray).Y
+			g = 0x20 + g/4
+			m.SetNRGBA(x, y, color.NRGBA{g, g, g, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".qoi") {
+		err = qoi.Encode(&buf, m)
+	} else {
+		err = png.Encode(&buf, m)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o666)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func testImage() *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := range 10 {
+		for x := range 10 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 20), uint8(y * 20), 0x80, 0xff})
+		}
+	}
+	return m
+}
+
+func write(t *testing.T, name string, m image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if strings.HasSuffix(name, ".qoi") {
+		err = qoi.Encode(&buf, m)
+	} else {
+		err = png.Encode(&buf, m)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	m := testImage()
+	a := write(t, filepath.Join(dir, "a.qoi"), m)
+	same := write(t, filepath.Join(dir, "same.png"), m)
+	m.SetNRGBA(3, 4, color.NRGBA{1, 2, 3, 4})
+	m.SetNRGBA(6, 7, color.NRGBA{1, 2, 3, 4})
+	b := write(t, filepath.Join(dir, "b.png"), m)
+
+	var out bytes.Buffer
+	if err := run([]string{a, same}, &out, &out); err != nil {
+		t.Errorf("identical images: %v", err)
+	}
+	if !strings.Contains(out.String(), "identical") {
+		t.Errorf("output %q", out.String())
+	}
+
+	out.Reset()
+	hl := filepath.Join(dir, "diff.qoi")
+	if err := run([]string{"-o", hl, "-n", "1", a, b}, &out, &out); !errors.Is(err, errDiffer) {
+		t.Errorf("different images: %v", err)
+	}
+	for _, want := range []string{"differ in 2 of 100 pixels", "(3,4)-(7,8)", "(3,4): #3c5080ff != #01020304", "..."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	data, err := os.ReadFile(hl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := qoi.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := h.At(6, 7).(color.NRGBA); c.G != 0 || c.R < 0x40 {
+		t.Errorf("differing pixel highlighted as %v", c)
+	}
+	if c := h.At(0, 0).(color.NRGBA); c.R != c.G {
+		t.Errorf("matching pixel highlighted as %v", c)
+	}
+
+	out.Reset()
+	if err := run([]string{"-q", a, b}, &out, &out); !errors.Is(err, errDiffer) || out.Len() > 0 {
+		t.Errorf("-q: error %v, output %q", err, out.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := write(t, filepath.Join(dir, "a.qoi"), testImage())
+	small := write(t, filepath.Join(dir, "small.png"), image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	if err := run([]string{a, small}, &bytes.Buffer{}, &bytes.Buffer{}); !errors.Is(err, errDiffer) {
+		t.Errorf("different sizes: %v", err)
+	}
+	for _, args := range [][]string{{a}, {a, filepath.Join(dir, "missing.qoi")}} {
+		if err := run(args, &bytes.Buffer{}, &bytes.Buffer{}); err == nil || errors.Is(err, errDiffer) {
+			t.Errorf("run(%q) = %v", args, err)
+		}
+	}
+}
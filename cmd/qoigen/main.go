@@ -0,0 +1,227 @@
+// Qoigen generates synthetic images for testing and benchmarking, writing
+// each as a QOI file and a PNG file with the same pixels.
+//
+// Usage:
+//
+//	qoigen [flags]
+//
+// The flags are:
+//
+//	-pattern names
+//		comma-separated patterns to generate (default: all of them)
+//	-size sizes
+//		comma-separated sizes, each WxH (default 256x256)
+//	-o dir
+//		write the files to dir (default: the current directory)
+//	-seed n
+//		seed for the random patterns (default 1)
+//
+// The patterns are:
+//
+//	gradient  smooth horizontal and vertical color ramps
+//	noise     uniformly random opaque pixels
+//	flat      a single color
+//	text      dark glyph-like blocks on a light background, like a
+//	          screenshot of text
+//	checker   a checkerboard of transparent, translucent and opaque squares
+//
+// Files are named after the pattern and size, such as noise-64x64.qoi.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoigen:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// patterns maps each pattern name to a function that draws it.
+var patterns = map[string]func(m *image.NRGBA, r *rand.Rand){
+	"gradient": gradient,
+	"noise":    noise,
+	"flat":     flat,
+	"text":     text,
+	"checker":  checker,
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("qoigen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoigen [flags]")
+		fs.PrintDefaults()
+	}
+	var (
+		pattern = fs.String("pattern", "", "comma-separated `names` of patterns (default all)")
+		sizes   = fs.String("size", "256x256", "comma-separated `sizes`, each WxH")
+		outDir  = fs.String("o", ".", "output `dir`ectory")
+		seed    = fs.Uint64("seed", 1, "seed for the random patterns")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var names []string
+	if *pattern == "" {
+		for name := range patterns {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	} else {
+		names = strings.Split(*pattern, ",")
+		for _, name := range names {
+			if patterns[name] == nil {
+				return fmt.Errorf("unknown pattern %q", name)
+			}
+		}
+	}
+	var rects []image.Rectangle
+	for _, s := range strings.Split(*sizes, ",") {
+		var w, h int
+		if n, err := fmt.Sscanf(s, "%dx%d", &w, &h); n != 2 || err != nil || w <= 0 || h <= 0 {
+			return fmt.Errorf("invalid size %q", s)
+		}
+		rects = append(rects, image.Rect(0, 0, w, h))
+	}
+	if err := os.MkdirAll(*outDir, 0o777); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		for _, r := range rects {
+			m := image.NewNRGBA(r)
+			patterns[name](m, newRand(*seed))
+			base := filepath.Join(*outDir, fmt.Sprintf("%s-%dx%d", name, r.Dx(), r.Dy()))
+			if err := write(base+".qoi", m, qoi.Encode); err != nil {
+				return err
+			}
+			if err := write(base+".png", m, png.Encode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0))
+}
+
+// write encodes m with encode to the named file.
+func write(name string, m image.Image, encode func(io.Writer, image.Image) error) error {
+	var buf bytes.Buffer
+	if err := encode(&buf, m); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o666)
+}
+
+func gradient(m *image.NRGBA, _ *rand.Rand) {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	for y := range h {
+		for x := range w {
+			m.SetNRGBA(x, y, color.NRGBA{
+				uint8(x * 0xff / max(w-1, 1)),
+				uint8(y * 0xff / max(h-1, 1)),
+				uint8((x + y) * 0xff / max(w+h-2, 1)),
+				0xff,
+			})
+		}
+	}
+}
+
+func noise(m *image.NRGBA, r *rand.Rand) {
+	for i := 0; i < len(m.Pix); i += 4 {
+		v := r.Uint32()
+		m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = uint8(v), uint8(v>>8), uint8(v>>16), 0xff
+	}
+}
+
+func flat(m *image.NRGBA, _ *rand.Rand) {
+	for i := 0; i < len(m.Pix); i += 4 {
+		m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = 0x33, 0x66, 0x99, 0xff
+	}
+}
+
+// text draws lines of 5×7 glyphs made of random strokes, with a lighter
+// edge around each stroke like anti-aliasing.
+func text(m *image.NRGBA, r *rand.Rand) {
+	const (
+		cellW, cellH = 7, 11
+		glyphW       = 5
+		glyphH       = 7
+	)
+	bg := color.NRGBA{0xf8, 0xf8, 0xf2, 0xff}
+	ink := color.NRGBA{0x20, 0x20, 0x28, 0xff}
+	edge := color.NRGBA{0x9c, 0x9c, 0xa0, 0xff}
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	for y := range h {
+		for x := range w {
+			m.SetNRGBA(x, y, bg)
+		}
+	}
+	for cy := 0; cy+cellH <= h; cy += cellH {
+		lineLen := r.IntN(w/cellW + 1)
+		for cx := 0; cx/cellW < lineLen && cx+cellW <= w; cx += cellW {
+			if r.IntN(6) == 0 {
+				continue // a space
+			}
+			for gy := range glyphH {
+				bits := r.Uint32()
+				for gx := range glyphW {
+					if bits>>gx&1 == 0 {
+						continue
+					}
+					x, y := cx+1+gx, cy+2+gy
+					m.SetNRGBA(x, y, ink)
+					if m.NRGBAAt(x+1, y) == bg {
+						m.SetNRGBA(x+1, y, edge)
+					}
+				}
+			}
+		}
+	}
+}
+
+// checker draws 8×8 squares cycling through transparent, translucent and
+// opaque versions of a few colors.
+func checker(m *image.NRGBA, _ *rand.Rand) {
+	alphas := []uint8{0, 0x40, 0x80, 0xc0, 0xff}
+	colors := []color.NRGBA{{0xff, 0, 0, 0}, {0, 0xc0, 0x40, 0}, {0x20, 0x40, 0xff, 0}}
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	for y := range h {
+		for x := range w {
+			i := x/8 + y/8
+			c := colors[i%len(colors)]
+			c.A = alphas[i%len(alphas)]
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	var stderr bytes.Buffer
+	if err := run([]string{"-o", dir, "-size", "33x17,1x1"}, &stderr); err != nil {
+		t.Fatalf("run: %v\n%s", err, stderr.Bytes())
+	}
+	for name := range patterns {
+		for _, size := range []string{"33x17", "1x1"} {
+			base := filepath.Join(dir, name+"-"+size)
+			q, err := os.ReadFile(base + ".qoi")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := os.ReadFile(base + ".png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := qoi.Decode(bytes.NewReader(q))
+			if err != nil {
+				t.Fatalf("%s: %v", base, err)
+			}
+			b, err := png.Decode(bytes.NewReader(p))
+			if err != nil {
+				t.Fatalf("%s: %v", base, err)
+			}
+			d, err := qoi.Diff(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.Equal() {
+				t.Errorf("%s: QOI and PNG differ in %d pixels", base, d.Count)
+			}
+		}
+	}
+}
+
+func TestPatternsDeterministic(t *testing.T) {
+	for name, draw := range patterns {
+		a := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+		b := image.NewNRGBA(a.Rect)
+		draw(a, newRand(7))
+		draw(b, newRand(7))
+		if !bytes.Equal(a.Pix, b.Pix) {
+			t.Errorf("%s: same seed drew different pixels", name)
+		}
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"-pattern", "plaid"},
+		{"-size", "10"},
+		{"-size", "0x10"},
+		{"extra"},
+	} {
+		if err := run(append(args, "-o", t.TempDir()), &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Qoilint checks that files are valid QOI images and reports where they are
+// not.
+//
+// Usage:
+//
+//	qoilint [-v] file...
+//
+// Qoilint checks the magic, that the header fields are valid, that the
+// chunks cover exactly the pixels the header declares, the end marker, and
+// that anything after the end marker is well-formed metadata or restart
+// points rather than garbage. Each problem is printed with the byte offset
+// where it starts, and qoilint exits with status 1 if any file has one. With
+// -v, it also prints a line for each valid file.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoilint:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("qoilint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoilint [-v] file...")
+		fs.PrintDefaults()
+	}
+	verbose := fs.Bool("v", false, "print a line for each valid file too")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	bad := 0
+	for _, name := range fs.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			bad++
+			continue
+		}
+		problems := lint(data)
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: offset %d: %s\n", name, p.off, p.msg)
+		}
+		if len(problems) > 0 {
+			bad++
+		} else if *verbose {
+			fmt.Fprintf(stdout, "%s: ok\n", name)
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d of %d files have problems", bad, fs.NArg())
+	}
+	return nil
+}
+
+// A problem is something wrong with a file, starting at byte offset off.
+type problem struct {
+	off int64
+	msg string
+}
+
+const (
+	headerLen = 14
+	endLen    = 8
+
+	// maxPixels is the largest image the reference decoder accepts.
+	maxPixels = 400_000_000
+)
+
+var endMarker = []byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+// lint returns the problems in the QOI stream in data.
+func lint(data []byte) []problem {
+	var ps []problem
+	add := func(off int64, format string, args ...any) {
+		ps = append(ps, problem{off, fmt.Sprintf(format, args...)})
+	}
+
+	if !bytes.HasPrefix(data, []byte("qoif")) {
+		add(0, "bad magic %q, want \"qoif\"", data[:min(4, len(data))])
+		return ps
+	}
+	if len(data) < headerLen {
+		add(int64(len(data)), "header truncated after %d bytes", len(data))
+		return ps
+	}
+	w := binary.BigEndian.Uint32(data[4:])
+	h := binary.BigEndian.Uint32(data[8:])
+	if w == 0 {
+		add(4, "zero width")
+	}
+	if h == 0 {
+		add(8, "zero height")
+	}
+	if c := data[12]; c != 3 && c != 4 {
+		add(12, "invalid channel count %d", c)
+	}
+	if cs := data[13]; cs > 1 {
+		add(13, "invalid colorspace %d", cs)
+	}
+	if uint64(w)*uint64(h) > maxPixels {
+		add(4, "%dx%d image exceeds the reference decoder's limit of %d pixels", w, h, maxPixels)
+	}
+	if ps != nil {
+		return ps
+	}
+
+	cr, err := qoi.NewChunkReader(bytes.NewReader(data))
+	if err != nil {
+		add(0, "%s", message(err))
+		return ps
+	}
+	want := uint64(w) * uint64(h)
+	var pixels uint64
+	for {
+		off := cr.InputOffset()
+		c, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, qoi.ErrTruncated) && pixels >= want:
+				add(off, "stream ends before the end marker")
+			case errors.Is(err, qoi.ErrTruncated):
+				add(off, "stream ends inside the chunk for pixel (%d, %d)", pixels%uint64(w), pixels/uint64(w))
+			default:
+				add(off, "%s", message(err))
+			}
+			return ps
+		}
+		if c.Type == qoi.ChunkRun {
+			pixels += uint64(c.Run)
+		} else {
+			pixels++
+		}
+		if pixels > want {
+			add(off, "run covers %d pixels past the last one", pixels-want)
+		}
+	}
+
+	end := cr.InputOffset() - endLen
+	for i, b := range data[end : end+endLen] {
+		if b != endMarker[i] {
+			add(end+int64(i), "invalid end marker % x", data[end:end+endLen])
+			break
+		}
+	}
+	lintTrailer(data, end+endLen, add)
+	return ps
+}
+
+// lintTrailer checks the data after the end marker, which starts at off:
+// any number of extension blocks, as Encoder.Metadata and Encoder.Checksum
+// write them, followed by the restart points that Encoder.RestartInterval
+// writes, if any.
+func lintTrailer(data []byte, off int64, add func(int64, string, ...any)) {
+	start := off
+	rest := data[off:]
+	for bytes.HasPrefix(rest, []byte("qoie")) {
+		if len(rest) < 12 {
+			add(off, "extension block header truncated")
+			return
+		}
+		n := uint64(binary.BigEndian.Uint32(rest[8:]))
+		if n > uint64(len(rest)-12) {
+			add(off, "%q extension block of %d bytes extends past the end of the file", rest[4:8], n)
+			return
+		}
+		off += 12 + int64(n)
+		rest = rest[12+n:]
+	}
+	if off > start {
+		if _, err := qoi.ReadMetadata(bytes.NewReader(data)); err != nil {
+			add(start, "%s", message(err))
+		}
+	}
+	if len(rest) == 0 {
+		return
+	}
+	if len(rest) >= 12 && bytes.HasSuffix(rest, []byte("qoix")) {
+		n := uint64(binary.BigEndian.Uint32(rest[len(rest)-8:]))
+		if uint64(len(rest)) == 12+8*n {
+			// Decoding in parallel checks that each stripe ends where the
+			// next restart point says.
+			if _, err := qoi.DecodeParallel(data, 2); err != nil {
+				add(off, "%s", message(err))
+			}
+			return
+		}
+	}
+	add(off, "%d bytes of trailing garbage", len(rest))
+}
+
+// message returns err's text without the package prefix.
+func message(err error) string {
+	return strings.TrimPrefix(err.Error(), "qoi: ")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func encode(t *testing.T, enc *qoi.Encoder) []byte {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := range 8 {
+		for x := range 8 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 30), uint8(y * 30), 0x10, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLintValid(t *testing.T) {
+	for _, enc := range []*qoi.Encoder{
+		{},
+		{Channels: qoi.RGB},
+		{Checksum: true, Metadata: &qoi.Metadata{Text: map[string]string{"k": "v"}}},
+		{RestartInterval: 3, Checksum: true},
+	} {
+		if ps := lint(encode(t, enc)); ps != nil {
+			t.Errorf("%+v: problems %v", enc, ps)
+		}
+	}
+}
+
+func TestLintProblems(t *testing.T) {
+	data := encode(t, &qoi.Encoder{})
+	n := len(data)
+	edit := func(f func(b []byte) []byte) []byte { return f(bytes.Clone(data)) }
+	for _, tc := range []struct {
+		name string
+		data []byte
+		off  int64
+		msg  string
+	}{
+		{"magic", edit(func(b []byte) []byte { b[0] = 'x'; return b }), 0, "bad magic"},
+		{"short header", data[:10], 10, "header truncated"},
+		{"channels", edit(func(b []byte) []byte { b[12] = 5; return b }), 12, "channel count"},
+		{"colorspace", edit(func(b []byte) []byte { b[13] = 2; return b }), 13, "colorspace"},
+		{"width", edit(func(b []byte) []byte { b[7] = 0; return b }), 4, "zero width"},
+		{"truncated", data[:n-endLen-1], -1, "inside the chunk"},
+		{"no end marker", data[:n-endLen], int64(n - endLen), "before the end marker"},
+		{"end marker", edit(func(b []byte) []byte { b[n-3] = 9; return b }), int64(n - 3), "invalid end marker"},
+		{"garbage", append(bytes.Clone(data), "junk"...), int64(n), "4 bytes of trailing garbage"},
+		{"block", append(bytes.Clone(data), "qoietext\x00\x00\x01\x00"...), int64(n), "extends past the end"},
+	} {
+		ps := lint(tc.data)
+		if len(ps) != 1 {
+			t.Errorf("%s: problems %v, want one", tc.name, ps)
+			continue
+		}
+		if tc.off >= 0 && ps[0].off != tc.off || !strings.Contains(ps[0].msg, tc.msg) {
+			t.Errorf("%s: got %d: %s, want %d: ...%s...", tc.name, ps[0].off, ps[0].msg, tc.off, tc.msg)
+		}
+	}
+
+	// A run of 62 over the 64 pixels, ending 60 past the last one.
+	over := append(bytes.Clone(data[:headerLen]), 0xc0|61, 0xc0|61)
+	over = append(over, endMarker...)
+	ps := lint(over)
+	if len(ps) != 1 || ps[0].off != headerLen+1 || !strings.Contains(ps[0].msg, "60 pixels past") {
+		t.Errorf("overlong run: problems %v", ps)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.qoi"), filepath.Join(dir, "bad.qoi")
+	data := encode(t, &qoi.Encoder{})
+	os.WriteFile(good, data, 0o666)
+	os.WriteFile(bad, data[:20], 0o666)
+
+	var out bytes.Buffer
+	if err := run([]string{"-v", good}, &out, &out); err != nil || out.String() != good+": ok\n" {
+		t.Errorf("run(good) = %v, output %q", err, out.String())
+	}
+	out.Reset()
+	if err := run([]string{good, bad}, &out, &out); err == nil {
+		t.Error("run(good, bad) succeeded")
+	}
+	if !strings.HasPrefix(out.String(), bad+": offset ") {
+		t.Errorf("output %q", out.String())
+	}
+}
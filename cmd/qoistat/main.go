@@ -0,0 +1,170 @@
+// Qoistat prints the header and chunk statistics of QOI files.
+//
+// Usage:
+//
+//	qoistat file...
+//
+// For each file, qoistat prints the dimensions, channel count and
+// colorspace, how many chunks of each type encode the pixels and the bytes
+// they take, and the compression ratio against the raw samples. It then
+// lists any structural problems, such as a truncated stream, a run that
+// continues past the last pixel, or a damaged end marker, and exits with
+// status 1 if any file has one.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: qoistat file...")
+		os.Exit(2)
+	}
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "qoistat:", err)
+		os.Exit(1)
+	}
+}
+
+func run(names []string, w io.Writer) error {
+	bad := 0
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", name, err)
+			bad++
+			continue
+		}
+		s := inspect(data)
+		s.print(w, name)
+		if len(s.problems) > 0 {
+			bad++
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d of %d files have problems", bad, len(names))
+	}
+	return nil
+}
+
+// maxProblems is how many problems of one kind are listed per file.
+const maxProblems = 10
+
+// stats describes one file.
+type stats struct {
+	header   qoi.Header
+	hasHdr   bool
+	chunks   [qoi.ChunkRun + 1]int64
+	bytes    [qoi.ChunkRun + 1]int64
+	pixels   int64 // pixels covered by chunks
+	size     int64 // length of the file
+	trailer  int64 // bytes after the end marker
+	problems []string
+}
+
+func inspect(data []byte) *stats {
+	s := &stats{size: int64(len(data))}
+	cr, err := qoi.NewChunkReader(bytes.NewReader(data))
+	if err != nil {
+		s.problems = append(s.problems, err.Error())
+		return s
+	}
+	s.header, s.hasHdr = cr.Header(), true
+	want := int64(s.header.Width) * int64(s.header.Height)
+	alpha := 0
+	for {
+		off := cr.InputOffset()
+		c, err := cr.Next()
+		if err == io.EOF {
+			s.trailer = s.size - cr.InputOffset()
+			break
+		}
+		if err != nil {
+			s.problems = append(s.problems, err.Error())
+			return s
+		}
+		s.chunks[c.Type]++
+		s.bytes[c.Type] += chunkSize[c.Type]
+		if c.Type == qoi.ChunkRun {
+			s.pixels += int64(c.Run)
+		} else {
+			s.pixels++
+		}
+		if c.Type == qoi.ChunkRGBA && s.header.Channels == qoi.RGB && c.Color.A != 0xff {
+			if alpha++; alpha <= maxProblems {
+				s.problems = append(s.problems, fmt.Sprintf("offset %d: translucent pixel in a 3-channel image", off))
+			}
+		}
+	}
+	if alpha > maxProblems {
+		s.problems = append(s.problems, fmt.Sprintf("%d more translucent pixels", alpha-maxProblems))
+	}
+	if s.pixels > want {
+		s.problems = append(s.problems, fmt.Sprintf("last run continues %d pixels past the end of the image", s.pixels-want))
+	}
+	// Validate also checks the end marker as strictly as the
+	// specification requires.
+	if err := qoi.Validate(bytes.NewReader(data)); err != nil && s.pixels <= want {
+		s.problems = append(s.problems, err.Error())
+	}
+	if s.trailer > 0 {
+		if _, err := qoi.ReadMetadata(bytes.NewReader(data)); err != nil {
+			s.problems = append(s.problems, fmt.Sprintf("trailing data: %v", err))
+		}
+	}
+	return s
+}
+
+// chunkSize holds the length of a chunk of each type.
+var chunkSize = [...]int64{
+	qoi.ChunkRGB:   4,
+	qoi.ChunkRGBA:  5,
+	qoi.ChunkIndex: 1,
+	qoi.ChunkDiff:  1,
+	qoi.ChunkLuma:  2,
+	qoi.ChunkRun:   1,
+}
+
+func (s *stats) print(w io.Writer, name string) {
+	if !s.hasHdr {
+		fmt.Fprintf(w, "%s: not a QOI image\n", name)
+	} else {
+		h := s.header
+		cs := "sRGB"
+		if h.ColorSpace == qoi.Linear {
+			cs = "linear"
+		}
+		fmt.Fprintf(w, "%s: %dx%d, %d channels, %s\n", name, h.Width, h.Height, h.Channels, cs)
+
+		fmt.Fprintf(w, "  %-13s %10s %10s\n", "chunk", "count", "bytes")
+		var total int64
+		for t := qoi.ChunkRGB; t <= qoi.ChunkRun; t++ {
+			fmt.Fprintf(w, "  %-13v %10d %10d\n", t, s.chunks[t], s.bytes[t])
+			total += s.bytes[t]
+		}
+
+		raw := int64(h.Width) * int64(h.Height) * int64(h.Channels)
+		fmt.Fprintf(w, "  %d pixels in %d bytes of chunks", s.pixels, total)
+		if s.pixels > 0 {
+			fmt.Fprintf(w, ", %.2f bits/pixel", 8*float64(total)/float64(s.pixels))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  %d bytes in file", s.size)
+		if raw > 0 {
+			fmt.Fprintf(w, ", %.1f%% of %d raw bytes", 100*float64(s.size)/float64(raw), raw)
+		}
+		if s.trailer > 0 {
+			fmt.Fprintf(w, ", %d after the end marker", s.trailer)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, p := range s.problems {
+		fmt.Fprintf(w, "  problem: %s\n", p)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func encode(t *testing.T, m image.Image, enc *qoi.Encoder) []byte {
+	t.Helper()
+	if enc == nil {
+		enc = &qoi.Encoder{}
+	}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := range 8 {
+		for x := range 16 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x / 4 * 50), 0x80, uint8(y), 0xff})
+		}
+	}
+	data := encode(t, m, &qoi.Encoder{Metadata: &qoi.Metadata{Text: map[string]string{"a": "b"}}})
+	s := inspect(data)
+	if len(s.problems) > 0 {
+		t.Fatalf("problems: %q", s.problems)
+	}
+	if s.pixels != 16*8 || s.header.Width != 16 || s.trailer == 0 {
+		t.Errorf("pixels %d, header %+v, trailer %d", s.pixels, s.header, s.trailer)
+	}
+	var total int64
+	for _, b := range s.bytes {
+		total += b
+	}
+	if want := int64(len(data)) - 14 - 8 - s.trailer; total != want {
+		t.Errorf("chunks take %d bytes, want %d", total, want)
+	}
+
+	var out strings.Builder
+	s.print(&out, "x.qoi")
+	for _, want := range []string{"x.qoi: 16x8, 4 channels, sRGB", "QOI_OP_RUN", "bits/pixel"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestInspectProblems(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	data := encode(t, m, nil)
+	for name, data := range map[string][]byte{
+		"truncated":  data[:len(data)-4],
+		"end marker": append(data[:len(data)-1:len(data)-1], 2),
+		"not qoi":    []byte("hello, world"),
+	} {
+		if s := inspect(data); len(s.problems) == 0 {
+			t.Errorf("%s: no problems found", name)
+		}
+	}
+
+	// A run of 62 pixels over a 16-pixel image.
+	over := append(data[:14:14], 0xc0|61)
+	over = append(over, 0, 0, 0, 0, 0, 0, 0, 1)
+	s := inspect(over)
+	if len(s.problems) != 1 || !strings.Contains(s.problems[0], "past the end") {
+		t.Errorf("overlong run: problems %q", s.problems)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.qoi")
+	bad := filepath.Join(dir, "bad.qoi")
+	data := encode(t, image.NewNRGBA(image.Rect(0, 0, 2, 2)), nil)
+	os.WriteFile(good, data, 0o666)
+	os.WriteFile(bad, data[:20], 0o666)
+
+	var out bytes.Buffer
+	if err := run([]string{good}, &out); err != nil {
+		t.Errorf("run(good): %v", err)
+	}
+	if err := run([]string{good, bad}, &out); err == nil {
+		t.Error("run(good, bad) succeeded")
+	}
+}
@@ -0,0 +1,205 @@
+// Qoiview previews QOI images in a terminal.
+//
+// Usage:
+//
+//	qoiview [flags] file...
+//
+// By default, each image is drawn with half-block characters in 24-bit ANSI
+// color, two pixels to a character cell, scaled down to fit the terminal.
+// Transparent areas show a checkerboard. The flags are:
+//
+//	-w columns
+//		fit the image in this many columns (default: $COLUMNS, or 80)
+//	-h rows
+//		fit the image in this many rows (default: $LINES less one, or no limit)
+//	-sixel
+//		draw with sixel graphics instead, for terminals that support them,
+//		at up to 8 pixels per column and 16 per row
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/clfs/qoi"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "qoiview:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("qoiview", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: qoiview [flags] file...")
+		fs.PrintDefaults()
+	}
+	var (
+		cols  = fs.Int("w", envInt("COLUMNS", 81)-1, "fit the image in this many `columns`")
+		rows  = fs.Int("h", envInt("LINES", 1<<16)-1, "fit the image in this many `rows`")
+		sixel = fs.Bool("sixel", false, "draw with sixel graphics")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *cols <= 0 || *rows <= 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	for _, name := range fs.Args() {
+		if fs.NArg() > 1 {
+			fmt.Fprintf(stdout, "%s:\n", name)
+		}
+		var err error
+		if *sixel {
+			err = view(stdout, name, 8**cols, 16**rows, writeSixel)
+		} else {
+			err = view(stdout, name, *cols, 2**rows, writeBlocks)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// envInt returns the value of the environment variable key as a positive
+// integer, or def.
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// view decodes the named file, scaled down to fit in maxW×maxH pixels, and
+// draws it with draw.
+func view(w io.Writer, name string, maxW, maxH int, draw func(io.Writer, *image.NRGBA) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := qoi.DecodeThumbnail(f, maxW, maxH)
+	if err != nil {
+		return err
+	}
+	return draw(w, m)
+}
+
+// opaque composites the pixel at (x, y) over a checkerboard.
+func opaque(m *image.NRGBA, x, y int) color.RGBA {
+	c := m.NRGBAAt(x, y)
+	bg := uint32(0x99)
+	if (x/4+y/4)%2 == 0 {
+		bg = 0xcc
+	}
+	a := uint32(c.A)
+	blend := func(v uint8) uint8 { return uint8((uint32(v)*a + bg*(0xff-a) + 0x7f) / 0xff) }
+	return color.RGBA{blend(c.R), blend(c.G), blend(c.B), 0xff}
+}
+
+// writeBlocks draws m with upper half blocks, whose foreground is the top
+// pixel of each cell and whose background is the bottom one.
+func writeBlocks(w io.Writer, m *image.NRGBA) error {
+	var b []byte
+	width, height := m.Rect.Dx(), m.Rect.Dy()
+	for y := 0; y < height; y += 2 {
+		for x := range width {
+			top := opaque(m, x, y)
+			b = fmt.Appendf(b, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+			if y+1 < height {
+				bot := opaque(m, x, y+1)
+				b = fmt.Appendf(b, "\x1b[48;2;%d;%d;%dm", bot.R, bot.G, bot.B)
+			}
+			b = append(b, "▀"...)
+		}
+		b = append(b, "\x1b[0m\n"...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// writeSixel draws m as sixel graphics, with colors reduced to a 6×6×6
+// cube.
+func writeSixel(w io.Writer, m *image.NRGBA) error {
+	width, height := m.Rect.Dx(), m.Rect.Dy()
+	b := fmt.Appendf(nil, "\x1bPq\"1;1;%d;%d", width, height)
+	for i := range 216 {
+		b = fmt.Appendf(b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	cube := func(v uint8) int { return (int(v)*5 + 0x7f) / 0xff }
+	idx := make([]uint8, width*height)
+	for y := range height {
+		for x := range width {
+			c := opaque(m, x, y)
+			idx[y*width+x] = uint8(cube(c.R)*36 + cube(c.G)*6 + cube(c.B))
+		}
+	}
+
+	// Each band of six rows is drawn once for each color it uses.
+	for y0 := 0; y0 < height; y0 += 6 {
+		var used [216]bool
+		for y := y0; y < min(y0+6, height); y++ {
+			for _, c := range idx[y*width : (y+1)*width] {
+				used[c] = true
+			}
+		}
+		first := true
+		for c := range used {
+			if !used[c] {
+				continue
+			}
+			if !first {
+				b = append(b, '$')
+			}
+			first = false
+			b = fmt.Appendf(b, "#%d", c)
+			run, prev := 0, byte(0)
+			for x := range width {
+				bits := byte(0)
+				for dy := range min(6, height-y0) {
+					if int(idx[(y0+dy)*width+x]) == c {
+						bits |= 1 << dy
+					}
+				}
+				if ch := 63 + bits; ch == prev || run == 0 {
+					prev = ch
+					run++
+					continue
+				}
+				b = appendSixels(b, prev, run)
+				prev, run = 63+bits, 1
+			}
+			b = appendSixels(b, prev, run)
+		}
+		b = append(b, '-')
+	}
+	b = append(b, "\x1b\\"...)
+	_, err := w.Write(b)
+	return err
+}
+
+// appendSixels appends n repeats of the sixel ch.
+func appendSixels(b []byte, ch byte, n int) []byte {
+	if n > 3 {
+		return fmt.Appendf(b, "!%d%c", n, ch)
+	}
+	for range n {
+		b = append(b, ch)
+	}
+	return b
+}
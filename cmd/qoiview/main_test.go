@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func writeImage(t *testing.T, w, h int) string {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), 0x80, uint8(x * 2)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := qoi.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "x.qoi")
+	if err := os.WriteFile(name, buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestBlocks(t *testing.T) {
+	name := writeImage(t, 64, 41)
+	var out bytes.Buffer
+	if err := run([]string{"-w", "16", "-h", "20", name}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	// Scaled down by 4 to 16×11 pixels, which take 6 rows of cells.
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 6", len(lines))
+	}
+	for i, l := range lines {
+		if n := strings.Count(l, "▀"); n != 16 {
+			t.Errorf("line %d has %d cells, want 16", i, n)
+		}
+	}
+	if strings.Contains(lines[5], "\x1b[48;2;") {
+		t.Error("last line has a background for a missing row")
+	}
+}
+
+func TestSixel(t *testing.T) {
+	name := writeImage(t, 20, 13)
+	var out bytes.Buffer
+	if err := run([]string{"-sixel", name}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	if !strings.HasPrefix(s, "\x1bPq\"1;1;20;13") || !strings.HasSuffix(s, "\x1b\\") {
+		t.Errorf("malformed sixel output %q", s)
+	}
+	if n := strings.Count(s, "-"); n != 3 {
+		t.Errorf("got %d bands, want 3", n)
+	}
+}
+
+func TestAppendSixels(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{{1, "?"}, {3, "???"}, {4, "!4?"}, {100, "!100?"}} {
+		if got := string(appendSixels(nil, '?', tc.n)); got != tc.want {
+			t.Errorf("appendSixels(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package qoi
+
+import "math"
+
+// Lookup tables converting 8-bit color channels between the sRGB transfer
+// function and linear light. Alpha is linear in both colorspaces and is
+// never converted. Linear values are quantized to 8 bits like any other
+// QOI sample, so dark tones lose precision in a round trip.
+var srgbToLinear, linearToSRGB [256]uint8
+
+func init() {
+	for i := range 256 {
+		v := float64(i) / 255
+		var lin, srgb float64
+		if v <= 0.04045 {
+			lin = v / 12.92
+		} else {
+			lin = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		if v <= 0.0031308 {
+			srgb = v * 12.92
+		} else {
+			srgb = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		srgbToLinear[i] = uint8(math.Round(lin * 255))
+		linearToSRGB[i] = uint8(math.Round(srgb * 255))
+	}
+}
+
+// colorTable returns the table converting samples stored in colorspace from
+// to colorspace to, or nil if no conversion is needed.
+func colorTable(from, to ColorSpace) *[256]uint8 {
+	switch {
+	case from == to:
+		return nil
+	case to == Linear:
+		return &srgbToLinear
+	default:
+		return &linearToSRGB
+	}
+}
+
+// convertRow applies t to the color channels of the NRGBA pixels in src,
+// storing the result in dst. dst and src may be the same slice.
+func convertRow(dst, src []byte, t *[256]uint8) {
+	for i := 0; i < len(src); i += 4 {
+		s := src[i : i+4 : i+4]
+		d := dst[i : i+4 : i+4]
+		d[0], d[1], d[2], d[3] = t[s[0]], t[s[1]], t[s[2]], s[3]
+	}
+}
@@ -0,0 +1,85 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestColorTables(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		table   *[256]uint8
+		in, out uint8
+	}{
+		{"sRGB to linear", &srgbToLinear, 0, 0},
+		{"sRGB to linear", &srgbToLinear, 0xff, 0xff},
+		{"sRGB to linear", &srgbToLinear, 0x80, 0x37},
+		{"linear to sRGB", &linearToSRGB, 0, 0},
+		{"linear to sRGB", &linearToSRGB, 0xff, 0xff},
+		{"linear to sRGB", &linearToSRGB, 0x37, 0x80},
+	} {
+		if got := tt.table[tt.in]; got != tt.out {
+			t.Errorf("%s: %#02x -> %#02x, want %#02x", tt.name, tt.in, got, tt.out)
+		}
+	}
+	for i := 1; i < 256; i++ {
+		if srgbToLinear[i] < srgbToLinear[i-1] || linearToSRGB[i] < linearToSRGB[i-1] {
+			t.Fatalf("tables are not monotonic at %d", i)
+		}
+	}
+}
+
+func TestDecodeConvertColorSpace(t *testing.T) {
+	m := testImage(24, 10)
+	data := encodeBytes(t, m, &Encoder{ColorSpace: Linear})
+
+	for _, to := range []ColorSpace{SRGB, Linear} {
+		opts := &DecodeOptions{ConvertColorSpace: true, ColorSpace: to}
+		got, err := opts.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := image.NewNRGBA(m.Rect)
+		copy(want.Pix, m.Pix)
+		if to == SRGB {
+			convertRow(want.Pix, want.Pix, &linearToSRGB)
+		}
+		if !bytes.Equal(got.(*image.NRGBA).Pix, want.Pix) {
+			t.Errorf("converting to colorspace %d: pixels differ", to)
+		}
+	}
+}
+
+func TestEncodeConvertColorSpace(t *testing.T) {
+	m := testImage(24, 10)
+	want := image.NewNRGBA(m.Rect)
+	convertRow(want.Pix, m.Pix, &srgbToLinear)
+
+	data := encodeBytes(t, m, &Encoder{ColorSpace: Linear, ConvertColorSpace: true})
+	if !bytes.Equal(data, encodeBytes(t, want, &Encoder{ColorSpace: Linear})) {
+		t.Error("converted encoding differs from encoding linear samples")
+	}
+	if !bytes.Equal(m.Pix, testImage(24, 10).Pix) {
+		t.Error("conversion modified the source image")
+	}
+
+	// Converting back on decode recovers the sRGB samples, up to the
+	// precision 8-bit linear samples lose.
+	opts := &DecodeOptions{ConvertColorSpace: true, ColorSpace: SRGB}
+	got, err := opts.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range got.(*image.NRGBA).Pix {
+		if d := int(b) - int(m.Pix[i]); d < -13 || d > 13 {
+			t.Fatalf("byte %d: got %#02x, want about %#02x", i, b, m.Pix[i])
+		}
+	}
+
+	// An sRGB header needs no conversion.
+	data = encodeBytes(t, m, &Encoder{ConvertColorSpace: true})
+	if !bytes.Equal(data, encodeBytes(t, m, nil)) {
+		t.Error("sRGB output was converted")
+	}
+}
@@ -0,0 +1,126 @@
+// Package conformance checks QOI implementations against the official test
+// images at https://qoiformat.org/qoi_test_images.zip, which pair QOI files
+// encoded by the reference encoder with PNG files of the same pixels.
+//
+// The images are not part of the repository. To fetch them into
+// testdata/qoi_test_images, where this package's test looks for them, run
+//
+//	go generate ./conformance
+//
+// Forks and other implementations can run the same checks with Check.
+package conformance
+
+//go:generate go run fetch.go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clfs/qoi"
+)
+
+// DefaultDir is where go generate puts the test images, relative to this
+// package's directory.
+const DefaultDir = "testdata/qoi_test_images"
+
+// A Codec is the QOI implementation Check tests. Nil fields use package
+// qoi.
+type Codec struct {
+	// Decode decodes the QOI image in r.
+	Decode func(r io.Reader) (image.Image, error)
+
+	// Encode encodes m to w with the channel count and colorspace of h.
+	Encode func(w io.Writer, m image.Image, h qoi.Header) error
+}
+
+func (c *Codec) decode(r io.Reader) (image.Image, error) {
+	if c.Decode != nil {
+		return c.Decode(r)
+	}
+	return qoi.Decode(r)
+}
+
+func (c *Codec) encode(w io.Writer, m image.Image, h qoi.Header) error {
+	if c.Encode != nil {
+		return c.Encode(w, m, h)
+	}
+	enc := qoi.Encoder{Channels: h.Channels, ColorSpace: h.ColorSpace}
+	return enc.Encode(w, m)
+}
+
+// Check tests c against each QOI file in dir and the PNG file with the same
+// name: the QOI file must decode to the pixels of the PNG file, and
+// encoding those pixels with the channel count and colorspace of the QOI
+// file's header must reproduce it byte for byte, as the reference encoder
+// does. If c is nil, package qoi is tested. The error lists every file that
+// fails.
+func Check(dir string, c *Codec) error {
+	if c == nil {
+		c = &Codec{}
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.qoi"))
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		return fmt.Errorf("conformance: no QOI files in %s", dir)
+	}
+	var errs []error
+	for _, name := range names {
+		if err := checkFile(name, c); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(name), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkFile(name string, c *Codec) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(strings.TrimSuffix(name, ".qoi") + ".png")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		return err
+	}
+	h, err := qoi.DecodeHeader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	got, err := c.decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	d, err := qoi.Diff(got, want)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	if !d.Equal() {
+		return fmt.Errorf("decode: %d pixels differ from the PNG, first at %v", d.Count, d.Points[0])
+	}
+
+	var buf bytes.Buffer
+	if err := c.encode(&buf, want, h); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if buf.Len() != len(data) {
+		return fmt.Errorf("encode: %d bytes, want %d", buf.Len(), len(data))
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		return errors.New("encode: output differs from the reference encoder's")
+	}
+	return nil
+}
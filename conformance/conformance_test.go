@@ -0,0 +1,83 @@
+package conformance
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi"
+)
+
+func TestOfficialImages(t *testing.T) {
+	if _, err := os.Stat(DefaultDir); err != nil {
+		t.Skip("test images not fetched; run go generate")
+	}
+	if err := Check(DefaultDir, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// writePair writes m as name.qoi, encoded with enc, and name.png to dir.
+func writePair(t *testing.T, dir, name string, m image.Image, enc *qoi.Encoder) {
+	t.Helper()
+	var q, p bytes.Buffer
+	if err := enc.Encode(&q, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&p, m); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, name+".qoi"), q.Bytes(), 0o666)
+	os.WriteFile(filepath.Join(dir, name+".png"), p.Bytes(), 0o666)
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	m := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for y := range 10 {
+		for x := range 20 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 12), uint8(y * 25), 0x40, 0xff})
+		}
+	}
+	writePair(t, dir, "opaque", m, &qoi.Encoder{Channels: qoi.RGB})
+	m.SetNRGBA(3, 3, color.NRGBA{1, 2, 3, 4})
+	writePair(t, dir, "alpha", m, &qoi.Encoder{ColorSpace: qoi.Linear})
+	if err := Check(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A decoder that drops alpha and an encoder that ignores the header
+	// both fail.
+	bad := &Codec{
+		Decode: func(r io.Reader) (image.Image, error) {
+			m, err := qoi.Decode(r)
+			if err != nil {
+				return nil, err
+			}
+			n := m.(*image.NRGBA)
+			for i := 3; i < len(n.Pix); i += 4 {
+				n.Pix[i] = 0xff
+			}
+			return n, nil
+		},
+	}
+	err := Check(dir, bad)
+	if err == nil || !strings.Contains(err.Error(), "alpha.qoi: decode") || strings.Contains(err.Error(), "opaque.qoi") {
+		t.Errorf("dropping alpha: got %v", err)
+	}
+	bad = &Codec{Encode: func(w io.Writer, m image.Image, _ qoi.Header) error { return qoi.Encode(w, m) }}
+	err = Check(dir, bad)
+	if err == nil || !strings.Contains(err.Error(), "opaque.qoi: encode") {
+		t.Errorf("ignoring the header: got %v", err)
+	}
+
+	if err := Check(t.TempDir(), nil); err == nil {
+		t.Error("empty directory passed")
+	}
+}
@@ -0,0 +1,64 @@
+//go:build ignore
+
+// Fetch downloads the official QOI test images into testdata.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const url = "https://qoiformat.org/qoi_test_images.zip"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fetch: ")
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, f := range zr.File {
+		// Keep only the image files, flattened into one directory.
+		ext := filepath.Ext(f.Name)
+		if f.FileInfo().IsDir() || ext != ".qoi" && ext != ".png" || strings.HasPrefix(filepath.Base(f.Name), ".") {
+			continue
+		}
+		if err := extract(f, filepath.Join("testdata", "qoi_test_images", filepath.Base(f.Name))); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func extract(f *zip.File, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o666)
+}
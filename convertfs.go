@@ -0,0 +1,112 @@
+package qoi
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConvertOptions configures ConvertFS.
+type ConvertOptions struct {
+	// TranscodeOptions configures each conversion.
+	TranscodeOptions
+
+	// To is the format to convert to. FormatAuto converts QOI files, by
+	// their ".qoi" extension, to PNG and other files to QOI.
+	To Format
+
+	// Concurrency is the number of files to convert at a time; zero or
+	// less means runtime.GOMAXPROCS(0).
+	Concurrency int
+
+	// Overwrite makes ConvertFS replace existing files in dst instead of
+	// failing.
+	Overwrite bool
+}
+
+// ConvertFS converts each file in src whose path matches the pattern match,
+// as path.Match reports, into the directory dst, keeping the directory
+// structure of src and giving each file the extension of its new format. A
+// pattern without a slash is matched against the base name of each file
+// instead, so that "*.png" matches PNG files in every directory. The source
+// format is detected from the data. If opts is nil, the defaults of
+// Transcode are used.
+//
+// ConvertFS stops as EncodeBatch does, and the error for a file that
+// failed is a *BatchError wrapping an *fs.PathError for that file. Partly
+// written output files are removed.
+func ConvertFS(ctx context.Context, src fs.FS, match, dst string, opts *ConvertOptions) error {
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	if _, err := path.Match(match, ""); err != nil {
+		return err
+	}
+	base := !strings.Contains(match, "/")
+	var names []string
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		m := name
+		if base {
+			m = path.Base(name)
+		}
+		if ok, _ := path.Match(match, m); ok {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return runBatch(ctx, len(names), o.Concurrency, func(ctx context.Context, i int) error {
+		name := names[i]
+		if err := o.convert(src, name, dst); err != nil {
+			return &fs.PathError{Op: "convert", Path: name, Err: err}
+		}
+		return nil
+	})
+}
+
+// convert converts the named file in src into dst.
+func (o *ConvertOptions) convert(src fs.FS, name, dst string) error {
+	to := o.To
+	if to == FormatAuto {
+		to = FormatQOI
+		if strings.EqualFold(path.Ext(name), Extension) {
+			to = FormatPNG
+		}
+	}
+	out := filepath.Join(dst, filepath.FromSlash(strings.TrimSuffix(name, path.Ext(name))+to.Extension()))
+	if err := os.MkdirAll(filepath.Dir(out), 0o777); err != nil {
+		return err
+	}
+
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !o.Overwrite {
+		flag |= os.O_EXCL
+	}
+	f, err := os.OpenFile(out, flag, 0o666)
+	if err != nil {
+		return err
+	}
+	err = Transcode(f, in, FormatAuto, to, &o.TranscodeOptions)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package qoi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestConvertFS(t *testing.T) {
+	m := testImage(9, 7)
+	var p bytes.Buffer
+	if err := png.Encode(&p, m); err != nil {
+		t.Fatal(err)
+	}
+	src := fstest.MapFS{
+		"a.png":       {Data: p.Bytes()},
+		"sub/b.png":   {Data: p.Bytes()},
+		"sub/c.qoi":   {Data: encodeBytes(t, m, nil)},
+		"sub/d/e.png": {Data: p.Bytes()},
+		"notes.txt":   {Data: []byte("not an image")},
+	}
+	dst := t.TempDir()
+	ctx := context.Background()
+	if err := ConvertFS(ctx, src, "*.png", dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.qoi", "sub/b.qoi", "sub/d/e.qoi"} {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		samePixels(t, got, m)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sub/c.png")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("converted a file the pattern does not match: %v", err)
+	}
+
+	if err := ConvertFS(ctx, src, "sub/*.qoi", dst, &ConvertOptions{Concurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "sub/c.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, m)
+
+	err = ConvertFS(ctx, src, "a.png", dst, nil)
+	if !errors.Is(err, fs.ErrExist) {
+		t.Errorf("converting over an existing file: got %v, want %v", err, fs.ErrExist)
+	}
+	if err := ConvertFS(ctx, src, "a.png", dst, &ConvertOptions{Overwrite: true}); err != nil {
+		t.Errorf("Overwrite: %v", err)
+	}
+}
+
+func TestConvertFSErrors(t *testing.T) {
+	src := fstest.MapFS{"bad.png": {Data: []byte("not a PNG")}}
+	dst := t.TempDir()
+	err := ConvertFS(context.Background(), src, "*.png", dst, &ConvertOptions{To: FormatGIF})
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Path != "bad.png" {
+		t.Errorf("got %v, want an *fs.PathError for bad.png", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "bad.gif")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("partial output left behind: %v", err)
+	}
+
+	if err := ConvertFS(context.Background(), src, "[", dst, nil); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("bad pattern: got %v", err)
+	}
+}
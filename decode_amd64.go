@@ -0,0 +1,37 @@
+//go:build amd64 && !purego
+
+package qoi
+
+// fillWord fills b, whose length is a multiple of 4, with copies of the
+// pixel word w. Fills of at least four pixels are stored 16 bytes at a time
+// with SSE2.
+func fillWord(b []byte, w uint32) {
+	if len(b) < 16 {
+		fillWordGeneric(b, w)
+		return
+	}
+	n := len(b) &^ 15
+	fillSSE2(b[:n], w)
+	fillWordGeneric(b[n:], w)
+}
+
+// premultiplyRow converts non-premultiplied RGBA bytes in b to premultiplied
+// bytes in place, rounding exactly as color.RGBAModel does. Four pixels at
+// a time are converted with SSE2.
+func premultiplyRow(b []byte) {
+	n := len(b) &^ 15
+	if n > 0 {
+		premultiplySSE2(b[:n])
+	}
+	premultiplyRowGeneric(b[n:])
+}
+
+// fillSSE2 fills b, whose length is a multiple of 16, with copies of w.
+//
+//go:noescape
+func fillSSE2(b []byte, w uint32)
+
+// premultiplySSE2 is premultiplyRow for b whose length is a multiple of 16.
+//
+//go:noescape
+func premultiplySSE2(b []byte)
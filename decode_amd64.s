@@ -0,0 +1,72 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func fillSSE2(b []byte, w uint32)
+TEXT ·fillSSE2(SB), NOSPLIT, $0-28
+	MOVQ   b_base+0(FP), SI
+	MOVQ   b_len+8(FP), CX
+	MOVL   w+24(FP), AX
+	MOVL   AX, X0
+	PSHUFD $0, X0, X0
+	ADDQ   SI, CX
+
+loop:
+	CMPQ  SI, CX
+	JAE   done
+	MOVOU X0, (SI)
+	ADDQ  $16, SI
+	JMP   loop
+
+done:
+	RET
+
+// func premultiplySSE2(b []byte)
+TEXT ·premultiplySSE2(SB), NOSPLIT, $0-24
+	MOVQ b_base+0(FP), SI
+	MOVQ b_len+8(FP), CX
+	ADDQ SI, CX
+	PXOR X7, X7
+
+	// X6 holds the multiplier 0x8101 in every word, and X5 holds 0xff in
+	// the alpha words, which makes alpha its own result.
+	MOVQ       $0x8101810181018101, AX
+	MOVQ       AX, X6
+	PUNPCKLQDQ X6, X6
+	MOVQ       $0x00ff000000000000, AX
+	MOVQ       AX, X5
+	PUNPCKLQDQ X5, X5
+
+loop:
+	CMPQ SI, CX
+	JAE  done
+
+	// Widen four pixels to 16-bit words, two pixels to a register.
+	MOVOU     (SI), X0
+	MOVO      X0, X1
+	PUNPCKLBW X7, X0
+	PUNPCKHBW X7, X1
+
+	// Copy each pixel's alpha to all of its words.
+	PSHUFLW $0xff, X0, X2
+	PSHUFHW $0xff, X2, X2
+	PSHUFLW $0xff, X1, X3
+	PSHUFHW $0xff, X3, X3
+	POR     X5, X2
+	POR     X5, X3
+
+	// c*a*0x8101>>23, as a low multiply, a high multiply and a shift.
+	PMULLW  X2, X0
+	PMULLW  X3, X1
+	PMULHUW X6, X0
+	PMULHUW X6, X1
+	PSRLW   $7, X0
+	PSRLW   $7, X1
+
+	PACKUSWB X1, X0
+	MOVOU    X0, (SI)
+	ADDQ     $16, SI
+	JMP      loop
+
+done:
+	RET
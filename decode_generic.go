@@ -0,0 +1,15 @@
+//go:build !amd64 || purego
+
+package qoi
+
+// fillWord fills b, whose length is a multiple of 4, with copies of the
+// pixel word w.
+func fillWord(b []byte, w uint32) {
+	fillWordGeneric(b, w)
+}
+
+// premultiplyRow converts non-premultiplied RGBA bytes in b to premultiplied
+// bytes in place, rounding exactly as color.RGBAModel does.
+func premultiplyRow(b []byte) {
+	premultiplyRowGeneric(b)
+}
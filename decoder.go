@@ -0,0 +1,332 @@
+package qoi
+
+import (
+	"context"
+	"errors"
+	stdhash "hash"
+	"image"
+	"image/color"
+	"io"
+	"iter"
+)
+
+// DecodeOptions configures decoding QOI images. The zero value decodes the
+// same way as Decode.
+type DecodeOptions struct {
+	// BufferPool optionally specifies a buffer pool to get temporary
+	// DecoderBuffers when decoding an image.
+	BufferPool DecoderBufferPool
+
+	// ImagePool optionally supplies the images that decoded pixels are
+	// written into.
+	ImagePool ImagePool
+
+	// MaxWidth, MaxHeight and MaxPixels, if positive, limit the size of
+	// images that will be decoded. Larger images are rejected with a
+	// *SizeError right after the header is read, before any pixel memory is
+	// allocated. Limits applies them to the decoding functions that take no
+	// DecodeOptions.
+	MaxWidth, MaxHeight int
+	MaxPixels           int64
+
+	// Strict rejects streams that other decoders tolerate: a run that
+	// continues past the last pixel, or an end marker that is not the
+	// exact 8-byte sequence the specification requires.
+	Strict bool
+
+	// KeepPartial makes a decode that fails after the header return the
+	// image decoded so far along with the error, instead of a nil image.
+	// Pixels that were not reached are transparent black.
+	KeepPartial bool
+
+	// Premultiply makes Decode return an *image.RGBA, premultiplying each
+	// row as it is decoded rather than in a separate pass. ImagePool is not
+	// used in this mode. It does not affect Decoder.NextRow, which always
+	// returns non-premultiplied bytes.
+	Premultiply bool
+
+	// PackRGB makes Decode return an *RGBImage for images whose header
+	// declares three channels, saving the memory an alpha channel would
+	// take. It takes precedence over Premultiply, which makes no difference
+	// to opaque pixels, and ImagePool is not used for such images.
+	PackRGB bool
+
+	// If ConvertColorSpace is set, Decode converts the color channels of
+	// images whose header declares a colorspace other than ColorSpace, so
+	// that callers get samples in the colorspace they expect. Alpha is
+	// left as is. It does not affect Decoder.NextRow.
+	ConvertColorSpace bool
+	ColorSpace        ColorSpace
+
+	// FlipVertical makes Decode store the image upside down, for consumers
+	// such as OpenGL that expect the bottom row first. It does not affect
+	// Decoder.NextRow.
+	FlipVertical bool
+
+	// Orientation is a transform Decode applies as it stores pixels, such
+	// as the one an EXIF Orientation tag calls for. Transforms that rotate
+	// by 90° swap the width and height of the decoded image. FlipVertical,
+	// if also set, mirrors the result. It does not affect Decoder.NextRow.
+	Orientation Orientation
+
+	// VerifyChecksum makes Decode check the pixels against the checksum
+	// that Encoder.Checksum stores after the end marker, failing with an
+	// error matching ErrChecksum if they differ or there is no checksum.
+	// Decode then reads the extension blocks after the image too, so it
+	// must be the last thing in the stream.
+	VerifyChecksum bool
+
+	// Hash, if not nil, is fed the decoded pixels as Decode goes, as
+	// non-premultiplied RGBA bytes in row order before any of the
+	// conversions above, so that a digest of the pixels is computed
+	// without a second pass over the image. If decoding fails, the hash
+	// has been fed an unspecified prefix of the pixels.
+	Hash stdhash.Hash
+
+	// Progress, if not nil, is called by Decode after each row is decoded
+	// with the number of rows done so far and the number of bytes read
+	// from the input, counting the header.
+	Progress func(rows int, n int64)
+}
+
+// checkLimits reports whether o allows decoding an image with header h.
+func (o *DecodeOptions) checkLimits(h Header) error {
+	return Limits{o.MaxWidth, o.MaxHeight, o.MaxPixels}.check(h)
+}
+
+// Limits are the size limits of DecodeOptions on their own, for decoding
+// functions that take no other options. Each method of Limits is like the
+// package-level function of the same name, but rejects images larger than
+// the limits with a *SizeError right after the header is read, before any
+// pixel memory is allocated. As in DecodeOptions, a limit that is zero or
+// less does not apply.
+type Limits struct {
+	MaxWidth, MaxHeight int
+	MaxPixels           int64
+}
+
+// check reports whether l allows decoding an image with header h.
+func (l Limits) check(h Header) error {
+	if l.MaxWidth > 0 && h.Width > l.MaxWidth ||
+		l.MaxHeight > 0 && h.Height > l.MaxHeight ||
+		l.MaxPixels > 0 && uint64(h.Width)*uint64(h.Height) > uint64(l.MaxPixels) {
+		return &SizeError{Width: uint64(h.Width), Height: uint64(h.Height)}
+	}
+	return nil
+}
+
+// rowFunc returns the conversion to apply to each decoded row of an image
+// with header h, or nil if there is none.
+func (o *DecodeOptions) rowFunc(h Header) func(row []byte) {
+	if !o.ConvertColorSpace {
+		return nil
+	}
+	t := colorTable(h.ColorSpace, o.ColorSpace)
+	if t == nil {
+		return nil
+	}
+	return func(row []byte) { convertRow(row, row, t) }
+}
+
+// ImagePool is an interface for recycling decoded images. Get returns an
+// image with bounds (0, 0)-(w, h), or nil to have a new one allocated; its
+// pixels need not be cleared. Callers return images they no longer need with
+// Put. Decoding only calls Put itself for an image it got but failed to
+// fill.
+type ImagePool interface {
+	Get(w, h int) *image.NRGBA
+	Put(*image.NRGBA)
+}
+
+// DecoderBufferPool is an interface for getting and returning temporary
+// instances of the DecoderBuffer struct. This can be used to reuse buffers
+// when decoding multiple images.
+type DecoderBufferPool interface {
+	Get() *DecoderBuffer
+	Put(*DecoderBuffer)
+}
+
+// DecoderBuffer holds the buffers used for decoding QOI images.
+type DecoderBuffer decoder
+
+// Decode reads a QOI image from r and returns it as an *image.NRGBA.
+func (o *DecodeOptions) Decode(r io.Reader) (image.Image, error) {
+	return o.DecodeContext(context.Background(), r)
+}
+
+// DecodeContext is like Decode, but checks ctx after each row and returns
+// ctx.Err() if ctx is done before the image is complete.
+func (o *DecodeOptions) DecodeContext(ctx context.Context, r io.Reader) (image.Image, error) {
+	var d *decoder
+	if o.BufferPool != nil {
+		d = (*decoder)(o.BufferPool.Get())
+	}
+	if d == nil {
+		d = &decoder{}
+	}
+	if o.BufferPool != nil {
+		defer o.BufferPool.Put((*DecoderBuffer)(d))
+	}
+	d.reset(r)
+	d.strict = o.Strict
+	if ctx.Done() != nil {
+		// Only contexts that can be canceled are worth checking.
+		d.ctx = ctx
+	}
+	defer func() { d.ctx = nil }()
+	return d.decode(o)
+}
+
+// A Decoder reads QOI images from an input stream. Its buffers are kept
+// between images, so reusing one Decoder with Reset avoids allocating
+// decoder state for every image.
+//
+// An image can be read whole with Decode, or a row at a time with NextRow,
+// which lets callers pace decoding and stop early.
+type Decoder struct {
+	d    decoder
+	opts DecodeOptions
+
+	// Progress through the current image. y counts the rows returned by
+	// NextRow.
+	hdrRead bool
+	y       int
+	row     []byte
+	err     error
+}
+
+// NewDecoder returns a Decoder that reads from r. If opts is nil, the
+// defaults of Decode are used. opts.BufferPool is ignored, since a Decoder
+// keeps its own buffers.
+func NewDecoder(r io.Reader, opts *DecodeOptions) *Decoder {
+	dec := &Decoder{}
+	if opts != nil {
+		dec.opts = *opts
+	}
+	dec.d.strict = dec.opts.Strict
+	dec.Reset(r)
+	return dec
+}
+
+// Reset discards the Decoder's state and makes it read from r.
+func (dec *Decoder) Reset(r io.Reader) {
+	dec.d.reset(r)
+	dec.hdrRead, dec.y, dec.err = false, 0, nil
+}
+
+// Header reads the header of the current image, if it has not been read yet,
+// and returns it. If the input ends before another image starts, Header
+// returns io.EOF, as do Decode and NextRow.
+func (dec *Decoder) Header() (Header, error) {
+	if dec.err != nil {
+		return Header{}, dec.err
+	}
+	if !dec.hdrRead {
+		dec.d.resetImage()
+		start := dec.d.n
+		err := dec.d.readHeader()
+		if errors.Is(err, ErrTruncated) && dec.d.n == start {
+			// The input ended cleanly between images.
+			err = io.EOF
+		}
+		if err == nil {
+			err = dec.opts.checkLimits(dec.d.hdr)
+		}
+		if err != nil {
+			dec.err = err
+			return Header{}, err
+		}
+		dec.hdrRead = true
+	}
+	return dec.d.hdr, nil
+}
+
+// Decode reads the rest of the current image, which must not have been
+// partly read with NextRow. Since decoding stops just past the end marker,
+// calling Decode again reads an image that directly follows the previous
+// one, and io.EOF marks the end of a stream of concatenated images.
+func (dec *Decoder) Decode() (image.Image, error) {
+	if _, err := dec.Header(); err != nil {
+		return nil, err
+	}
+	if dec.y > 0 {
+		return nil, errors.New("qoi: Decode called after NextRow")
+	}
+	dec.hdrRead = false
+	m, err := dec.d.decodeImage(&dec.opts)
+	dec.err = err
+	return m, err
+}
+
+// NextRow decodes the next row of the current image and returns it as
+// non-premultiplied RGBA bytes. The slice is reused by the next call. After
+// the last row, NextRow reads the end marker and returns io.EOF; the next
+// call then starts on the image that follows, if any.
+func (dec *Decoder) NextRow() ([]byte, error) {
+	h, err := dec.Header()
+	if err != nil {
+		return nil, err
+	}
+	if dec.y == h.Height {
+		dec.hdrRead, dec.y = false, 0
+		if err := dec.d.readEnd(); err != nil {
+			dec.err = err
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	n := 4 * h.Width
+	if cap(dec.row) < n {
+		dec.row = make([]byte, n)
+	}
+	dec.row = dec.row[:n]
+	if err := dec.d.decodeSpan(dec.row); err != nil {
+		dec.err = err
+		return nil, err
+	}
+	dec.y++
+	return dec.row, nil
+}
+
+// InputOffset returns the number of bytes the Decoder has consumed from its
+// input since it was created or last Reset. After an image has been decoded
+// completely, this is the offset just past its end marker. If the input
+// does not implement io.ByteReader, the Decoder reads from it through a
+// buffer, so more bytes than this may have been read from it.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.d.n
+}
+
+// Err returns the first error the Decoder encountered, if any.
+func (dec *Decoder) Err() error {
+	return dec.err
+}
+
+// Pixels returns an iterator over the pixels of the current image in row
+// order, decoding them as it goes. Iteration stops early if decoding fails,
+// in which case Err reports why.
+func (dec *Decoder) Pixels() iter.Seq2[image.Point, color.NRGBA] {
+	return func(yield func(image.Point, color.NRGBA) bool) {
+		for {
+			y := dec.y
+			row, err := dec.NextRow()
+			if err != nil {
+				return
+			}
+			for i := 0; i < len(row); i += 4 {
+				c := color.NRGBA{row[i], row[i+1], row[i+2], row[i+3]}
+				if !yield(image.Pt(i/4, y), c) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pixels returns an iterator over the pixels of the QOI image in r, in row
+// order, decoding them as it goes without building an image. Iteration stops
+// early if r does not hold a valid image; use Decoder.Pixels and
+// Decoder.Err to tell that apart from the end of the image.
+func Pixels(r io.Reader) iter.Seq2[image.Point, color.NRGBA] {
+	return NewDecoder(r, nil).Pixels()
+}
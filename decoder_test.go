@@ -0,0 +1,505 @@
+package qoi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoderReset(t *testing.T) {
+	a, b := testImage(20, 10), testImage(9, 31)
+	da, db := encodeBytes(t, a, nil), encodeBytes(t, b, nil)
+
+	dec := NewDecoder(bytes.NewReader(da), nil)
+	got, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, a, got)
+
+	dec.Reset(bytes.NewReader(db))
+	if got, err = dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, b, got)
+
+	// Back-to-back images decode with successive calls.
+	dec.Reset(bytes.NewReader(append(da, db...)))
+	for _, want := range []*image.NRGBA{a, b} {
+		if got, err = dec.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, want, got)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode past the last image: got %v, want %v", err, io.EOF)
+	}
+}
+
+// decoderPool is a DecoderBufferPool holding at most one buffer.
+type decoderPool struct {
+	b    *DecoderBuffer
+	gets int
+}
+
+func (p *decoderPool) Get() *DecoderBuffer {
+	p.gets++
+	b := p.b
+	p.b = nil
+	return b
+}
+
+func (p *decoderPool) Put(b *DecoderBuffer) { p.b = b }
+
+func TestDecodeOptionsBufferPool(t *testing.T) {
+	pool := &decoderPool{}
+	opts := &DecodeOptions{BufferPool: pool}
+	for _, m := range []*image.NRGBA{testImage(30, 30), testImage(5, 8)} {
+		got, err := opts.Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, m, got)
+		if pool.b == nil {
+			t.Errorf("buffer was not returned to the pool")
+		}
+	}
+	if pool.gets != 2 {
+		t.Errorf("pool.Get called %d times, want 2", pool.gets)
+	}
+}
+
+// imagePool recycles images of any size.
+type imagePool struct {
+	free []*image.NRGBA
+	gets int
+	hits int
+}
+
+func (p *imagePool) Get(w, h int) *image.NRGBA {
+	p.gets++
+	for i, m := range p.free {
+		if m.Rect.Dx() == w && m.Rect.Dy() == h {
+			p.free = append(p.free[:i], p.free[i+1:]...)
+			p.hits++
+			return m
+		}
+	}
+	return nil
+}
+
+func (p *imagePool) Put(m *image.NRGBA) { p.free = append(p.free, m) }
+
+func TestDecodeOptionsImagePool(t *testing.T) {
+	pool := &imagePool{}
+	opts := &DecodeOptions{ImagePool: pool}
+	a, b := testImage(16, 16), testImage(8, 4)
+	da, db := encodeBytes(t, a, nil), encodeBytes(t, b, nil)
+
+	first, err := opts.Decode(bytes.NewReader(da))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(first.(*image.NRGBA))
+
+	// A different size can't reuse the pooled image.
+	if _, err := opts.Decode(bytes.NewReader(db)); err != nil {
+		t.Fatal(err)
+	}
+	second, err := opts.Decode(bytes.NewReader(da))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Errorf("pooled image was not reused")
+	}
+	samePixels(t, a, second)
+
+	// A failed decode hands its image back.
+	pool.Put(second.(*image.NRGBA))
+	if _, err := opts.Decode(bytes.NewReader(da[:len(da)/2])); err == nil {
+		t.Fatal("decoding a truncated stream succeeded")
+	}
+	if len(pool.free) != 1 {
+		t.Errorf("pool holds %d images after a failed decode, want 1", len(pool.free))
+	}
+	if pool.gets != 4 || pool.hits != 2 {
+		t.Errorf("gets %d, hits %d; want 4, 2", pool.gets, pool.hits)
+	}
+}
+
+func TestDecodePaddedPoolImage(t *testing.T) {
+	// A pooled image whose Stride exceeds its width must be filled row by
+	// row, with runs crossing row boundaries.
+	m := image.NewNRGBA(image.Rect(0, 0, 100, 20))
+	for i := range m.Pix {
+		m.Pix[i] = byte(i / 1000)
+	}
+	padded := image.NewNRGBA(image.Rect(0, 0, 110, 20)).SubImage(image.Rect(0, 0, 100, 20)).(*image.NRGBA)
+	pool := &imagePool{free: []*image.NRGBA{padded}}
+	got, err := (&DecodeOptions{ImagePool: pool}).Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != padded {
+		t.Fatal("pooled image was not used")
+	}
+	samePixels(t, m, got)
+}
+
+func TestDecoderNextRow(t *testing.T) {
+	a, b := testImage(13, 7), testImage(6, 3)
+	data := append(encodeBytes(t, a, nil), encodeBytes(t, b, nil)...)
+	dec := NewDecoder(bytes.NewReader(data), nil)
+
+	h, err := dec.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 13 || h.Height != 7 {
+		t.Fatalf("header: got %+v", h)
+	}
+	for y := 0; y < 7; y++ {
+		row, err := dec.NextRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(row, a.Pix[y*a.Stride:][:4*13]) {
+			t.Errorf("row %d differs", y)
+		}
+	}
+	if _, err := dec.NextRow(); err != io.EOF {
+		t.Fatalf("after the last row: got %v, want io.EOF", err)
+	}
+
+	// The next image can be read whole, or abandoned partway with Decode
+	// refusing to finish it.
+	row, err := dec.NextRow()
+	if err != nil || !bytes.Equal(row, b.Pix[:4*6]) {
+		t.Fatalf("first row of second image: %v", err)
+	}
+	if _, err := dec.Decode(); err == nil {
+		t.Errorf("Decode after NextRow succeeded")
+	}
+
+	dec.Reset(bytes.NewReader(data))
+	if _, err := dec.Header(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, a, got)
+}
+
+func TestPixels(t *testing.T) {
+	m := testImage(11, 9)
+	data := encodeBytes(t, m, nil)
+
+	n := 0
+	for p, c := range Pixels(bytes.NewReader(data)) {
+		if want := image.Pt(n%11, n/11); p != want {
+			t.Fatalf("pixel %d: got point %v, want %v", n, p, want)
+		}
+		if want := m.NRGBAAt(p.X, p.Y); c != want {
+			t.Fatalf("pixel %v: got %v, want %v", p, c, want)
+		}
+		n++
+	}
+	if n != 11*9 {
+		t.Errorf("got %d pixels, want %d", n, 11*9)
+	}
+
+	dec := NewDecoder(bytes.NewReader(data[:len(data)/2]), nil)
+	for range dec.Pixels() {
+	}
+	if dec.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("Err after a truncated stream: got %v, want %v", dec.Err(), io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	// A bare header claiming a huge image must be rejected before the
+	// decoder tries to allocate it.
+	huge := []byte("qoif\xff\xff\xff\xff\xff\xff\xff\xff\x04\x00")
+	data := encodeBytes(t, testImage(30, 20), nil)
+
+	tests := []struct {
+		opts DecodeOptions
+		data []byte
+		err  error
+	}{
+		{DecodeOptions{MaxPixels: 1 << 20}, huge, ErrTooLarge},
+		{DecodeOptions{MaxWidth: 29}, data, ErrTooLarge},
+		{DecodeOptions{MaxHeight: 19}, data, ErrTooLarge},
+		{DecodeOptions{MaxPixels: 599}, data, ErrTooLarge},
+		{DecodeOptions{MaxWidth: 30, MaxHeight: 20, MaxPixels: 600}, data, nil},
+	}
+	for i, tt := range tests {
+		if _, err := tt.opts.Decode(bytes.NewReader(tt.data)); !errors.Is(err, tt.err) {
+			t.Errorf("%d: Decode: got %v, want %v", i, err, tt.err)
+		}
+		dec := NewDecoder(bytes.NewReader(tt.data), &tt.opts)
+		if _, err := dec.Header(); !errors.Is(err, tt.err) {
+			t.Errorf("%d: Decoder.Header: got %v, want %v", i, err, tt.err)
+		}
+
+		l := Limits{tt.opts.MaxWidth, tt.opts.MaxHeight, tt.opts.MaxPixels}
+		if _, err := l.DecodeBytes(tt.data); !errors.Is(err, tt.err) {
+			t.Errorf("%d: DecodeBytes: got %v, want %v", i, err, tt.err)
+		}
+		if _, err := l.DecodeThumbnail(bytes.NewReader(tt.data), 8, 8); !errors.Is(err, tt.err) {
+			t.Errorf("%d: DecodeThumbnail: got %v, want %v", i, err, tt.err)
+		}
+		if _, err := l.DecodeRegion(bytes.NewReader(tt.data), image.Rect(0, 0, 4, 4)); !errors.Is(err, tt.err) {
+			t.Errorf("%d: DecodeRegion: got %v, want %v", i, err, tt.err)
+		}
+		if _, err := l.Salvage(bytes.NewReader(tt.data), nil); !errors.Is(err, tt.err) {
+			t.Errorf("%d: Salvage: got %v, want %v", i, err, tt.err)
+		}
+		if _, err := l.DecodeParallel(tt.data, 2); !errors.Is(err, tt.err) {
+			t.Errorf("%d: DecodeParallel: got %v, want %v", i, err, tt.err)
+		}
+		var buf bytes.Buffer
+		if _, err := l.DecodeToLayout(bytes.NewReader(tt.data), &buf, LayoutRGB); !errors.Is(err, tt.err) {
+			t.Errorf("%d: DecodeToLayout: got %v, want %v", i, err, tt.err)
+		} else if tt.err != nil && buf.Len() > 0 {
+			t.Errorf("%d: DecodeToLayout wrote %d bytes of a rejected image", i, buf.Len())
+		}
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	// A 2x1 image: one RGBA chunk, then a run of two where one is needed.
+	hdr := "qoif\x00\x00\x00\x02\x00\x00\x00\x01\x04\x00"
+	rgba := "\xff\x01\x02\x03\x04"
+	end := string(endMarker[:])
+	tests := []struct {
+		name   string
+		data   string
+		strict bool // whether Strict rejects it
+	}{
+		{"valid", hdr + rgba + "\xc0" + end, false},
+		{"run overshoot", hdr + rgba + "\xc1" + end, true},
+		{"bad end marker", hdr + rgba + "\xc0" + "\x00\x00\x00\x00\x00\x00\x00\x02", true},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(strings.NewReader(tt.data)); err != nil {
+			t.Errorf("%s: lenient Decode: %v", tt.name, err)
+		}
+		opts := &DecodeOptions{Strict: true}
+		_, err := opts.Decode(strings.NewReader(tt.data))
+		var fe FormatError
+		if tt.strict != errors.As(err, &fe) {
+			t.Errorf("%s: strict Decode: got %v", tt.name, err)
+		}
+		dec := NewDecoder(strings.NewReader(tt.data), opts)
+		_, err = dec.Decode()
+		if tt.strict != errors.As(err, &fe) {
+			t.Errorf("%s: strict Decoder.Decode: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestDecodeKeepPartial(t *testing.T) {
+	m := testImage(32, 32)
+	data := encodeBytes(t, m, nil)
+	cut := data[:len(data)/2]
+
+	if got, err := Decode(bytes.NewReader(cut)); got != nil || err == nil {
+		t.Fatalf("default Decode of a truncated stream: got %v, %v", got, err)
+	}
+
+	// A dirty pooled image must not leak stale pixels into the result.
+	stale := image.NewNRGBA(m.Rect)
+	for i := range stale.Pix {
+		stale.Pix[i] = 0xaa
+	}
+	for _, pool := range []*imagePool{{}, {free: []*image.NRGBA{stale}}} {
+		opts := &DecodeOptions{KeepPartial: true, ImagePool: pool}
+		got, err := opts.Decode(bytes.NewReader(cut))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+		pix := got.(*image.NRGBA).Pix
+		// Find where decoding stopped: the top row must be intact,
+		// the bottom row untouched.
+		if !bytes.Equal(pix[:4*32], m.Pix[:4*32]) {
+			t.Errorf("first row was not kept")
+		}
+		if !bytes.Equal(pix[len(pix)-4*32:], make([]byte, 4*32)) {
+			t.Errorf("last row is not transparent")
+		}
+	}
+}
+
+func TestDecoderInputOffset(t *testing.T) {
+	da := encodeBytes(t, testImage(10, 10), nil)
+	db := encodeBytes(t, testImage(3, 50), nil)
+	dec := NewDecoder(bytes.NewReader(append(da, db...)), nil)
+
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if got := dec.InputOffset(); got != int64(len(da)) {
+		t.Errorf("after first image: got %d, want %d", got, len(da))
+	}
+	if _, err := dec.Header(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dec.InputOffset(), int64(len(da)+headerLen); got != want {
+		t.Errorf("after second header: got %d, want %d", got, want)
+	}
+	for {
+		if _, err := dec.NextRow(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := dec.InputOffset(), int64(len(da)+len(db)); got != want {
+		t.Errorf("after second image: got %d, want %d", got, want)
+	}
+
+	dec.Reset(bytes.NewReader(db))
+	if got := dec.InputOffset(); got != 0 {
+		t.Errorf("after Reset: got %d, want 0", got)
+	}
+}
+
+func TestDecodePremultiply(t *testing.T) {
+	m := testImage(40, 30)
+	want := convertImage(image.NewRGBA(m.Rect), m).(*image.RGBA)
+	data := encodeBytes(t, m, nil)
+
+	opts := &DecodeOptions{Premultiply: true}
+	got, err := opts.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba, ok := got.(*image.RGBA)
+	if !ok {
+		t.Fatalf("got %T, want *image.RGBA", got)
+	}
+	if !bytes.Equal(rgba.Pix, want.Pix) {
+		t.Error("pixels differ from image/draw's conversion")
+	}
+
+	opts.KeepPartial = true
+	got, err = opts.Decode(bytes.NewReader(data[:len(data)/2]))
+	if err == nil {
+		t.Fatal("truncated stream decoded without error")
+	}
+	if !bytes.Equal(got.(*image.RGBA).Pix[:4*40], want.Pix[:4*40]) {
+		t.Error("first row was not kept")
+	}
+}
+
+func BenchmarkDecodePremultiply(b *testing.B) {
+	m := testImage(512, 512)
+	data := encodeBytes(b, m, nil)
+	opts := &DecodeOptions{Premultiply: true}
+	b.SetBytes(int64(len(m.Pix)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := opts.Decode(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeFlipVertical(t *testing.T) {
+	m := testImage(35, 13)
+	want := flipped(m)
+	for _, opts := range []*DecodeOptions{
+		{FlipVertical: true},
+		{FlipVertical: true, Premultiply: true},
+		{FlipVertical: true, PackRGB: true},
+	} {
+		var enc *Encoder
+		if opts.PackRGB {
+			enc = &Encoder{Channels: RGB}
+		}
+		got, err := opts.Decode(bytes.NewReader(encodeBytes(t, m, enc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain := *opts
+		plain.FlipVertical = false
+		w, err := plain.Decode(bytes.NewReader(encodeBytes(t, want, enc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, got, w)
+	}
+}
+
+func TestDecodeProgress(t *testing.T) {
+	m := testImage(60, 25)
+	data := encodeBytes(t, m, nil)
+	for _, opts := range []DecodeOptions{{}, {PackRGB: true}, {Orientation: OrientRightTop}} {
+		var rows int
+		var last int64
+		opts.Progress = func(n int, read int64) {
+			if n != rows+1 || read < last {
+				t.Errorf("%+v: progress went from (%d, %d) to (%d, %d)", opts, rows, last, n, read)
+			}
+			rows, last = n, read
+		}
+		in := data
+		if opts.PackRGB {
+			in = encodeBytes(t, m, &Encoder{Channels: RGB})
+		}
+		if _, err := opts.Decode(bytes.NewReader(in)); err != nil {
+			t.Fatal(err)
+		}
+		if rows != 25 || last != int64(len(in)-endLen) {
+			t.Errorf("%+v: last progress was (%d, %d), want (25, %d)", opts, rows, last, len(in)-endLen)
+		}
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	data := encodeBytes(t, testImage(32, 32), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := &DecodeOptions{Progress: func(rows int, _ int64) {
+		if rows == 10 {
+			cancel()
+		}
+	}}
+	if _, err := opts.DecodeContext(ctx, bytes.NewReader(data)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := DecodeContext(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Errorf("uncanceled: %v", err)
+	}
+}
+
+func TestDecodeHash(t *testing.T) {
+	m := testImage(33, 21)
+	for _, o := range []DecodeOptions{
+		{},
+		{FlipVertical: true, Premultiply: true},
+		{PackRGB: true, ConvertColorSpace: true, ColorSpace: Linear},
+		{VerifyChecksum: true},
+	} {
+		h := sha256.New()
+		o.Hash = h
+		if _, err := o.Decode(bytes.NewReader(encodeBytes(t, m, &Encoder{Checksum: true}))); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := h.Sum(nil), sha256.Sum256(m.Pix); !bytes.Equal(got, want[:]) {
+			t.Errorf("%+v: hash does not match the pixels", o)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package qoi
+
+import (
+	"context"
+	"image"
+	"io"
+)
+
+// Delta mode is a non-standard way of storing a sequence of frames, such as
+// a screen recording, as concatenated QOI images that share encoder state.
+// Each frame is a complete QOI image, except that a frame may start with the
+// magic "qoic" instead of "qoif". Such a continuation frame starts with the
+// index and previous pixel left by the end of the frame before it, rather
+// than with the initial values, so colors seen in earlier frames are written
+// as 1-byte index chunks. A frame with the standard magic, a key frame,
+// starts afresh. Other decoders decode key frames and reject continuation
+// frames as invalid.
+const deltaMagic = "qoic"
+
+// A DeltaEncoder writes a sequence of frames in delta mode. Frames may
+// differ in size. The frames can be read with a Decoder from
+// NewDeltaDecoder.
+type DeltaEncoder struct {
+	e   encoder
+	enc Encoder
+	w   io.Writer
+
+	// key forces the next frame to be a key frame.
+	key bool
+	err error
+}
+
+// NewDeltaEncoder returns a DeltaEncoder that writes frames to w. If opts
+// is nil, the defaults of Encode are used. opts.RestartInterval,
+// opts.Metadata, opts.Checksum and opts.BufferPool are ignored.
+func NewDeltaEncoder(w io.Writer, opts *Encoder) *DeltaEncoder {
+	de := &DeltaEncoder{w: w, key: true}
+	if opts != nil {
+		de.enc = *opts
+	}
+	de.enc.RestartInterval = 0
+	de.enc.Metadata = nil
+	de.enc.Checksum = false
+	de.enc.BufferPool = nil
+	return de
+}
+
+// KeyFrame makes the next frame a key frame, which can be decoded without
+// the frames before it.
+func (de *DeltaEncoder) KeyFrame() {
+	de.key = true
+}
+
+// Encode writes m as the next frame. The first frame is always a key frame.
+func (de *DeltaEncoder) Encode(m image.Image) error {
+	if de.err != nil {
+		return de.err
+	}
+	if err := checkSize(int64(m.Bounds().Dx()), int64(m.Bounds().Dy())); err != nil {
+		return err
+	}
+
+	e := &de.e
+	index, prev := e.index, e.prev
+	e.enc, e.w, e.m = &de.enc, de.w, m
+	defer func() { e.m = nil }()
+	e.reset()
+	if !de.key {
+		e.index, e.prev = index, prev
+	}
+	e.resetBuf()
+	e.writeHeader(m.Bounds().Dx(), m.Bounds().Dy())
+	if !de.key {
+		copy(e.buf, deltaMagic)
+	}
+	e.writeChunks(context.Background())
+	e.writeEnd()
+	// After a failed write, the decoder's state would no longer match.
+	de.err = e.err
+	de.key = false
+	return e.err
+}
+
+// NewDeltaDecoder returns a Decoder that reads frames written in delta mode,
+// as by a DeltaEncoder. Its Decode method returns io.EOF after the last
+// frame. The first frame, and the first one after Reset, must be a key
+// frame.
+func NewDeltaDecoder(r io.Reader, opts *DecodeOptions) *Decoder {
+	dec := NewDecoder(r, opts)
+	dec.d.delta = true
+	return dec
+}
@@ -0,0 +1,86 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/rand/v2"
+	"testing"
+)
+
+// noiseFrame returns a frame of pixels picked at random from a few colors
+// far enough apart that only index chunks encode them compactly.
+func noiseFrame(seed uint64) *image.NRGBA {
+	colors := []color.NRGBA{
+		{0x10, 0x80, 0xf0, 0xff}, {0xf0, 0x10, 0x80, 0xff}, {0x80, 0xf0, 0x10, 0xff},
+		{0x00, 0x00, 0x00, 0xff}, {0xff, 0xff, 0xff, 0xff}, {0x40, 0x40, 0xc0, 0x80},
+	}
+	rng := rand.New(rand.NewPCG(seed, 0))
+	m := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for y := range 24 {
+		for x := range 32 {
+			m.SetNRGBA(x, y, colors[rng.IntN(len(colors))])
+		}
+	}
+	return m
+}
+
+func TestDelta(t *testing.T) {
+	frames := []image.Image{noiseFrame(1), noiseFrame(2), testImage(9, 5), noiseFrame(3)}
+	var buf bytes.Buffer
+	de := NewDeltaEncoder(&buf, nil)
+	var sizes []int
+	for i, m := range frames {
+		if i == 2 {
+			de.KeyFrame()
+		}
+		n := buf.Len()
+		if err := de.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, buf.Len()-n)
+	}
+	data := buf.Bytes()
+
+	if plain := len(encodeBytes(t, frames[1], nil)); sizes[1] >= plain {
+		t.Errorf("continuation frame is %d bytes, want less than %d", sizes[1], plain)
+	}
+	for i, want := range []string{magic, deltaMagic, magic, deltaMagic} {
+		off := 0
+		for _, n := range sizes[:i] {
+			off += n
+		}
+		if got := string(data[off : off+4]); got != want {
+			t.Errorf("frame %d: magic %q, want %q", i, got, want)
+		}
+	}
+
+	dec := NewDeltaDecoder(bytes.NewReader(data), nil)
+	for i, want := range frames {
+		m, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		samePixels(t, m, want)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("after the last frame: got %v, want io.EOF", err)
+	}
+
+	// Standard decoders read key frames and reject continuation frames.
+	r := bytes.NewReader(data)
+	if _, err := Decode(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(r); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("continuation frame: got %v, want ErrBadMagic", err)
+	}
+
+	// Decoding can't start at a continuation frame.
+	dec.Reset(bytes.NewReader(data[sizes[0]:]))
+	if _, err := dec.Decode(); err == nil {
+		t.Error("decoding from a continuation frame succeeded")
+	}
+}
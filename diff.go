@@ -0,0 +1,77 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+)
+
+// maxDiffPoints is the number of differing pixels whose locations Diff
+// records.
+const maxDiffPoints = 100
+
+// A DiffResult describes how two images differ.
+type DiffResult struct {
+	// Count is the number of pixels that differ.
+	Count int64
+
+	// Bounds is the smallest rectangle holding every differing pixel, and
+	// Points holds the locations of the first of them in row order, up to
+	// 100. Both are in the coordinates of the first image.
+	Bounds image.Rectangle
+	Points []image.Point
+
+	// MaxDelta is the largest difference between two samples.
+	MaxDelta uint8
+
+	// Heatmap, if any pixels differ, has the bounds of the first image and
+	// shows where they do: the level of each pixel is zero where the
+	// images match, and otherwise rises from 0x40 with the largest
+	// difference between its samples, so that even the smallest one stands
+	// out.
+	Heatmap *image.Gray
+}
+
+// Equal reports whether the images were identical.
+func (r *DiffResult) Equal() bool { return r.Count == 0 }
+
+// Diff compares the pixels of a and b, which must be the same size, as
+// non-premultiplied RGBA, the form QOI stores. Fully transparent pixels
+// differ if their color channels do. It is meant for verifying lossless
+// round trips and comparing the output of different QOI implementations.
+func Diff(a, b image.Image) (*DiffResult, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return nil, errors.New("qoi: images differ in size")
+	}
+	r := &DiffResult{}
+	ra, rb := make([]byte, 4*ab.Dx()), make([]byte, 4*ab.Dx())
+	for y := range ab.Dy() {
+		readRow(a, ab.Min.Y+y, ra)
+		readRow(b, bb.Min.Y+y, rb)
+		for i := 0; i < len(ra); i += 4 {
+			var d uint8
+			for j := i; j < i+4; j++ {
+				d = max(d, max(ra[j], rb[j])-min(ra[j], rb[j]))
+			}
+			if d == 0 {
+				continue
+			}
+			r.add(image.Pt(ab.Min.X+i/4, ab.Min.Y+y), d, ab)
+		}
+	}
+	return r, nil
+}
+
+// add records a difference of d at p, in an image with bounds b.
+func (r *DiffResult) add(p image.Point, d uint8, b image.Rectangle) {
+	if r.Count == 0 {
+		r.Heatmap = image.NewGray(b)
+	}
+	r.Count++
+	r.Bounds = r.Bounds.Union(image.Rectangle{p, p.Add(image.Pt(1, 1))})
+	if len(r.Points) < maxDiffPoints {
+		r.Points = append(r.Points, p)
+	}
+	r.MaxDelta = max(r.MaxDelta, d)
+	r.Heatmap.Pix[r.Heatmap.PixOffset(p.X, p.Y)] = 0x40 + uint8(uint16(d)*0xbf/0xff)
+}
@@ -0,0 +1,54 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := testImage(16, 12)
+	got, err := Decode(bytes.NewReader(encodeBytes(t, a, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Diff(a, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal() || r.Heatmap != nil || r.Points != nil {
+		t.Errorf("identical images: got %+v", r)
+	}
+
+	// b is a shifted copy of a with two changed pixels.
+	b := image.NewNRGBA(a.Rect.Add(image.Pt(5, -3)))
+	copy(b.Pix, a.Pix)
+	c := a.NRGBAAt(3, 2)
+	c.G++
+	b.SetNRGBA(3+5, 2-3, c)
+	c = a.NRGBAAt(10, 7)
+	c.A -= 40
+	b.SetNRGBA(10+5, 7-3, c)
+
+	r, err = Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 2 || r.MaxDelta != 40 || r.Bounds != image.Rect(3, 2, 11, 8) {
+		t.Errorf("got count %d, max delta %d, bounds %v", r.Count, r.MaxDelta, r.Bounds)
+	}
+	if len(r.Points) != 2 || r.Points[0] != image.Pt(3, 2) || r.Points[1] != image.Pt(10, 7) {
+		t.Errorf("got points %v", r.Points)
+	}
+	if got := r.Heatmap.GrayAt(3, 2); got != (color.Gray{0x40}) {
+		t.Errorf("heatmap at a difference of 1: got %v", got)
+	}
+	if got := r.Heatmap.GrayAt(0, 0); got != (color.Gray{}) {
+		t.Errorf("heatmap at a match: got %v", got)
+	}
+
+	if _, err := Diff(a, testImage(16, 13)); err == nil {
+		t.Error("Diff accepted images of different sizes")
+	}
+}
@@ -0,0 +1,177 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// An EncodedImage is a QOI image kept in its encoded form. It implements
+// image.Image, decoding the pixels the first time they are needed, and
+// io.WriterTo, writing the original bytes, so that a server can look at or
+// process an image and still send it on without encoding it again. Holding
+// images this way also suits collections of which only a few images are
+// ever drawn: the others cost only their encoded size, and loading them
+// reads nothing but the header.
+//
+// An EncodedImage is safe for concurrent use.
+type EncodedImage struct {
+	data []byte
+	hdr  Header
+
+	once sync.Once
+	img  *image.NRGBA
+	err  error
+}
+
+// NewEncodedImage returns an EncodedImage holding the QOI image in data,
+// which must not be modified afterwards. Only the header is read; errors in
+// the rest of data are reported by Decode once pixels are needed.
+func NewEncodedImage(data []byte) (*EncodedImage, error) {
+	d := newDecoder(bytes.NewReader(data))
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	return &EncodedImage{data: data, hdr: d.hdr}, nil
+}
+
+// Header returns the header of the image.
+func (m *EncodedImage) Header() Header { return m.hdr }
+
+// Bytes returns the encoded image, including anything that followed its
+// end marker in the data it was created with. The slice must not be
+// modified.
+func (m *EncodedImage) Bytes() []byte { return m.data }
+
+// WriteTo writes the encoded image to w, as Bytes returns it.
+func (m *EncodedImage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m.data)
+	return int64(n), err
+}
+
+// Decode returns the decoded pixels, decoding them if this is the first
+// time they are needed. The image is shared by every caller and by At, so
+// it must not be modified.
+func (m *EncodedImage) Decode() (*image.NRGBA, error) {
+	m.once.Do(m.decode)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.img, nil
+}
+
+func (m *EncodedImage) decode() {
+	d := newDecoder(bytes.NewReader(m.data))
+	if m.err = d.readHeader(); m.err != nil {
+		return
+	}
+	// The pixels before an error are kept for At, as with
+	// DecodeOptions.KeepPartial.
+	m.img = image.NewNRGBA(image.Rect(0, 0, m.hdr.Width, m.hdr.Height))
+	m.err = d.decodeBytes(m.data, m.img.Pix)
+}
+
+// ColorModel returns RGBModel if the header declares three channels, and
+// color.NRGBAModel otherwise, as DecodeConfig does.
+func (m *EncodedImage) ColorModel() color.Model {
+	if m.hdr.Channels == RGB {
+		return RGBModel
+	}
+	return color.NRGBAModel
+}
+
+// Bounds returns the image's bounds, which are known without decoding it.
+func (m *EncodedImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, m.hdr.Width, m.hdr.Height)
+}
+
+// At returns the color of the pixel at (x, y). If the image fails to decode,
+// pixels past the error are transparent black.
+func (m *EncodedImage) At(x, y int) color.Color {
+	return m.NRGBAAt(x, y)
+}
+
+// NRGBAAt is At for callers that want a color.NRGBA.
+func (m *EncodedImage) NRGBAAt(x, y int) color.NRGBA {
+	m.once.Do(m.decode)
+	if m.img == nil {
+		return color.NRGBA{}
+	}
+	return m.img.NRGBAAt(x, y)
+}
+
+// SubImage returns an image representing the portion of m visible through
+// r. Like m, it does not decode the pixels until they are needed.
+func (m *EncodedImage) SubImage(r image.Rectangle) image.Image {
+	return &encodedRegion{m, r.Intersect(m.Bounds())}
+}
+
+// Opaque reports whether every pixel of the image is fully opaque. For
+// images whose header declares three channels, which are opaque unless
+// their chunks say otherwise, the chunks are checked without storing the
+// pixels.
+func (m *EncodedImage) Opaque() bool {
+	if m.hdr.Channels == RGB {
+		return opaqueChunks(m.data)
+	}
+	img, err := m.Decode()
+	return err == nil && img.Opaque()
+}
+
+// opaqueChunks reports whether every pixel of the image in data decodes
+// fully opaque. The header's channel count does not matter: the decoder
+// keeps the alpha of QOI_OP_RGBA chunks, and of the transparent black that
+// fills the index, in images declared RGB too.
+func opaqueChunks(data []byte) bool {
+	d := newDecoder(bytes.NewReader(data))
+	if err := d.readHeader(); err != nil {
+		return false
+	}
+	for n := int64(d.hdr.Width) * int64(d.hdr.Height); n > 0; {
+		if d.run > 0 {
+			// A run repeats a pixel already checked.
+			k := min(int64(d.run), n)
+			d.run -= int(k)
+			n -= k
+			continue
+		}
+		if err := d.advance(); err != nil || d.px.A != 0xff {
+			return false
+		}
+		n--
+	}
+	return true
+}
+
+// encodedRegion is the result of EncodedImage.SubImage.
+type encodedRegion struct {
+	m *EncodedImage
+	r image.Rectangle
+}
+
+func (p *encodedRegion) ColorModel() color.Model { return p.m.ColorModel() }
+
+func (p *encodedRegion) Bounds() image.Rectangle { return p.r }
+
+func (p *encodedRegion) At(x, y int) color.Color { return p.NRGBAAt(x, y) }
+
+func (p *encodedRegion) NRGBAAt(x, y int) color.NRGBA {
+	if !(image.Point{x, y}.In(p.r)) {
+		return color.NRGBA{}
+	}
+	return p.m.NRGBAAt(x, y)
+}
+
+func (p *encodedRegion) SubImage(r image.Rectangle) image.Image {
+	return &encodedRegion{p.m, r.Intersect(p.r)}
+}
+
+func (p *encodedRegion) Opaque() bool {
+	if p.m.hdr.Channels == RGB && opaqueChunks(p.m.data) {
+		return true
+	}
+	img, err := p.m.Decode()
+	return err == nil && img.SubImage(p.r).(*image.NRGBA).Opaque()
+}
@@ -0,0 +1,137 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodedImage(t *testing.T) {
+	src := testImage(40, 30)
+	data := encodeBytes(t, src, nil)
+	m, err := NewEncodedImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Bounds(), src.Bounds(); got != want {
+		t.Errorf("Bounds = %v, want %v", got, want)
+	}
+	if h := m.Header(); h.Channels != RGBA || h.Width != 40 {
+		t.Errorf("Header = %+v", h)
+	}
+	if m.img != nil {
+		t.Error("pixels decoded before they were needed")
+	}
+
+	var buf bytes.Buffer
+	if n, err := m.WriteTo(&buf); err != nil || n != int64(len(data)) {
+		t.Fatalf("WriteTo = %d, %v, want %d, nil", n, err, len(data))
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("WriteTo wrote different bytes")
+	}
+
+	samePixels(t, src, m)
+	img, err := m.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, src, img)
+	if !bytes.Equal(encodeBytes(t, m, nil), data) {
+		t.Error("re-encoding changed the bytes")
+	}
+}
+
+func TestEncodedImageErrors(t *testing.T) {
+	if _, err := NewEncodedImage([]byte("qoif")); !errors.Is(err, ErrTruncated) {
+		t.Errorf("short header: got %v, want ErrTruncated", err)
+	}
+
+	src := testImage(20, 10)
+	data := encodeBytes(t, src, nil)
+	m, err := NewEncodedImage(data[:len(data)/2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Decode(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Decode: got %v, want ErrTruncated", err)
+	}
+	if got, want := m.NRGBAAt(0, 0), src.NRGBAAt(0, 0); got != want {
+		t.Errorf("first pixel is %v, want %v", got, want)
+	}
+	// Pixels past the error were never reached.
+	if got := m.NRGBAAt(19, 9); got != (color.NRGBA{}) {
+		t.Errorf("last pixel is %v, want transparent black", got)
+	}
+}
+
+func TestEncodedImageSubImage(t *testing.T) {
+	src := testImage(40, 30)
+	m, err := NewEncodedImage(encodeBytes(t, src, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := image.Rect(5, 6, 30, 20)
+	sub := m.SubImage(r).(interface {
+		image.Image
+		SubImage(image.Rectangle) image.Image
+	})
+	if sub.Bounds() != r {
+		t.Errorf("Bounds = %v, want %v", sub.Bounds(), r)
+	}
+	if m.img != nil {
+		t.Error("SubImage decoded the pixels")
+	}
+	samePixels(t, src.SubImage(r), sub)
+	r2 := image.Rect(20, 0, 60, 10)
+	samePixels(t, src.SubImage(r).(*image.NRGBA).SubImage(r2), sub.SubImage(r2))
+	if got := sub.At(0, 0); got != (color.NRGBA{}) {
+		t.Errorf("At outside the bounds = %v", got)
+	}
+}
+
+func TestEncodedImageOpaque(t *testing.T) {
+	src := testImage(40, 30)
+	rgb, err := NewEncodedImage(encodeBytes(t, src, &Encoder{Channels: RGB}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rgb.Opaque() || rgb.img != nil {
+		t.Error("RGB image: want opaque without decoding")
+	}
+
+	// testImage is translucent only in columns 24 to 31 of each 32.
+	m, err := NewEncodedImage(encodeBytes(t, src, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Opaque() {
+		t.Error("translucent image reported opaque")
+	}
+	sub := m.SubImage(image.Rect(0, 0, 24, 30)).(interface{ Opaque() bool })
+	if !sub.Opaque() {
+		t.Error("opaque region reported translucent")
+	}
+
+	// A header declaring RGB does not make translucent chunks opaque.
+	var buf bytes.Buffer
+	if err := RewriteHeader(&buf, bytes.NewReader(encodeBytes(t, src, nil)), RGB, SRGB); err != nil {
+		t.Fatal(err)
+	}
+	relabeled, err := NewEncodedImage(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relabeled.Opaque() {
+		t.Error("relabeled translucent image reported opaque")
+	}
+	if sub := relabeled.SubImage(image.Rect(0, 0, 24, 30)).(interface{ Opaque() bool }); !sub.Opaque() {
+		t.Error("relabeled image: opaque region reported translucent")
+	}
+	if sub := relabeled.SubImage(image.Rect(20, 0, 30, 30)).(interface{ Opaque() bool }); sub.Opaque() {
+		t.Error("relabeled image: translucent region reported opaque")
+	}
+	samePixels(t, src, relabeled)
+}
@@ -0,0 +1,10 @@
+//go:build amd64 && !purego
+
+package qoi
+
+// equalRun returns the number of pixels at the start of pix, which holds
+// RGBA bytes, whose words masked by mask equal v. It compares four pixels
+// at a time with SSE2, which every amd64 processor has.
+//
+//go:noescape
+func equalRun(pix []byte, v, mask uint32) int
@@ -0,0 +1,76 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func equalRun(pix []byte, v, mask uint32) int
+TEXT ·equalRun(SB), NOSPLIT, $0-40
+	MOVQ pix_base+0(FP), SI
+	MOVQ pix_len+8(FP), CX
+	SHRQ $2, CX
+	MOVL v+24(FP), AX
+	MOVL mask+28(FP), DX
+	XORQ BX, BX
+
+	// Broadcast v and mask to every lane.
+	MOVL   AX, X0
+	PSHUFD $0, X0, X0
+	MOVL   DX, X1
+	PSHUFD $0, X1, X1
+
+	// Compare eight pixels per iteration while there are that many left.
+	MOVQ CX, R8
+	ANDQ $~7, R8
+
+loop8:
+	CMPQ     BX, R8
+	JAE      loop4
+	MOVOU    (SI)(BX*4), X2
+	MOVOU    16(SI)(BX*4), X3
+	PAND     X1, X2
+	PAND     X1, X3
+	PCMPEQL  X0, X2
+	PCMPEQL  X0, X3
+	PAND     X2, X3
+	PMOVMSKB X3, R9
+	CMPL     R9, $0xffff
+	JNE      loop4
+	ADDQ     $8, BX
+	JMP      loop8
+
+loop4:
+	MOVQ     CX, R9
+	SUBQ     BX, R9
+	CMPQ     R9, $4
+	JB       tail
+	MOVOU    (SI)(BX*4), X2
+	PAND     X1, X2
+	PCMPEQL  X0, X2
+	PMOVMSKB X2, R9
+	CMPL     R9, $0xffff
+	JNE      found
+	ADDQ     $4, BX
+	JMP      loop4
+
+found:
+	// The first zero bit of the byte mask marks the first differing
+	// pixel.
+	NOTL R9
+	BSFL R9, R9
+	SHRL $2, R9
+	ADDQ R9, BX
+	MOVQ BX, ret+32(FP)
+	RET
+
+tail:
+	CMPQ BX, CX
+	JAE  done
+	MOVL (SI)(BX*4), R9
+	ANDL DX, R9
+	CMPL R9, AX
+	JNE  done
+	INCQ BX
+	JMP  tail
+
+done:
+	MOVQ BX, ret+32(FP)
+	RET
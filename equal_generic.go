@@ -0,0 +1,9 @@
+//go:build !amd64 || purego
+
+package qoi
+
+// equalRun returns the number of pixels at the start of pix, which holds
+// RGBA bytes, whose words masked by mask equal v.
+func equalRun(pix []byte, v, mask uint32) int {
+	return equalRunGeneric(pix, v, mask)
+}
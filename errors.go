@@ -0,0 +1,97 @@
+package qoi
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sentinel errors for branching on the kind of a failure with errors.Is.
+// The errors returned by this package wrap them, and may carry more detail,
+// such as a FormatError, SizeError or DecodeError.
+var (
+	// ErrBadMagic means the input does not start with the QOI magic.
+	ErrBadMagic = errors.New("qoi: bad magic")
+
+	// ErrBadHeader means a header field holds an invalid value.
+	ErrBadHeader = errors.New("qoi: bad header")
+
+	// ErrTruncated means the input ended before the image did. It is
+	// io.ErrUnexpectedEOF, which the package returns unwrapped for
+	// truncated input, as the standard library's image decoders do.
+	ErrTruncated = io.ErrUnexpectedEOF
+
+	// ErrTooLarge means the image is too large to decode.
+	ErrTooLarge = errors.New("qoi: image is too large")
+
+	// ErrChecksum means the pixels of an image do not match the checksum
+	// stored with them, or that the checksum is missing.
+	ErrChecksum = errors.New("qoi: checksum mismatch")
+
+	// ErrUnsupported means an argument asks for something this package
+	// does not implement, such as an unknown pixel format.
+	ErrUnsupported = errors.New("qoi: unsupported")
+)
+
+// kindError is err classified as one of the sentinel errors.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// A FormatError reports that the input is not a valid QOI image.
+type FormatError string
+
+func (e FormatError) Error() string { return "qoi: invalid format: " + string(e) }
+
+// A SizeError reports an image that is too large to decode, either because
+// it exceeds the limits in DecodeOptions or because its pixels would not fit
+// in memory on this platform. It matches ErrTooLarge with errors.Is.
+type SizeError struct {
+	Width, Height uint64
+}
+
+func (e *SizeError) Error() string {
+	return "qoi: image too large: " + strconv.FormatUint(e.Width, 10) + "x" + strconv.FormatUint(e.Height, 10)
+}
+
+func (e *SizeError) Is(target error) bool { return target == ErrTooLarge }
+
+// A DecodeError records where in a stream the data turned out to be corrupt
+// after the header. Err is the underlying FormatError. Errors from reading
+// the stream, truncation among them, are returned unwrapped, as the
+// standard library's image decoders return them, except by Salvage.
+type DecodeError struct {
+	Offset int64 // byte offset of the chunk or end marker being read
+	X, Y   int   // pixel being decoded; Y is the image height at the end marker
+	Tag    int   // first byte of the chunk, or -1 if it could not be read
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "qoi: ")
+	s := "qoi: " + msg + " at offset " + strconv.FormatInt(e.Offset, 10) +
+		", pixel (" + strconv.Itoa(e.X) + ", " + strconv.Itoa(e.Y) + ")"
+	if e.Tag >= 0 {
+		s += ", chunk 0x" + strconv.FormatUint(uint64(e.Tag)|0x100, 16)[1:]
+	}
+	return s
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// A BatchError reports which item of EncodeBatch or DecodeBatch failed.
+type BatchError struct {
+	Index int // position of the item in the batch
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return "qoi: batch item " + strconv.Itoa(e.Index) + ": " + strings.TrimPrefix(e.Err.Error(), "qoi: ")
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
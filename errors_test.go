@@ -0,0 +1,51 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	valid := encodeBytes(t, testImage(4, 4), nil)
+	tests := []struct {
+		name string
+		err  error
+		want []error
+	}{
+		{"magic", decodeErr("qoiX\x00\x00\x00\x01\x00\x00\x00\x01\x04\x00"), []error{ErrBadMagic}},
+		{"size", decodeErr("qoif\x00\x00\x00\x00\x00\x00\x00\x01\x04\x00"), []error{ErrBadHeader}},
+		{"channels", decodeErr("qoif\x00\x00\x00\x01\x00\x00\x00\x01\x02\x00"), []error{ErrBadHeader}},
+		{"colorspace", decodeErr("qoif\x00\x00\x00\x01\x00\x00\x00\x01\x04\x07"), []error{ErrBadHeader}},
+		{"short header", decodeErr("qoif\x00"), []error{ErrTruncated, io.ErrUnexpectedEOF}},
+		{"short body", decodeErr(string(valid[:20])), []error{ErrTruncated, io.ErrUnexpectedEOF}},
+		{"huge", decodeErr("qoif\xff\xff\xff\xff\xff\xff\xff\xff\x04\x00"), []error{ErrTooLarge}},
+		{"layout", layoutErr(valid), []error{ErrUnsupported}},
+		{"pixel format", EncodeRaw(io.Discard, make([]byte, 4), 1, 1, 4, PixelFormat(99)), []error{ErrUnsupported}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.want {
+			if !errors.Is(tt.err, want) {
+				t.Errorf("%s: %v does not match %v", tt.name, tt.err, want)
+			}
+		}
+	}
+
+	// The detail types remain reachable.
+	var fe FormatError
+	if err := decodeErr("qoiX\x00\x00\x00\x01\x00\x00\x00\x01\x04\x00"); !errors.As(err, &fe) {
+		t.Errorf("bad magic: %v is not a FormatError", err)
+	}
+}
+
+func decodeErr(data string) error {
+	_, err := Decode(strings.NewReader(data))
+	return err
+}
+
+func layoutErr(data []byte) error {
+	_, err := DecodeToLayout(bytes.NewReader(data), io.Discard, PixelLayout(99))
+	return err
+}
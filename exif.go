@@ -0,0 +1,71 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// exifID prefixes the EXIF data in a JPEG APP1 segment.
+const exifID = "Exif\x00\x00"
+
+// JPEG markers.
+const (
+	jpegSOI  = 0xd8
+	jpegSOS  = 0xda
+	jpegAPP1 = 0xe1
+)
+
+// JPEGEXIF returns the EXIF data of the JPEG file in data, in the form
+// Metadata.EXIF holds, or nil if it has none. The result shares data's
+// memory. When converting a JPEG file to QOI, it lets the camera metadata
+// and orientation be kept.
+func JPEGEXIF(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != jpegSOI {
+		return nil, errors.New("qoi: not a JPEG file")
+	}
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, errors.New("qoi: invalid JPEG segment")
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill byte.
+			i++
+			continue
+		}
+		if marker == jpegSOS {
+			// The metadata segments all come before the image data.
+			return nil, nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return nil, errors.New("qoi: invalid JPEG segment")
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == jpegAPP1 && len(seg) >= len(exifID) && string(seg[:len(exifID)]) == exifID {
+			return seg[len(exifID):], nil
+		}
+		i += 2 + n
+	}
+}
+
+// AddJPEGEXIF returns a copy of the JPEG file in data with an APP1 segment
+// holding exif, in the form Metadata.EXIF holds, inserted after the start of
+// image marker. It reattaches EXIF data to a JPEG file written by
+// image/jpeg, which writes none. data should not already have EXIF data.
+func AddJPEGEXIF(data, exif []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != jpegSOI {
+		return nil, errors.New("qoi: not a JPEG file")
+	}
+	n := 2 + len(exifID) + len(exif)
+	if n > 0xffff {
+		return nil, errors.New("qoi: EXIF data too long for a JPEG segment")
+	}
+	out := make([]byte, 0, len(data)+2+n)
+	out = append(out, data[:2]...)
+	out = append(out, 0xff, jpegAPP1)
+	out = binary.BigEndian.AppendUint16(out, uint16(n))
+	out = append(out, exifID...)
+	out = append(out, exif...)
+	return append(out, data[2:]...), nil
+}
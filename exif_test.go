@@ -0,0 +1,66 @@
+package qoi
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+)
+
+func TestEXIFTranscode(t *testing.T) {
+	// Big-endian TIFF data with an empty IFD.
+	exif := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00")
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(16, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := JPEGEXIF(buf.Bytes()); err != nil || got != nil {
+		t.Fatalf("JPEG without EXIF data: got %q, %v", got, err)
+	}
+	src, err := AddJPEGEXIF(buf.Bytes(), exif)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// JPEG to QOI.
+	m, err := jpeg.Decode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := JPEGEXIF(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := encodeBytes(t, m, &Encoder{Metadata: &Metadata{EXIF: got}})
+
+	// QOI back to JPEG.
+	m, md, err := DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := jpeg.Encode(&buf, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := AddJPEGEXIF(buf.Bytes(), md.EXIF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := JPEGEXIF(dst); err != nil || !bytes.Equal(got, exif) {
+		t.Errorf("got EXIF data %q, %v, want %q", got, err, exif)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(dst)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJPEGEXIFInvalid(t *testing.T) {
+	for _, data := range []string{"", "\xff\xd8", "\xff\xd8\xff\xe1\x00\x40Exif", "GIF89a"} {
+		if _, err := JPEGEXIF([]byte(data)); err == nil {
+			t.Errorf("JPEGEXIF(%q) succeeded", data)
+		}
+	}
+	if _, err := AddJPEGEXIF([]byte("\xff\xd8"), make([]byte, 1<<16)); err == nil {
+		t.Error("AddJPEGEXIF accepted EXIF data too long for a segment")
+	}
+}
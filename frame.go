@@ -0,0 +1,124 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// Framing wraps each encoded image for transport over a byte stream, such
+// as a TCP connection, that does not delimit messages itself. A frame is:
+//
+//	flags  uint8    bit 0 set if a sequence number follows; others zero
+//	seq    uint64   sequence number, if present
+//	length uint32   length n of the image
+//	image  [n]byte  a complete QOI stream
+//
+// All integers are big-endian.
+const frameSeq = 1 << 0
+
+// maxFrameHeaderLen is the size of the longest frame header.
+const maxFrameHeaderLen = 1 + 8 + 4
+
+// A Frame is an image read by ReadFrame.
+type Frame struct {
+	Image image.Image
+
+	// Seq is the frame's sequence number, if HasSeq is set.
+	Seq    uint64
+	HasSeq bool
+}
+
+// WriteFrame encodes m and writes it to w as a frame without a sequence
+// number, in a single Write call. If opts is nil, the defaults of Encode are
+// used.
+func WriteFrame(w io.Writer, m image.Image, opts *Encoder) error {
+	return writeFrame(w, m, 0, false, opts)
+}
+
+// WriteFrameSeq is like WriteFrame, but includes the sequence number seq in
+// the frame.
+func WriteFrameSeq(w io.Writer, seq uint64, m image.Image, opts *Encoder) error {
+	return writeFrame(w, m, seq, true, opts)
+}
+
+func writeFrame(w io.Writer, m image.Image, seq uint64, hasSeq bool, opts *Encoder) error {
+	buf := make([]byte, 0, maxFrameHeaderLen)
+	if hasSeq {
+		buf = append(buf, frameSeq)
+		buf = binary.BigEndian.AppendUint64(buf, seq)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = append(buf, 0, 0, 0, 0)
+	start := len(buf)
+	buf, err := AppendEncode(buf, m, opts)
+	if err != nil {
+		return err
+	}
+	n := len(buf) - start
+	if uint64(n) >= 1<<32 {
+		return errors.New("qoi: frame too large")
+	}
+	binary.BigEndian.PutUint32(buf[start-4:], uint32(n))
+	_, err = w.Write(buf)
+	return err
+}
+
+// ReadFrame reads a frame written by WriteFrame or WriteFrameSeq from r and
+// decodes its image. If opts is nil, the defaults of Decode are used. It
+// returns io.EOF if r ends before the frame starts, and reads exactly
+// through the end of the frame when it succeeds, so that frames can be read
+// in a loop. Data in the frame after the image's end marker is skipped.
+func ReadFrame(r io.Reader, opts *DecodeOptions) (Frame, error) {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+	var f Frame
+	var hdr [maxFrameHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:1]); err != nil {
+		return f, err // io.EOF between frames
+	}
+	switch hdr[0] {
+	case 0:
+	case frameSeq:
+		f.HasSeq = true
+	default:
+		return f, FormatError("invalid frame flags")
+	}
+	h := hdr[1:5]
+	if f.HasSeq {
+		h = hdr[1:13]
+	}
+	if _, err := io.ReadFull(r, h); err != nil {
+		return f, truncated(err)
+	}
+	if f.HasSeq {
+		f.Seq = binary.BigEndian.Uint64(h)
+		h = h[8:]
+	}
+
+	lr := &io.LimitedReader{R: r, N: int64(binary.BigEndian.Uint32(h))}
+	m, err := opts.Decode(lr)
+	if err != nil {
+		return f, err
+	}
+	if _, err := io.Copy(io.Discard, lr); err != nil {
+		return f, err
+	}
+	if lr.N != 0 {
+		return f, io.ErrUnexpectedEOF
+	}
+	f.Image = m
+	return f, nil
+}
+
+// truncated maps the errors io.ReadFull reports for a short read to
+// io.ErrUnexpectedEOF.
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	a, b := testImage(7, 3), testImage(4, 9)
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, a, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrameSeq(&buf, 1<<40+5, b, &Encoder{RestartInterval: 2}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	r := bytes.NewReader(data)
+	f, err := ReadFrame(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.HasSeq {
+		t.Errorf("first frame has sequence number %d", f.Seq)
+	}
+	samePixels(t, f.Image, a)
+
+	// The restart trailer inside the frame is skipped.
+	f, err = ReadFrame(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.HasSeq || f.Seq != 1<<40+5 {
+		t.Errorf("second frame: got sequence number %d, %t", f.Seq, f.HasSeq)
+	}
+	samePixels(t, f.Image, b)
+
+	if _, err := ReadFrame(r, nil); err != io.EOF {
+		t.Errorf("after the last frame: got %v, want io.EOF", err)
+	}
+
+	first := 5 + len(encodeBytes(t, a, nil))
+	for n := 1; n < first; n++ {
+		if _, err := ReadFrame(bytes.NewReader(data[:n]), nil); !errors.Is(err, ErrTruncated) {
+			t.Errorf("%d bytes: got %v, want ErrTruncated", n, err)
+		}
+	}
+
+	bad := append([]byte{2}, data[1:]...)
+	if _, err := ReadFrame(bytes.NewReader(bad), nil); err == nil {
+		t.Error("ReadFrame accepted unknown flags")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/clfs/qoi
+
+go 1.23
@@ -0,0 +1,56 @@
+//go:build cgo && qoih
+
+package cqoi
+
+/*
+#define QOI_IMPLEMENTATION
+#define QOI_NO_STDIO
+#include <stdlib.h>
+#include "qoi.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Encode encodes w×h pixels of channels bytes each with qoi_encode.
+func Encode(pix []byte, w, h, channels, colorspace int) ([]byte, error) {
+	if len(pix) != w*h*channels || len(pix) == 0 {
+		return nil, errors.New("cqoi: pixel buffer does not match the size")
+	}
+	desc := C.qoi_desc{
+		width:      C.uint(w),
+		height:     C.uint(h),
+		channels:   C.uchar(channels),
+		colorspace: C.uchar(colorspace),
+	}
+	var n C.int
+	p := C.qoi_encode(unsafe.Pointer(&pix[0]), &desc, &n)
+	if p == nil {
+		return nil, errors.New("cqoi: qoi_encode failed")
+	}
+	defer C.free(p)
+	return C.GoBytes(p, n), nil
+}
+
+// Decode decodes data with qoi_decode, returning the pixels with channels
+// bytes each, or the number in the header if channels is 0, along with the
+// header fields.
+func Decode(data []byte, channels int) (pix []byte, w, h, ch, colorspace int, err error) {
+	if len(data) == 0 {
+		return nil, 0, 0, 0, 0, errors.New("cqoi: qoi_decode failed")
+	}
+	var desc C.qoi_desc
+	p := C.qoi_decode(unsafe.Pointer(&data[0]), C.int(len(data)), &desc, C.int(channels))
+	if p == nil {
+		return nil, 0, 0, 0, 0, errors.New("cqoi: qoi_decode failed")
+	}
+	defer C.free(p)
+	w, h, ch, colorspace = int(desc.width), int(desc.height), int(desc.channels), int(desc.colorspace)
+	if channels == 0 {
+		channels = ch
+	}
+	return C.GoBytes(p, C.int(w*h*channels)), w, h, ch, colorspace, nil
+}
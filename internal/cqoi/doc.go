@@ -0,0 +1,11 @@
+// Package cqoi wraps qoi.h, the reference QOI implementation in C, for
+// differential tests of package qoi.
+//
+// The wrapper is built only with cgo and the qoih build tag, and needs
+// qoi.h in this directory, which go generate downloads:
+//
+//	go generate ./internal/cqoi
+//	go test -tags qoih .
+package cqoi
+
+//go:generate curl -sSfLO https://raw.githubusercontent.com/phoboslab/qoi/master/qoi.h
@@ -0,0 +1,136 @@
+// Package naive is a deliberately simple QOI decoder, written to follow the
+// specification as literally as possible rather than to be fast. Package
+// qoi's tests compare its optimized decoders against this one on arbitrary
+// inputs.
+package naive
+
+import "errors"
+
+// Errors returned by Decode.
+var (
+	ErrHeader    = errors.New("naive: invalid header")
+	ErrTruncated = errors.New("naive: truncated")
+	ErrRun       = errors.New("naive: run extends past the last pixel")
+	ErrEnd       = errors.New("naive: missing end marker")
+)
+
+// An Image is a decoded QOI image. Pix holds the pixels as RGBA bytes in
+// row order, four per pixel whatever the header's channel count.
+type Image struct {
+	Width, Height int
+	Channels      uint8
+	ColorSpace    uint8
+	Pix           []byte
+}
+
+type pixel struct{ r, g, b, a uint8 }
+
+func hash(p pixel) int {
+	return (int(p.r)*3 + int(p.g)*5 + int(p.b)*7 + int(p.a)*11) % 64
+}
+
+// maxPixels bounds the size of images Decode accepts.
+const maxPixels = 1 << 30
+
+// Decode decodes the QOI image at the start of data. Unless strict is set,
+// it tolerates a run that continues past the last pixel and any 8 bytes in
+// place of the end marker, as most decoders do. Data after the end marker is
+// ignored.
+func Decode(data []byte, strict bool) (*Image, error) {
+	if len(data) < 14 || string(data[:4]) != "qoif" {
+		return nil, ErrHeader
+	}
+	be32 := func(b []byte) int {
+		return int(b[0])<<24 | int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+	}
+	m := &Image{
+		Width:      be32(data[4:]),
+		Height:     be32(data[8:]),
+		Channels:   data[12],
+		ColorSpace: data[13],
+	}
+	if m.Width == 0 || m.Height == 0 || m.Channels != 3 && m.Channels != 4 || m.ColorSpace > 1 ||
+		uint64(m.Width)*uint64(m.Height) > maxPixels {
+		return nil, ErrHeader
+	}
+	n := m.Width * m.Height
+
+	// Read the chunks into a list of pixels, then copy them out.
+	var index [64]pixel
+	prev := pixel{0, 0, 0, 255}
+	var pixels []pixel
+	pos := 14
+	read := func() (uint8, bool) {
+		if pos >= len(data) {
+			return 0, false
+		}
+		pos++
+		return data[pos-1], true
+	}
+	for len(pixels) < n {
+		tag, ok := read()
+		if !ok {
+			return nil, ErrTruncated
+		}
+		p := prev
+		count := 1
+		switch {
+		case tag == 0xfe:
+			r, ok1 := read()
+			g, ok2 := read()
+			b, ok3 := read()
+			if !ok1 || !ok2 || !ok3 {
+				return nil, ErrTruncated
+			}
+			p.r, p.g, p.b = r, g, b
+		case tag == 0xff:
+			r, ok1 := read()
+			g, ok2 := read()
+			b, ok3 := read()
+			a, ok4 := read()
+			if !ok1 || !ok2 || !ok3 || !ok4 {
+				return nil, ErrTruncated
+			}
+			p = pixel{r, g, b, a}
+		case tag>>6 == 0:
+			p = index[tag]
+		case tag>>6 == 1:
+			p.r += (tag>>4)&3 - 2
+			p.g += (tag>>2)&3 - 2
+			p.b += tag&3 - 2
+		case tag>>6 == 2:
+			b2, ok := read()
+			if !ok {
+				return nil, ErrTruncated
+			}
+			dg := tag&0x3f - 32
+			p.r += dg + b2>>4 - 8
+			p.g += dg
+			p.b += dg + b2&0x0f - 8
+		default:
+			count = int(tag&0x3f) + 1
+		}
+		index[hash(p)] = p
+		prev = p
+		for range count {
+			pixels = append(pixels, p)
+		}
+	}
+	if len(pixels) > n {
+		if strict {
+			return nil, ErrRun
+		}
+		pixels = pixels[:n]
+	}
+	if len(data)-pos < 8 {
+		return nil, ErrTruncated
+	}
+	if strict && string(data[pos:pos+8]) != "\x00\x00\x00\x00\x00\x00\x00\x01" {
+		return nil, ErrEnd
+	}
+
+	for _, p := range pixels {
+		m.Pix = append(m.Pix, p.r, p.g, p.b, p.a)
+	}
+	return m, nil
+}
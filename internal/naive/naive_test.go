@@ -0,0 +1,61 @@
+package naive
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// stream is a 3×2 image using every chunk type.
+var stream = []byte{
+	'q', 'o', 'i', 'f', 0, 0, 0, 3, 0, 0, 0, 2, 4, 0,
+	0xfe, 10, 20, 30, // RGB
+	0x40 | 3<<4 | 1<<2 | 2, // DIFF +1 -1 0
+	0xff, 1, 2, 3, 4,       // RGBA
+	0x80 | 34, 0x9a, // LUMA dg +2, dr-dg +1, db-dg +2
+	0x00 | 9, // INDEX of the first pixel, hash (30+100+210+2805)%64
+	0xc0,     // RUN of 1
+	0, 0, 0, 0, 0, 0, 0, 1,
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(stream, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		10, 20, 30, 255, 11, 19, 30, 255, 1, 2, 3, 4,
+		4, 4, 7, 4, 10, 20, 30, 255, 10, 20, 30, 255,
+	}
+	if m.Width != 3 || m.Height != 2 || m.Channels != 4 || !bytes.Equal(m.Pix, want) {
+		t.Errorf("got %+v, want pixels %v", m, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	overlong := append(bytes.Clone(stream[:len(stream)-9]), 0xc1, 0, 0, 0, 0, 0, 0, 0, 1)
+	badEnd := bytes.Clone(stream)
+	badEnd[len(badEnd)-1] = 2
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		strict bool
+		want   error
+	}{
+		{"magic", append([]byte("qoix"), stream[4:]...), false, ErrHeader},
+		{"short", stream[:20], false, ErrTruncated},
+		{"no end", stream[:len(stream)-1], false, ErrTruncated},
+		{"run", overlong, true, ErrRun},
+		{"end marker", badEnd, true, ErrEnd},
+	} {
+		if _, err := Decode(tc.data, tc.strict); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if _, err := Decode(overlong, false); err != nil {
+		t.Errorf("overlong run, not strict: %v", err)
+	}
+	if _, err := Decode(badEnd, false); err != nil {
+		t.Errorf("bad end marker, not strict: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package qoi
+
+import (
+	"errors"
+	"image/color"
+	"io"
+)
+
+// PixelLayout is the byte order of raw pixels written by DecodeToLayout.
+type PixelLayout uint8
+
+// Pixel layouts. Channels are non-premultiplied and 8 bits each.
+const (
+	LayoutRGBA PixelLayout = iota
+	LayoutBGRA
+	LayoutRGB
+	LayoutBGR
+)
+
+// bytesPerPixel returns the size of a pixel in layout l, or 0 if l is not a
+// known layout.
+func (l PixelLayout) bytesPerPixel() int {
+	switch l {
+	case LayoutRGBA, LayoutBGRA:
+		return 4
+	case LayoutRGB, LayoutBGR:
+		return 3
+	}
+	return 0
+}
+
+// put stores c at the start of b in layout l.
+func (l PixelLayout) put(b []byte, c color.NRGBA) {
+	switch l {
+	case LayoutRGBA:
+		b[0], b[1], b[2], b[3] = c.R, c.G, c.B, c.A
+	case LayoutBGRA:
+		b[0], b[1], b[2], b[3] = c.B, c.G, c.R, c.A
+	case LayoutRGB:
+		b[0], b[1], b[2] = c.R, c.G, c.B
+	case LayoutBGR:
+		b[0], b[1], b[2] = c.B, c.G, c.R
+	}
+}
+
+// DecodeToLayout reads a QOI image from r and writes its pixels to w as raw
+// bytes in the given layout, top row first with no padding between rows. It
+// buffers a single row at a time. Once the header has been read, the returned
+// Header describes the image even if decoding fails later.
+func DecodeToLayout(r io.Reader, w io.Writer, layout PixelLayout) (Header, error) {
+	return Limits{}.DecodeToLayout(r, w, layout)
+}
+
+// DecodeToLayout is like the package-level DecodeToLayout. An image larger
+// than the limits is rejected before any of it is written to w, and its
+// Header is returned with the error.
+func (l Limits) DecodeToLayout(r io.Reader, w io.Writer, layout PixelLayout) (Header, error) {
+	bpp := layout.bytesPerPixel()
+	if bpp == 0 {
+		return Header{}, &kindError{ErrUnsupported, errors.New("qoi: unknown pixel layout")}
+	}
+
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, err
+	}
+	if err := l.check(d.hdr); err != nil {
+		return d.hdr, err
+	}
+
+	row := make([]byte, d.hdr.Width*bpp)
+	for y := 0; y < d.hdr.Height; y++ {
+		for x := 0; x < d.hdr.Width; x++ {
+			if err := d.advance(); err != nil {
+				return d.hdr, err
+			}
+			layout.put(row[x*bpp:], d.px)
+		}
+		if _, err := w.Write(row); err != nil {
+			return d.hdr, err
+		}
+	}
+	return d.hdr, d.readEnd()
+}
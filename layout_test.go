@@ -0,0 +1,48 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestDecodeToLayout(t *testing.T) {
+	src := testImage(37, 21)
+	data := encodeBytes(t, src, &Encoder{ColorSpace: Linear})
+	m, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pix := m.(*image.NRGBA).Pix
+
+	tests := []struct {
+		layout PixelLayout
+		order  []int // indices into each NRGBA pixel
+	}{
+		{LayoutRGBA, []int{0, 1, 2, 3}},
+		{LayoutBGRA, []int{2, 1, 0, 3}},
+		{LayoutRGB, []int{0, 1, 2}},
+		{LayoutBGR, []int{2, 1, 0}},
+	}
+	for _, tt := range tests {
+		var want []byte
+		for i := 0; i < len(pix); i += 4 {
+			for _, j := range tt.order {
+				want = append(want, pix[i+j])
+			}
+		}
+
+		var got bytes.Buffer
+		h, err := DecodeToLayout(bytes.NewReader(data), &got, tt.layout)
+		if err != nil {
+			t.Fatalf("layout %d: %v", tt.layout, err)
+		}
+		wantHdr := Header{Width: 37, Height: 21, Channels: RGBA, ColorSpace: Linear}
+		if h != wantHdr {
+			t.Errorf("layout %d: header: got %+v, want %+v", tt.layout, h, wantHdr)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("layout %d: pixels differ from reordered Decode output", tt.layout)
+		}
+	}
+}
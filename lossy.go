@@ -0,0 +1,256 @@
+package qoi
+
+import (
+	"image/color"
+	"math"
+	"slices"
+)
+
+// optimizeLookahead is how many pixels Encoder.Optimize looks ahead.
+const optimizeLookahead = 8
+
+// writeLossy emits the chunks for pix, which holds non-premultiplied RGBA
+// bytes, letting each decoded sample differ from the source by up to
+// e.enc.Tolerance. e.prev and e.index always hold decoded values, so errors
+// never accumulate from pixel to pixel.
+func (e *encoder) writeLossy(pix []byte) {
+	tol := e.enc.Tolerance
+	var dec []byte
+	if e.checksum {
+		// The checksum covers the pixels as they will be decoded.
+		if cap(e.dec) < len(pix) {
+			e.dec = make([]byte, len(pix))
+		}
+		dec = e.dec[:len(pix)]
+	}
+	for i := 0; i < len(pix); i += 4 {
+		c := color.NRGBA{pix[i], pix[i+1], pix[i+2], pix[i+3]}
+		if e.channels == RGB {
+			c.A = 0xff
+		}
+		switch {
+		case e.literal:
+			e.emit(c, hash(c))
+		case e.enc.Optimize:
+			if x := e.choose(pix[i:], tol); x == e.prev {
+				e.extendRun()
+			} else {
+				e.emit(x, hash(x))
+			}
+		case near(c, e.prev, tol):
+			e.extendRun()
+		default:
+			a := approx(&e.index, e.prev, c, tol)
+			e.emit(a, hash(a))
+		}
+		if dec != nil {
+			d := dec[i : i+4 : i+4]
+			d[0], d[1], d[2], d[3] = e.prev.R, e.prev.G, e.prev.B, e.prev.A
+		}
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+	if dec != nil {
+		e.crc = updateCRC(e.crc, dec, e.channels, &e.sumBuf)
+	}
+}
+
+// approx returns a color within tol of c, which differs from the previous
+// pixel p by more than tol, that is as cheap as possible to encode given the
+// color index.
+func approx(index *[64]color.NRGBA, p, c color.NRGBA, tol uint8) color.NRGBA {
+	if i := hash(c); near(index[i], c, tol) {
+		return index[i]
+	}
+	for _, x := range index {
+		if near(x, c, tol) {
+			return x
+		}
+	}
+	if absDiff(c.A, p.A) > tol {
+		return c
+	}
+
+	// Try the differences a QOI_OP_DIFF or QOI_OP_LUMA chunk can hold
+	// that are nearest to the actual ones.
+	dr := int(int8(c.R - p.R))
+	dg := int(int8(c.G - p.G))
+	db := int(int8(c.B - p.B))
+	x := color.NRGBA{p.R + uint8(clamp(dr, -2, 1)), p.G + uint8(clamp(dg, -2, 1)), p.B + uint8(clamp(db, -2, 1)), p.A}
+	if near(x, c, tol) {
+		return x
+	}
+	g := clamp(dg, -32, 31)
+	x = color.NRGBA{p.R + uint8(clamp(dr, g-8, g+7)), p.G + uint8(g), p.B + uint8(clamp(db, g-8, g+7)), p.A}
+	if near(x, c, tol) {
+		return x
+	}
+	// An RGB chunk, keeping the previous alpha.
+	return color.NRGBA{c.R, c.G, c.B, p.A}
+}
+
+// choose returns the color the first pixel of pix, which holds
+// non-premultiplied RGBA bytes, should decode to. It tries each color within
+// tol that is cheap to encode, following it with the greedy choices for the
+// next few pixels, and returns the one that takes the fewest bytes.
+func (e *encoder) choose(pix []byte, tol uint8) color.NRGBA {
+	s := lossyState{prev: e.prev, index: e.index, run: e.run}
+	c := e.pixelAt(pix, 0)
+	e.cands = s.candidates(e.cands[:0], c, tol)
+	end := min(len(pix), 4*(optimizeLookahead+1))
+	best, bestN := c, math.MaxInt
+	for _, x := range e.cands {
+		t := s
+		n := t.put(x)
+		for j := 4; j < end && n < bestN; j += 4 {
+			n += t.put(t.greedy(e.pixelAt(pix, j), tol))
+		}
+		if n < bestN {
+			best, bestN = x, n
+		}
+	}
+	return best
+}
+
+// pixelAt returns the pixel at offset i of pix as it would be encoded.
+func (e *encoder) pixelAt(pix []byte, i int) color.NRGBA {
+	c := color.NRGBA{pix[i], pix[i+1], pix[i+2], pix[i+3]}
+	if e.channels == RGB {
+		c.A = 0xff
+	}
+	return c
+}
+
+// lossyState is the part of the encoder's state that decides how many bytes
+// later pixels take, tracked by Encoder.Optimize as it tries alternatives.
+type lossyState struct {
+	prev  color.NRGBA
+	index [64]color.NRGBA
+	run   int
+}
+
+// candidates appends to dst the colors within tol of c that may be worth
+// encoding in place of c, starting with the cheapest.
+func (s *lossyState) candidates(dst []color.NRGBA, c color.NRGBA, tol uint8) []color.NRGBA {
+	p := s.prev
+	if near(p, c, tol) {
+		dst = append(dst, p)
+	}
+	for _, x := range s.index {
+		if x != p && near(x, c, tol) && !slices.Contains(dst, x) {
+			dst = append(dst, x)
+		}
+	}
+	dr := int(int8(c.R - p.R))
+	dg := int(int8(c.G - p.G))
+	db := int(int8(c.B - p.B))
+	if absDiff(c.A, p.A) <= tol {
+		x := color.NRGBA{p.R + uint8(clamp(dr, -2, 1)), p.G + uint8(clamp(dg, -2, 1)), p.B + uint8(clamp(db, -2, 1)), p.A}
+		if near(x, c, tol) {
+			dst = append(dst, x)
+		}
+		g := clamp(dg, -32, 31)
+		x = color.NRGBA{p.R + uint8(clamp(dr, g-8, g+7)), p.G + uint8(g), p.B + uint8(clamp(db, g-8, g+7)), p.A}
+		if near(x, c, tol) {
+			dst = append(dst, x)
+		}
+		dst = append(dst, color.NRGBA{c.R, c.G, c.B, p.A})
+	}
+	return append(dst, c)
+}
+
+// greedy returns the color writeLossy would encode for c without
+// Encoder.Optimize.
+func (s *lossyState) greedy(c color.NRGBA, tol uint8) color.NRGBA {
+	if near(c, s.prev, tol) {
+		return s.prev
+	}
+	return approx(&s.index, s.prev, c, tol)
+}
+
+// put updates s as if a pixel decoding to x had been encoded and returns
+// the number of bytes that took, counting a run's byte at its first pixel.
+func (s *lossyState) put(x color.NRGBA) int {
+	if x == s.prev {
+		n := 0
+		if s.run == 0 {
+			n = 1
+		}
+		if s.run++; s.run == maxRun {
+			s.run = 0
+		}
+		return n
+	}
+	s.run = 0
+	i := hash(x)
+	n := chunkSize(s.index[i], s.prev, x)
+	s.index[i], s.prev = x, x
+	return n
+}
+
+// chunkSize returns the size of the chunk emit writes for a pixel c that
+// differs from the previous pixel p, where x is the index entry for c.
+func chunkSize(x, p, c color.NRGBA) int {
+	if x == c {
+		return 1
+	}
+	if c.A != p.A {
+		return 5
+	}
+	dr, dg, db := int8(c.R-p.R), int8(c.G-p.G), int8(c.B-p.B)
+	drg, dbg := dr-dg, db-dg
+	switch {
+	case -2 <= dr && dr <= 1 && -2 <= dg && dg <= 1 && -2 <= db && db <= 1:
+		return 1
+	case -32 <= dg && dg <= 31 && -8 <= drg && drg <= 7 && -8 <= dbg && dbg <= 7:
+		return 2
+	}
+	return 4
+}
+
+// near reports whether each sample of a is within tol of b's.
+func near(a, b color.NRGBA, tol uint8) bool {
+	return absDiff(a.R, b.R) <= tol && absDiff(a.G, b.G) <= tol &&
+		absDiff(a.B, b.B) <= tol && absDiff(a.A, b.A) <= tol
+}
+
+func absDiff(a, b uint8) uint8 {
+	return max(a, b) - min(a, b)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// alphaTable returns a table that rounds alpha values to n evenly spaced
+// levels.
+func alphaTable(n int) *[256]uint8 {
+	var t [256]uint8
+	for a := range t {
+		q := (a*(n-1) + 0x7f) / 0xff
+		t[a] = uint8((q*0xff + (n-1)/2) / (n - 1))
+	}
+	return &t
+}
+
+// quantizeAlpha copies the NRGBA pixels in src to dst, mapping alpha
+// through t. dst and src may be the same slice.
+func quantizeAlpha(dst, src []byte, t *[256]uint8) {
+	copy(dst, src)
+	for i := 3; i < len(dst); i += 4 {
+		dst[i] = t[dst[i]]
+	}
+}
+
+// zeroTransparent copies the NRGBA pixels in src to dst, replacing fully
+// transparent ones with transparent black. dst and src may be the same
+// slice.
+func zeroTransparent(dst, src []byte) {
+	copy(dst, src)
+	for i := 0; i < len(dst); i += 4 {
+		if dst[i+3] == 0 {
+			dst[i], dst[i+1], dst[i+2] = 0, 0, 0
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// gradient returns a screenshot-like image of slight gradients.
+func gradient(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x / 3), uint8(200 + y/7), uint8(x*y/50 + x%3), 0xff - uint8(x%2)})
+		}
+	}
+	return m
+}
+
+func TestTolerance(t *testing.T) {
+	m := gradient(120, 80)
+	lossless := len(encodeBytes(t, m, nil))
+	for _, tc := range []struct {
+		tol uint8
+		enc Encoder
+	}{
+		{1, Encoder{}},
+		{4, Encoder{}},
+		{4, Encoder{Channels: RGB}},
+		{16, Encoder{RestartInterval: 9}},
+		{255, Encoder{}},
+	} {
+		tc.enc.Tolerance = tc.tol
+		tc.enc.Checksum = true
+		data := encodeBytes(t, m, &tc.enc)
+		if len(data) >= lossless {
+			t.Errorf("tolerance %d: %d bytes, want less than %d", tc.tol, len(data), lossless)
+		}
+		got, err := (&DecodeOptions{VerifyChecksum: true}).Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("tolerance %d: %v", tc.tol, err)
+		}
+		g := got.(*image.NRGBA)
+		for i, v := range g.Pix {
+			want := m.Pix[i]
+			if tc.enc.Channels == RGB && i%4 == 3 {
+				want = 0xff
+			}
+			if absDiff(v, want) > tc.tol {
+				t.Fatalf("tolerance %d: sample %d is %d, want within %d of %d", tc.tol, i, v, tc.tol, want)
+			}
+		}
+		if tc.enc.RestartInterval > 0 {
+			par, err := DecodeParallel(data, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			samePixels(t, par, got)
+		}
+		if err := Validate(bytes.NewReader(data)); err != nil {
+			t.Errorf("tolerance %d: %v", tc.tol, err)
+		}
+	}
+}
+
+func TestToleranceZeroIsLossless(t *testing.T) {
+	m := gradient(30, 20)
+	if !bytes.Equal(encodeBytes(t, m, &Encoder{Tolerance: 0}), encodeBytes(t, m, nil)) {
+		t.Error("zero tolerance changed the encoding")
+	}
+}
+
+func TestAlphaLevels(t *testing.T) {
+	// Anti-aliased edges: alpha ramps in every row.
+	m := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for y := range 32 {
+		for x := range 64 {
+			m.SetNRGBA(x, y, color.NRGBA{0x20, 0x40, 0x80, uint8(x*4 + y)})
+		}
+	}
+	lossless := len(encodeBytes(t, m, nil))
+	for _, n := range []int{2, 16, 100} {
+		enc := &Encoder{AlphaLevels: n, Checksum: true}
+		data := encodeBytes(t, m, enc)
+		if len(data) >= lossless {
+			t.Errorf("%d levels: %d bytes, want less than %d", n, len(data), lossless)
+		}
+		got, err := (&DecodeOptions{VerifyChecksum: true}).Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		levels := make(map[uint8]bool)
+		g := got.(*image.NRGBA)
+		for i := 0; i < len(g.Pix); i += 4 {
+			a, want := g.Pix[i+3], m.Pix[i+3]
+			levels[a] = true
+			if int(absDiff(a, want)) > (0xff/(n-1)+1)/2 {
+				t.Fatalf("%d levels: alpha %d for %d", n, a, want)
+			}
+			if !bytes.Equal(g.Pix[i:i+3], m.Pix[i:i+3]) {
+				t.Fatalf("%d levels: color channels changed", n)
+			}
+		}
+		if len(levels) > n {
+			t.Errorf("%d levels: decoded %d alpha values", n, len(levels))
+		}
+	}
+
+	tbl := alphaTable(16)
+	if tbl[0] != 0 || tbl[0xff] != 0xff {
+		t.Errorf("16 levels: endpoints map to %d and %d", tbl[0], tbl[0xff])
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	for _, m := range []*image.NRGBA{gradient(120, 80), testImage(64, 48)} {
+		for _, tol := range []uint8{2, 8, 32} {
+			greedy := len(encodeBytes(t, m, &Encoder{Tolerance: tol}))
+			var stats EncodeStats
+			enc := &Encoder{
+				Tolerance: tol,
+				Optimize:  true,
+				Checksum:  true,
+				Stats:     func(s *EncodeStats) { stats = *s },
+			}
+			data := encodeBytes(t, m, enc)
+			// The checksum block takes 16 bytes.
+			if n := len(data) - 16; n > greedy {
+				t.Errorf("tolerance %d: %d bytes, want at most %d", tol, n, greedy)
+			}
+			if stats.OutputSize != int64(len(data)) {
+				t.Errorf("tolerance %d: Stats.OutputSize = %d, want %d", tol, stats.OutputSize, len(data))
+			}
+			got, err := (&DecodeOptions{VerifyChecksum: true}).Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("tolerance %d: %v", tol, err)
+			}
+			for i, v := range got.(*image.NRGBA).Pix {
+				if absDiff(v, m.Pix[i]) > tol {
+					t.Fatalf("tolerance %d: sample %d is %d, want within %d of %d", tol, i, v, tol, m.Pix[i])
+				}
+			}
+		}
+	}
+
+	m := gradient(30, 20)
+	if !bytes.Equal(encodeBytes(t, m, &Encoder{Optimize: true}), encodeBytes(t, m, nil)) {
+		t.Error("Optimize changed a lossless encoding")
+	}
+}
+
+func TestZeroTransparent(t *testing.T) {
+	// A sprite on a transparent background that still holds noise.
+	m := testImage(64, 48)
+	for i := 0; i < len(m.Pix); i += 4 {
+		if x := i / 4 % 64; x < 16 || x >= 48 {
+			m.Pix[i+3] = 0
+		}
+	}
+	plain := encodeBytes(t, m, nil)
+	enc := &Encoder{ZeroTransparent: true}
+	data := encodeBytes(t, m, enc)
+	if len(data) >= len(plain) {
+		t.Errorf("%d bytes, want less than %d", len(data), len(plain))
+	}
+	got, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := got.(*image.NRGBA)
+	for i := 0; i < len(g.Pix); i += 4 {
+		want := m.Pix[i : i+4]
+		if want[3] == 0 {
+			want = []byte{0, 0, 0, 0}
+		}
+		if !bytes.Equal(g.Pix[i:i+4], want) {
+			t.Fatalf("pixel %d is %v, want %v", i/4, g.Pix[i:i+4], want)
+		}
+	}
+
+	// Palette entries are cleared the same way.
+	p := image.NewPaletted(image.Rect(0, 0, 8, 2), color.Palette{
+		color.NRGBA{0xff, 0, 0, 0},
+		color.NRGBA{0, 0xff, 0, 0},
+		color.NRGBA{0, 0, 0xff, 0xff},
+	})
+	for i := range p.Pix {
+		p.Pix[i] = uint8(i % 3)
+	}
+	n := image.NewNRGBA(p.Rect)
+	draw.Draw(n, n.Rect, p, image.Point{}, draw.Src)
+	if !bytes.Equal(encodeBytes(t, p, enc), encodeBytes(t, n, enc)) {
+		t.Error("Paletted and NRGBA sources encode differently")
+	}
+
+	rgb := &Encoder{Channels: RGB}
+	if !bytes.Equal(encodeBytes(t, m, &Encoder{Channels: RGB, ZeroTransparent: true}), encodeBytes(t, m, rgb)) {
+		t.Error("ZeroTransparent changed an RGB encoding")
+	}
+}
@@ -0,0 +1,252 @@
+package qoi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Metadata is stored in extension blocks after the end marker, where other
+// decoders ignore it. Each block is:
+//
+//	magic  "qoie"
+//	type   [4]byte
+//	length uint32   length n of the data, big-endian
+//	data   [n]byte
+//
+// A "text" block holds one key-value pair as the key, a zero byte, and the
+// value, an "iccp" block holds an ICC profile, an "exif" block holds EXIF
+// data, and a "csum" block holds the checksum Encoder.Checksum adds. Blocks
+// of unknown types are skipped. Any restart points follow the last block.
+const (
+	blockMagic  = "qoie"
+	blockHdrLen = 12
+)
+
+// Metadata holds the optional data stored alongside an image. Since it
+// follows the end marker, an image with metadata must be the last thing in
+// its stream; Decoder and DecodeAll do not expect it between concatenated
+// images.
+//
+// To keep metadata when re-encoding an image, pass what DecodeMetadata
+// returns to Encoder.Metadata.
+type Metadata struct {
+	// Text holds key-value pairs such as capture times, sources and tags.
+	// Keys must be non-empty and must not contain zero bytes.
+	Text map[string]string
+
+	// ICCProfile is an embedded ICC color profile, as in PNG's iCCP chunk,
+	// describing the colorspace of the samples more precisely than the
+	// header can.
+	ICCProfile []byte
+
+	// EXIF is opaque EXIF data, in the TIFF format that follows the
+	// "Exif\x00\x00" identifier in a JPEG APP1 segment, as in PNG's eXIf
+	// chunk. JPEGEXIF and AddJPEGEXIF carry it to and from JPEG files.
+	EXIF []byte
+}
+
+// check reports whether md can be encoded.
+func (md *Metadata) check() error {
+	if md == nil {
+		return nil
+	}
+	for k, v := range md.Text {
+		if k == "" || strings.IndexByte(k, 0) >= 0 {
+			return errors.New("qoi: invalid metadata key " + strconv.Quote(k))
+		}
+		if uint64(len(k)+1+len(v)) >= 1<<32 {
+			return errors.New("qoi: metadata value too long")
+		}
+	}
+	if uint64(len(md.ICCProfile)) >= 1<<32 {
+		return errors.New("qoi: ICC profile too long")
+	}
+	if uint64(len(md.EXIF)) >= 1<<32 {
+		return errors.New("qoi: EXIF data too long")
+	}
+	return nil
+}
+
+// appendBlock appends an extension block of type typ to b.
+func appendBlock(b []byte, typ string, data ...string) []byte {
+	var n int
+	for _, s := range data {
+		n += len(s)
+	}
+	b = append(b, blockMagic...)
+	b = append(b, typ...)
+	b = binary.BigEndian.AppendUint32(b, uint32(n))
+	for _, s := range data {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// encodedLen returns the size of the extension blocks for md.
+func (md *Metadata) encodedLen() uint64 {
+	var n uint64
+	if md.ICCProfile != nil {
+		n += blockHdrLen + uint64(len(md.ICCProfile))
+	}
+	if md.EXIF != nil {
+		n += blockHdrLen + uint64(len(md.EXIF))
+	}
+	for k, v := range md.Text {
+		n += blockHdrLen + uint64(len(k)+1+len(v))
+	}
+	return n
+}
+
+// writeMetadata appends the extension blocks for md to e.buf.
+func (e *encoder) writeMetadata(md *Metadata) {
+	if md.ICCProfile != nil {
+		e.buf = appendBlock(e.buf, "iccp", string(md.ICCProfile))
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+	if md.EXIF != nil {
+		e.buf = appendBlock(e.buf, "exif", string(md.EXIF))
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+	// Sort the keys so that equal metadata is encoded identically.
+	keys := make([]string, 0, len(md.Text))
+	for k := range md.Text {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		e.buf = appendBlock(e.buf, "text", k, "\x00", md.Text[k])
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+}
+
+// readMetadata reads the extension blocks that follow the end marker until
+// r ends or holds something else, such as restart points.
+func (d *decoder) readMetadata() (*Metadata, error) {
+	md := &Metadata{}
+	d.hasSum = false
+	for {
+		start := d.n
+		if err := d.readFull(d.tmp[:blockHdrLen]); err != nil {
+			if err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			if d.n-start >= 4 && string(d.tmp[:4]) == blockMagic {
+				return nil, io.ErrUnexpectedEOF
+			}
+			// The blocks end with the input.
+			return md, nil
+		}
+		if string(d.tmp[:4]) != blockMagic {
+			return md, nil
+		}
+		typ := string(d.tmp[4:8])
+		n := int64(binary.BigEndian.Uint32(d.tmp[8:12]))
+		// Read through a limit rather than allocating n bytes up front, in
+		// case the input is shorter than the block claims.
+		var buf bytes.Buffer
+		k, err := buf.ReadFrom(io.LimitReader(d.r, n))
+		d.n += k
+		if err != nil {
+			return nil, err
+		}
+		if k < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		switch data := buf.Bytes(); typ {
+		case "text":
+			key, value, ok := bytes.Cut(data, []byte{0})
+			if !ok || len(key) == 0 {
+				return nil, FormatError("invalid text block")
+			}
+			if md.Text == nil {
+				md.Text = make(map[string]string)
+			}
+			md.Text[string(key)] = string(value)
+		case "iccp":
+			md.ICCProfile = data
+		case "exif":
+			md.EXIF = data
+		case "csum":
+			if len(data) != 4 {
+				return nil, FormatError("invalid checksum block")
+			}
+			d.sum, d.hasSum = binary.BigEndian.Uint32(data), true
+		}
+	}
+}
+
+// DecodeMetadata reads a QOI image from r, like Decode, and then reads the
+// metadata stored after it, consuming the rest of r.
+func DecodeMetadata(r io.Reader) (image.Image, *Metadata, error) {
+	var o DecodeOptions
+	return o.DecodeMetadata(r)
+}
+
+// DecodeMetadata is like the package-level DecodeMetadata, but decodes the
+// image with the options in o.
+func (o *DecodeOptions) DecodeMetadata(r io.Reader) (image.Image, *Metadata, error) {
+	d := newDecoder(r)
+	d.strict = o.Strict
+	m, err := d.decode(o)
+	if err != nil {
+		return m, nil, err
+	}
+	if d.md != nil {
+		// Verifying the checksum has read the blocks already.
+		return m, d.md, nil
+	}
+	md, err := d.readMetadata()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, md, nil
+}
+
+// ReadMetadata reads the metadata stored after the QOI image in r, without
+// storing the image's pixels.
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	_, md, err := readHeaderMetadata(r)
+	return md, err
+}
+
+// DecodeICCProfile reads the header of the QOI image in r along with its
+// embedded ICC profile, or nil if it has none. Unlike DecodeHeader, it must
+// read through the image's chunks to reach the profile, though it does not
+// store the pixels.
+func DecodeICCProfile(r io.Reader) (Header, []byte, error) {
+	h, md, err := readHeaderMetadata(r)
+	if err != nil {
+		return h, nil, err
+	}
+	return h, md.ICCProfile, nil
+}
+
+// readHeaderMetadata reads the header and metadata of the image in r,
+// skipping its pixels. Once the header has been read, it is returned even
+// if reading fails later.
+func readHeaderMetadata(r io.Reader) (Header, *Metadata, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, nil, err
+	}
+	if err := d.skip(int64(d.hdr.Width) * int64(d.hdr.Height)); err != nil {
+		return d.hdr, nil, err
+	}
+	if err := d.readEnd(); err != nil {
+		return d.hdr, nil, err
+	}
+	md, err := d.readMetadata()
+	return d.hdr, md, err
+}
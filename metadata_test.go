@@ -0,0 +1,172 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	m := testImage(12, 9)
+	md := &Metadata{Text: map[string]string{
+		"time":   "2024-05-01T12:00:00Z",
+		"source": "camera 2",
+		"empty":  "",
+		"binary": "\x00\xff",
+	}}
+	for _, enc := range []*Encoder{
+		{Metadata: md},
+		{Metadata: md, RestartInterval: 4},
+	} {
+		data := encodeBytes(t, m, enc)
+
+		got, gotMD, err := DecodeMetadata(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, got, m)
+		if !maps.Equal(gotMD.Text, md.Text) {
+			t.Errorf("got text %q, want %q", gotMD.Text, md.Text)
+		}
+
+		gotMD, err = ReadMetadata(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(gotMD.Text, md.Text) {
+			t.Errorf("ReadMetadata: got text %q, want %q", gotMD.Text, md.Text)
+		}
+
+		// Other decoders, and DecodeParallel, ignore the blocks.
+		if _, err := Decode(bytes.NewReader(data)); err != nil {
+			t.Error(err)
+		}
+		if got, err := DecodeParallel(data, 2); err != nil {
+			t.Error(err)
+		} else {
+			samePixels(t, got, m)
+		}
+
+		// Passing the metadata through re-encodes identically.
+		re := *enc
+		re.Metadata = gotMD
+		if again := encodeBytes(t, got, &re); !bytes.Equal(again, data) {
+			t.Error("re-encoding with the decoded metadata changed the stream")
+		}
+	}
+}
+
+func TestMetadataNone(t *testing.T) {
+	_, md, err := DecodeMetadata(bytes.NewReader(encodeBytes(t, testImage(3, 3), nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Text) != 0 {
+		t.Errorf("got text %q, want none", md.Text)
+	}
+}
+
+func TestMetadataWriter(t *testing.T) {
+	m := testImage(5, 4)
+	md := &Metadata{Text: map[string]string{"k": "v"}}
+	var buf bytes.Buffer
+	qw, err := NewWriter(&buf, 5, 4, &Encoder{Metadata: md})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qw.Write(m.Pix); err != nil {
+		t.Fatal(err)
+	}
+	if err := qw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), encodeBytes(t, m, &Encoder{Metadata: md})) {
+		t.Error("Writer and Encode wrote different streams")
+	}
+}
+
+func TestMetadataInvalid(t *testing.T) {
+	for _, key := range []string{"", "a\x00b"} {
+		enc := &Encoder{Metadata: &Metadata{Text: map[string]string{key: "v"}}}
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, testImage(2, 2)); err == nil {
+			t.Errorf("key %q: Encode succeeded", key)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("key %q: Encode wrote %d bytes", key, buf.Len())
+		}
+	}
+
+	data := encodeBytes(t, testImage(2, 2), &Encoder{Metadata: &Metadata{Text: map[string]string{"key": "value"}}})
+	if _, _, err := DecodeMetadata(bytes.NewReader(data[:len(data)-2])); !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated block: got %v, want ErrTruncated", err)
+	}
+
+	// The "text" block takes len("key\x00value") bytes after its header.
+	blockStart := len(data) - blockHdrLen - 9
+	for _, n := range []int{4, 8, blockHdrLen - 1} {
+		cut := data[:blockStart+n]
+		if _, _, err := DecodeMetadata(bytes.NewReader(cut)); !errors.Is(err, ErrTruncated) {
+			t.Errorf("%d bytes of block header: got %v, want ErrTruncated", n, err)
+		}
+		if _, err := ReadMetadata(bytes.NewReader(cut)); !errors.Is(err, ErrTruncated) {
+			t.Errorf("%d bytes of block header: ReadMetadata got %v, want ErrTruncated", n, err)
+		}
+	}
+}
+
+func TestMetadataChecksum(t *testing.T) {
+	m := testImage(12, 9)
+	md := &Metadata{
+		Text:       map[string]string{"source": "camera 2"},
+		ICCProfile: []byte("profile"),
+		EXIF:       []byte("MM\x00*"),
+	}
+	data := encodeBytes(t, m, &Encoder{Metadata: md, Checksum: true})
+	got, gotMD, err := (&DecodeOptions{VerifyChecksum: true}).DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, m)
+	if !maps.Equal(gotMD.Text, md.Text) {
+		t.Errorf("got text %q, want %q", gotMD.Text, md.Text)
+	}
+	if !bytes.Equal(gotMD.ICCProfile, md.ICCProfile) || !bytes.Equal(gotMD.EXIF, md.EXIF) {
+		t.Errorf("got ICC profile %q and EXIF %q, want %q and %q", gotMD.ICCProfile, gotMD.EXIF, md.ICCProfile, md.EXIF)
+	}
+}
+
+func TestICCProfile(t *testing.T) {
+	m := testImage(6, 4)
+	profile := bytes.Repeat([]byte("icc profile "), 3000)
+	enc := &Encoder{ColorSpace: Linear, Metadata: &Metadata{
+		ICCProfile: profile,
+		Text:       map[string]string{"k": "v"},
+	}}
+	data := encodeBytes(t, m, enc)
+
+	h, got, err := DecodeICCProfile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Header{Width: 6, Height: 4, Channels: RGBA, ColorSpace: Linear}); h != want {
+		t.Errorf("got header %+v, want %+v", h, want)
+	}
+	if !bytes.Equal(got, profile) {
+		t.Errorf("got a %d-byte profile, want %d bytes", len(got), len(profile))
+	}
+
+	_, md, err := DecodeMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(md.ICCProfile, profile) || md.Text["k"] != "v" {
+		t.Error("DecodeMetadata lost the profile or text")
+	}
+
+	_, got, err = DecodeICCProfile(bytes.NewReader(encodeBytes(t, m, nil)))
+	if err != nil || got != nil {
+		t.Errorf("image without a profile: got %d bytes, %v", len(got), err)
+	}
+}
@@ -0,0 +1,48 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"mime"
+)
+
+// MIMEType is the media type of QOI images. It is not registered with
+// IANA, but it is the one QOI tools and browsers that support the format
+// use.
+const MIMEType = "image/qoi"
+
+// Extension is the file name extension of QOI images.
+const Extension = ".qoi"
+
+// IsQOI reports whether prefix, the first bytes of some data, starts like a
+// QOI image: the magic followed by a header with a nonzero size and valid
+// channel count and colorspace. Given fewer than the 14 bytes of the header,
+// it checks as much of it as prefix holds, so 4 bytes are enough for a
+// check of the magic alone. It does not read past the header.
+func IsQOI(prefix []byte) bool {
+	n := min(len(prefix), 4)
+	if n == 0 || string(prefix[:n]) != magic[:n] {
+		return false
+	}
+	if len(prefix) >= 8 && binary.BigEndian.Uint32(prefix[4:]) == 0 ||
+		len(prefix) >= 12 && binary.BigEndian.Uint32(prefix[8:]) == 0 {
+		return false
+	}
+	if len(prefix) > 12 {
+		if c := Channels(prefix[12]); c != RGB && c != RGBA {
+			return false
+		}
+	}
+	if len(prefix) > 13 {
+		if cs := ColorSpace(prefix[13]); cs != SRGB && cs != Linear {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterMIMEType associates Extension with MIMEType in package mime, so
+// that mime.TypeByExtension, and servers such as http.FileServer that use
+// it, label QOI files correctly.
+func RegisterMIMEType() error {
+	return mime.AddExtensionType(Extension, MIMEType)
+}
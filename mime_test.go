@@ -0,0 +1,45 @@
+package qoi
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestIsQOI(t *testing.T) {
+	h := encodeBytes(t, testImage(3, 2), nil)[:headerLen]
+	edit := func(i int, v byte) []byte {
+		b := append([]byte(nil), h...)
+		b[i] = v
+		return b
+	}
+	for _, tc := range []struct {
+		name   string
+		prefix []byte
+		want   bool
+	}{
+		{"header", h, true},
+		{"whole stream", encodeBytes(t, testImage(3, 2), nil), true},
+		{"magic", h[:4], true},
+		{"partial magic", h[:2], true},
+		{"partial header", h[:9], true},
+		{"empty", nil, false},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), false},
+		{"zero width", append(append([]byte("qoif"), 0, 0, 0, 0), h[8:]...), false},
+		{"zero height", edit(11, 0), false},
+		{"channels", edit(12, 5), false},
+		{"colorspace", edit(13, 7), false},
+	} {
+		if got := IsQOI(tc.prefix); got != tc.want {
+			t.Errorf("%s: IsQOI = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRegisterMIMEType(t *testing.T) {
+	if err := RegisterMIMEType(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mime.TypeByExtension(Extension); got != MIMEType {
+		t.Errorf("TypeByExtension(%q) = %q, want %q", Extension, got, MIMEType)
+	}
+}
@@ -0,0 +1,55 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+	"io"
+	"iter"
+)
+
+// EncodeAll writes each image in images to w as a complete QOI stream, one
+// directly after another. If opts is nil, the defaults of Encode are used.
+func EncodeAll(w io.Writer, images []image.Image, opts *Encoder) error {
+	if opts == nil {
+		opts = &Encoder{}
+	}
+	for _, m := range images {
+		if err := opts.Encode(w, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeAll reads QOI images from r until it ends, for streams of
+// concatenated images such as those EncodeAll writes. The input must end
+// exactly after an end marker. On error, DecodeAll returns the images
+// decoded so far.
+func DecodeAll(r io.Reader) ([]image.Image, error) {
+	var images []image.Image
+	for m, err := range DecodeSeq(r) {
+		if err != nil {
+			return images, err
+		}
+		images = append(images, m)
+	}
+	return images, nil
+}
+
+// DecodeSeq returns an iterator over the concatenated QOI images in r,
+// decoding each one only when the loop reaches it. If decoding fails, the
+// iterator yields a nil image and the error, then stops.
+func DecodeSeq(r io.Reader) iter.Seq2[image.Image, error] {
+	return func(yield func(image.Image, error) bool) {
+		dec := NewDecoder(r, nil)
+		for {
+			m, err := dec.Decode()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(m, err) || err != nil {
+				return
+			}
+		}
+	}
+}
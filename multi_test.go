@@ -0,0 +1,82 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestEncodeDecodeAll(t *testing.T) {
+	images := []image.Image{testImage(10, 4), testImage(1, 1), testImage(33, 17)}
+	var buf bytes.Buffer
+	if err := EncodeAll(&buf, images, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	got, err := DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(images) {
+		t.Fatalf("got %d images, want %d", len(got), len(images))
+	}
+	for i := range got {
+		samePixels(t, got[i], images[i])
+	}
+
+	got, err = DecodeAll(bytes.NewReader(nil))
+	if len(got) != 0 || err != nil {
+		t.Errorf("empty input: got %d images, %v", len(got), err)
+	}
+
+	got, err = DecodeAll(bytes.NewReader(data[:len(data)-5]))
+	if len(got) != 2 || !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated input: got %d images, %v", len(got), err)
+	}
+
+	// A partial header after the last image is an error, not the end.
+	got, err = DecodeAll(bytes.NewReader(append(bytes.Clone(data), "qoi"...)))
+	if len(got) != 3 || !errors.Is(err, ErrTruncated) {
+		t.Errorf("trailing bytes: got %d images, %v", len(got), err)
+	}
+}
+
+func TestDecodeSeq(t *testing.T) {
+	images := []image.Image{testImage(10, 4), testImage(7, 7), testImage(3, 9)}
+	var buf bytes.Buffer
+	if err := EncodeAll(&buf, images, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// Stopping early leaves the rest of the stream undecoded.
+	r := bytes.NewReader(data)
+	for m, err := range DecodeSeq(r) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, m, images[0])
+		break
+	}
+	if want := len(data) - len(encodeBytes(t, images[0], nil)); r.Len() != want {
+		t.Errorf("%d bytes left after the first image, want %d", r.Len(), want)
+	}
+
+	var n int
+	for m, err := range DecodeSeq(bytes.NewReader(data[:len(data)-1])) {
+		if n < 2 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			samePixels(t, m, images[n])
+		} else if m != nil || !errors.Is(err, ErrTruncated) {
+			t.Errorf("last image: got %v, %v", m, err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("iterated %d times, want 3", n)
+	}
+}
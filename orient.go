@@ -0,0 +1,103 @@
+package qoi
+
+// Orientation is a transform applied to an image while it is decoded, with
+// the values of the EXIF Orientation tag. Each constant names where the
+// stored image's first row and column end up: OrientRightTop, for example,
+// puts the first row on the right and the first column at the top, which
+// rotates the image 90° clockwise. The zero value means OrientTopLeft.
+type Orientation uint8
+
+// Orientations.
+const (
+	OrientTopLeft     Orientation = 1 // as stored
+	OrientTopRight    Orientation = 2 // mirrored horizontally
+	OrientBottomRight Orientation = 3 // rotated 180°
+	OrientBottomLeft  Orientation = 4 // mirrored vertically
+	OrientLeftTop     Orientation = 5 // transposed
+	OrientRightTop    Orientation = 6 // rotated 90° clockwise
+	OrientRightBottom Orientation = 7 // transversed
+	OrientLeftBottom  Orientation = 8 // rotated 90° counterclockwise
+)
+
+// mirrorVertical maps each orientation o to the one that applies o and
+// then mirrors the result vertically.
+var mirrorVertical = [...]Orientation{0, 4, 3, 2, 1, 8, 7, 6, 5}
+
+// size returns the dimensions of a w×h image after transform t.
+func (t Orientation) size(w, h int) (int, int) {
+	if t >= OrientLeftTop {
+		return h, w
+	}
+	return w, h
+}
+
+// apply returns where transform t moves pixel (x, y) of a w×h image.
+func (t Orientation) apply(x, y, w, h int) (int, int) {
+	switch t {
+	case OrientTopRight:
+		return w - 1 - x, y
+	case OrientBottomRight:
+		return w - 1 - x, h - 1 - y
+	case OrientBottomLeft:
+		return x, h - 1 - y
+	case OrientLeftTop:
+		return y, x
+	case OrientRightTop:
+		return h - 1 - y, x
+	case OrientRightBottom:
+		return h - 1 - y, w - 1 - x
+	case OrientLeftBottom:
+		return y, w - 1 - x
+	}
+	return x, y
+}
+
+// orientation returns the transform Decode applies, which combines
+// Orientation and FlipVertical.
+func (o *DecodeOptions) orientation() Orientation {
+	t := o.Orientation
+	if t == 0 {
+		t = OrientTopLeft
+	}
+	if o.FlipVertical && t <= OrientLeftBottom {
+		t = mirrorVertical[t]
+	}
+	return t
+}
+
+// decodeOriented decodes the pixels and end marker into pix, which holds
+// rows of bpp-byte pixels stride bytes apart, moving each pixel where
+// transform t puts it. For 3-byte pixels, alpha is dropped. post is applied
+// to each row before its pixels are moved, as in decodePixels.
+func (d *decoder) decodeOriented(pix []byte, stride, bpp int, t Orientation, post func(row []byte)) error {
+	w, h := d.hdr.Width, d.hdr.Height
+	row := make([]byte, 4*w)
+	for y := range h {
+		err := d.decodeSpan(row)
+		src := row
+		if err != nil {
+			// Keep the pixels of this row that were decoded.
+			src = row[:4*(d.pixel-int64(y)*int64(w))]
+		}
+		if post != nil {
+			post(src)
+		}
+
+		// Consecutive pixels of a row land a fixed distance apart.
+		x0, y0 := t.apply(0, y, w, h)
+		x1, y1 := t.apply(1, y, w, h)
+		i := y0*stride + x0*bpp
+		step := (y1-y0)*stride + (x1-x0)*bpp
+		for j := 0; j < len(src); j += 4 {
+			copy(pix[i:i+bpp], src[j:j+bpp])
+			i += step
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.rowDone(y); err != nil {
+			return err
+		}
+	}
+	return d.readEnd()
+}
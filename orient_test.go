@@ -0,0 +1,97 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeOrientation(t *testing.T) {
+	const w, h = 7, 4
+	m := testImage(w, h)
+	data := encodeBytes(t, m, nil)
+	rgbData := encodeBytes(t, m, &Encoder{Channels: RGB})
+
+	// source returns the pixel of m that lands at (x, y) under o,
+	// written as the inverse of each transform.
+	source := func(o Orientation, x, y int) color.NRGBA {
+		switch o {
+		case OrientTopRight:
+			x = w - 1 - x
+		case OrientBottomRight:
+			x, y = w-1-x, h-1-y
+		case OrientBottomLeft:
+			y = h - 1 - y
+		case OrientLeftTop:
+			x, y = y, x
+		case OrientRightTop:
+			x, y = y, h-1-x
+		case OrientRightBottom:
+			x, y = w-1-y, h-1-x
+		case OrientLeftBottom:
+			x, y = w-1-y, x
+		}
+		return m.NRGBAAt(x, y)
+	}
+
+	for o := Orientation(0); o <= OrientLeftBottom; o++ {
+		for _, opts := range []DecodeOptions{
+			{Orientation: o},
+			{Orientation: o, Premultiply: true},
+			{Orientation: o, PackRGB: true},
+		} {
+			in := data
+			if opts.PackRGB {
+				in = rgbData
+			}
+			got, err := opts.Decode(bytes.NewReader(in))
+			if err != nil {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+			b := got.Bounds()
+			if wantW, wantH := o.size(w, h); b.Dx() != wantW || b.Dy() != wantH {
+				t.Fatalf("%+v: got bounds %v", opts, b)
+			}
+			for y := range b.Dy() {
+				for x := range b.Dx() {
+					want := source(o, x, y)
+					var c color.Color = want
+					switch {
+					case opts.PackRGB:
+						c = RGBModel.Convert(c)
+					case opts.Premultiply:
+						c = color.RGBAModel.Convert(c)
+					}
+					if g := got.At(x, y); g != c {
+						t.Fatalf("%+v: pixel (%d, %d) = %v, want %v", opts, x, y, g, c)
+					}
+				}
+			}
+		}
+	}
+
+	opts := &DecodeOptions{Orientation: 9}
+	if _, err := opts.Decode(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("invalid orientation: got %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestDecodeOrientationFlip(t *testing.T) {
+	m := testImage(9, 5)
+	data := encodeBytes(t, m, nil)
+	for o := OrientTopLeft; o <= OrientLeftBottom; o++ {
+		plain := &DecodeOptions{Orientation: o}
+		a, err := plain.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		flip := &DecodeOptions{Orientation: o, FlipVertical: true}
+		b, err := flip.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, b, flipped(a.(*image.NRGBA)))
+	}
+}
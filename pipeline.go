@@ -0,0 +1,68 @@
+package qoi
+
+import (
+	"image"
+	"sync"
+)
+
+// rowPipeline converts the rows of an image to NRGBA bytes on several
+// goroutines and hands them back in order, so that conversion overlaps with
+// the sequential work of emitting chunks.
+type rowPipeline struct {
+	jobs  chan int      // positions of rows to convert
+	done  []chan []byte // converted rows, one channel per slot
+	count int           // number of rows
+	next  int           // position of the next row to hand out
+	wg    sync.WaitGroup
+}
+
+// startPipeline starts workers goroutines converting the count rows of m,
+// where the row at position n is rowY(n).
+func startPipeline(m image.Image, count int, rowY func(n int) int, workers int) *rowPipeline {
+	// Each slot owns a row buffer and has at most one job in flight, so
+	// neither sends on jobs nor sends on done ever block.
+	slots := 2 * workers
+	p := &rowPipeline{
+		jobs:  make(chan int, slots),
+		done:  make([]chan []byte, slots),
+		count: count,
+	}
+	bufs := make([][]byte, slots)
+	for i := range slots {
+		p.done[i] = make(chan []byte, 1)
+		bufs[i] = make([]byte, 4*m.Bounds().Dx())
+	}
+	for range workers {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for n := range p.jobs {
+				row := bufs[n%slots]
+				readRow(m, rowY(n), row)
+				p.done[n%slots] <- row
+			}
+		}()
+	}
+	for n := range min(slots, count) {
+		p.jobs <- n
+	}
+	return p
+}
+
+// row returns the next row. It is valid until the following call.
+func (p *rowPipeline) row() []byte {
+	n, slots := p.next, len(p.done)
+	// The caller is done with the previous row, so its slot can take the
+	// next job.
+	if n > 0 && n-1+slots < p.count {
+		p.jobs <- n - 1 + slots
+	}
+	p.next++
+	return <-p.done[n%slots]
+}
+
+// stop shuts down the workers, which may leave rows unconverted.
+func (p *rowPipeline) stop() {
+	close(p.jobs)
+	p.wg.Wait()
+}
@@ -0,0 +1,86 @@
+// Package qoi implements a QOI image decoder and encoder.
+//
+// The QOI specification is at https://qoiformat.org/qoi-specification.pdf.
+package qoi
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	magic     = "qoif"
+	headerLen = 14
+	endLen    = 8
+)
+
+// endMarker terminates every QOI stream.
+var endMarker = [endLen]byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+// Chunk tags. The 2-bit tags occupy the top two bits of the first byte; the
+// 8-bit tags take precedence over opRun.
+const (
+	opIndex = 0x00 // 00xxxxxx
+	opDiff  = 0x40 // 01xxxxxx
+	opLuma  = 0x80 // 10xxxxxx
+	opRun   = 0xc0 // 11xxxxxx
+	opRGB   = 0xfe // 11111110
+	opRGBA  = 0xff // 11111111
+
+	opMask = 0xc0
+)
+
+// maxChunkLen is the size of the largest chunk, QOI_OP_RGBA.
+const maxChunkLen = 5
+
+// maxRun is the longest run a single QOI_OP_RUN chunk can encode.
+const maxRun = 62
+
+// Channels is the channel count stored in a QOI header.
+type Channels uint8
+
+// Channel counts.
+const (
+	RGB  Channels = 3
+	RGBA Channels = 4
+)
+
+// ColorSpace is the colorspace stored in a QOI header. It is purely
+// informational and does not change how pixels are encoded.
+type ColorSpace uint8
+
+// Colorspaces.
+const (
+	SRGB   ColorSpace = 0 // sRGB with linear alpha
+	Linear ColorSpace = 1 // all channels linear
+)
+
+// RGBModel is the color model of images without an alpha channel. It
+// converts colors to fully opaque color.NRGBA values, dropping alpha the way
+// an Encoder with Channels set to RGB does.
+var RGBModel color.Model = color.ModelFunc(rgbModel)
+
+func rgbModel(c color.Color) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = 0xff
+	return n
+}
+
+// Header is the metadata stored at the start of a QOI stream.
+type Header struct {
+	Width, Height int
+	Channels      Channels
+	ColorSpace    ColorSpace
+}
+
+// startPixel is the value of the previous pixel before the first chunk.
+var startPixel = color.NRGBA{0, 0, 0, 0xff}
+
+// hash returns the position of c in the index of previously seen pixels.
+func hash(c color.NRGBA) int {
+	return (int(c.R)*3 + int(c.G)*5 + int(c.B)*7 + int(c.A)*11) % 64
+}
+
+func init() {
+	image.RegisterFormat("qoi", magic, Decode, DecodeConfig)
+}
@@ -0,0 +1,111 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage returns a deterministic w×h image mixing runs, small and large
+// color steps, and varying alpha, so that every chunk type gets exercised.
+func testImage(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			seed = seed*1664525 + 1013904223
+			var c color.NRGBA
+			switch (x / 8) % 4 {
+			case 0: // flat
+				c = color.NRGBA{0x20, 0x40, 0x60, 0xff}
+			case 1: // gentle gradient
+				c = color.NRGBA{uint8(x), uint8(y), uint8(x + y), 0xff}
+			case 2: // noise
+				c = color.NRGBA{uint8(seed >> 24), uint8(seed >> 16), uint8(seed >> 8), 0xff}
+			case 3: // translucent
+				c = color.NRGBA{uint8(x * 3), 0x80, uint8(y * 5), uint8(seed >> 24)}
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+	return m
+}
+
+func encodeBytes(t testing.TB, m image.Image, enc *Encoder) []byte {
+	t.Helper()
+	if enc == nil {
+		enc = &Encoder{}
+	}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, m); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// samePixels reports the first pixel at which a and b differ, comparing them
+// in NRGBA space.
+func samePixels(t testing.TB, a, b image.Image) {
+	t.Helper()
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		t.Fatalf("size mismatch: %v vs %v", ab, bb)
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y))
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if ca != cb {
+				t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, cb, ca)
+			}
+		}
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	for _, size := range []image.Point{{1, 1}, {7, 3}, {64, 64}, {300, 17}} {
+		m := testImage(size.X, size.Y)
+		got, err := Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+		if err != nil {
+			t.Fatalf("%v: Decode: %v", size, err)
+		}
+		samePixels(t, m, got)
+	}
+}
+
+func TestImageDecode(t *testing.T) {
+	m := testImage(16, 16)
+	got, format, err := image.Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "qoi" {
+		t.Errorf("format: got %q, want %q", format, "qoi")
+	}
+	samePixels(t, m, got)
+}
+
+func FuzzRoundtrip(f *testing.F) {
+	f.Add(uint8(1), []byte{0, 0, 0, 0})
+	f.Add(uint8(3), testImage(3, 5).Pix)
+	f.Add(uint8(16), testImage(16, 16).Pix)
+	f.Fuzz(func(t *testing.T, w uint8, pix []byte) {
+		if w == 0 {
+			return
+		}
+		stride := 4 * int(w)
+		h := len(pix) / stride
+		if h == 0 {
+			return
+		}
+		m := &image.NRGBA{Pix: pix[:h*stride], Stride: stride, Rect: image.Rect(0, 0, int(w), h)}
+		got, err := Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.(*image.NRGBA).Pix, m.Pix) {
+			t.Fatal("roundtrip mismatch")
+		}
+	})
+}
@@ -0,0 +1,282 @@
+// Package qoihttp serves QOI images over HTTP to clients that may not
+// support them.
+//
+// A Handler wraps another handler, typically an http.FileServer over a
+// directory of assets. Clients whose Accept header lists image/qoi get the
+// QOI responses as they are; everyone else gets them transcoded to PNG.
+// Transcoded images are cached, so each is converted only once.
+package qoihttp
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"image/png"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/clfs/qoi"
+)
+
+// DefaultCacheSize is the number of bytes of transcoded images a Handler
+// keeps when CacheSize is zero.
+const DefaultCacheSize = 32 << 20
+
+// A Handler serves requests with another handler, transcoding its QOI
+// responses to PNG for clients that do not accept QOI. A response is QOI if
+// its Content-Type is image/qoi; the Handler sets that type for requests
+// whose path ends in ".qoi" before the wrapped handler runs, so that
+// http.FileServer labels QOI files correctly. Only complete 200 responses
+// are transcoded, and other responses pass through unchanged.
+//
+// Requests for paths ending in ".qoi" from clients that do not accept QOI
+// are rewritten so that the response can be transcoded: their Range headers
+// are dropped, HEAD becomes GET, and the Handler answers If-None-Match
+// itself against the ETag of the PNG variant. Requests for other paths
+// reach the wrapped handler unchanged, so range and conditional requests
+// for them work as usual; a QOI response to one is transcoded only if it
+// is complete.
+//
+// A Handler must not be copied after first use.
+type Handler struct {
+	// Handler is the wrapped handler.
+	Handler http.Handler
+
+	// PNG configures the PNG encoder.
+	PNG png.Encoder
+
+	// Decode configures decoding QOI responses. Its size limits protect
+	// against responses that would take too much memory to transcode.
+	Decode qoi.DecodeOptions
+
+	// CacheSize is the number of bytes of transcoded images to keep, least
+	// recently used first out. Zero means DefaultCacheSize, and a negative
+	// value disables the cache.
+	CacheSize int64
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]*list.Element
+	lru   list.List // of *entry, most recently used first
+	size  int64
+}
+
+type entry struct {
+	key [sha256.Size]byte
+	png []byte
+}
+
+// NewHandler returns a Handler that wraps h with the default settings.
+func NewHandler(h http.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if strings.HasSuffix(r.URL.Path, qoi.Extension) {
+		w.Header().Set("Content-Type", qoi.MIMEType)
+	}
+	if accepts(r.Header.Get("Accept"), qoi.MIMEType) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, qoi.Extension) {
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			h.Handler.ServeHTTP(w, r)
+			return
+		}
+		h.serveTranscoded(w, r, r)
+		return
+	}
+
+	// Transcoding needs the whole image, and HEAD requests need it too to
+	// know the length of the result. The client's validators are for the
+	// PNG variant, so the Handler checks them itself; if passed on, they
+	// would be compared with the ETag of the QOI response.
+	r2 := r.Clone(r.Context())
+	r2.Header.Del("Range")
+	r2.Header.Del("If-Range")
+	inm := r.Header.Get("If-None-Match")
+	if inm != "" {
+		r2.Header.Del("If-None-Match")
+		// If-None-Match takes precedence over If-Modified-Since.
+		r2.Header.Del("If-Modified-Since")
+	}
+	if r.Method == http.MethodHead {
+		r2.Method = http.MethodGet
+	}
+	h.serveTranscoded(w, r, r2)
+}
+
+// serveTranscoded serves r by passing r2 to the wrapped handler and
+// transcoding its response if it is a complete QOI image.
+func (h *Handler) serveTranscoded(w http.ResponseWriter, r, r2 *http.Request) {
+	cw := &captureWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+	h.Handler.ServeHTTP(cw, r2)
+	if !cw.capture {
+		return
+	}
+
+	hdr := w.Header()
+	etag := pngETag(hdr.Get("Etag"))
+	if inm := r.Header.Get("If-None-Match"); etag != "" && inm != "" && etagMatch(inm, etag) {
+		hdr.Set("Etag", etag)
+		hdr.Del("Content-Type")
+		hdr.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, err := h.transcode(cw.buf.Bytes())
+	if err != nil {
+		// Serve the image as it is rather than fail the request; the
+		// client may still know what to do with it.
+		data = cw.buf.Bytes()
+	} else {
+		hdr.Set("Content-Type", "image/png")
+		hdr.Del("Accept-Ranges")
+		if etag != "" {
+			hdr.Set("Etag", etag)
+		}
+	}
+	hdr.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// transcode returns the PNG encoding of the QOI image in data.
+func (h *Handler) transcode(data []byte) ([]byte, error) {
+	key := sha256.Sum256(data)
+	if b := h.lookup(key); b != nil {
+		return b, nil
+	}
+	m, err := h.Decode.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := h.PNG.Encode(&buf, m); err != nil {
+		return nil, err
+	}
+	h.store(key, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+func (h *Handler) lookup(key [sha256.Size]byte) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := h.cache[key]
+	if e == nil {
+		return nil
+	}
+	h.lru.MoveToFront(e)
+	return e.Value.(*entry).png
+}
+
+func (h *Handler) store(key [sha256.Size]byte, b []byte) {
+	limit := h.CacheSize
+	if limit == 0 {
+		limit = DefaultCacheSize
+	}
+	if int64(len(b)) > limit {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		h.cache = make(map[[sha256.Size]byte]*list.Element)
+	}
+	if h.cache[key] != nil {
+		return
+	}
+	h.cache[key] = h.lru.PushFront(&entry{key, b})
+	h.size += int64(len(b))
+	for h.size > limit {
+		e := h.lru.Remove(h.lru.Back()).(*entry)
+		delete(h.cache, e.key)
+		h.size -= int64(len(e.png))
+	}
+}
+
+// pngETag returns the entity tag of the PNG variant of a response with
+// entity tag etag, or "" if etag is not a valid entity tag.
+func pngETag(etag string) string {
+	if len(etag) < 2 || !strings.HasSuffix(etag, `"`) {
+		return ""
+	}
+	return strings.TrimSuffix(etag, `"`) + `-png"`
+}
+
+// etagMatch reports whether the If-None-Match header value inm lists etag,
+// using the weak comparison that If-None-Match calls for.
+func etagMatch(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether the Accept header value lists typ explicitly with
+// a nonzero quality. Wildcards do not count, since browsers send them
+// without supporting every image format.
+func accepts(accept, typ string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		t, params, err := mime.ParseMediaType(part)
+		if err != nil || t != typ {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// A captureWriter holds back a successful QOI response so that it can be
+// transcoded, and passes any other response through.
+type captureWriter struct {
+	http.ResponseWriter
+	head bool // the client asked for headers only
+
+	wroteHeader bool
+	capture     bool
+	buf         bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	t, _, _ := mime.ParseMediaType(cw.Header().Get("Content-Type"))
+	if code == http.StatusOK && t == qoi.MIMEType {
+		cw.capture = true
+		return
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.capture {
+		return cw.buf.Write(b)
+	}
+	if cw.head {
+		// The request was turned into a GET, so drop the body.
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
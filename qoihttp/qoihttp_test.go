@@ -0,0 +1,207 @@
+package qoihttp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/clfs/qoi"
+)
+
+func testImage() *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 12, 9))
+	for y := range 9 {
+		for x := range 12 {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 20), uint8(y * 28), 0x55, uint8(0xff - x)})
+		}
+	}
+	return m
+}
+
+func newServer(t *testing.T) (*Handler, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := qoi.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"a.qoi":     {Data: buf.Bytes()},
+		"notes.txt": {Data: []byte("hello")},
+	}
+	return NewHandler(http.FileServerFS(fsys)), buf.Bytes()
+}
+
+func get(h http.Handler, method, path string, hdr ...string) *http.Response {
+	r := httptest.NewRequest(method, path, nil)
+	for i := 0; i < len(hdr); i += 2 {
+		r.Header.Set(hdr[i], hdr[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestAcceptsQOI(t *testing.T) {
+	h, data := newServer(t)
+	resp := get(h, "GET", "/a.qoi", "Accept", "image/qoi,image/*;q=0.8")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "image/qoi" || !bytes.Equal(body, data) {
+		t.Errorf("got %d %q, %d bytes", resp.StatusCode, resp.Header.Get("Content-Type"), len(body))
+	}
+	if resp.Header.Get("Vary") != "Accept" {
+		t.Errorf("Vary = %q", resp.Header.Get("Vary"))
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	h, _ := newServer(t)
+	var first []byte
+	for i := range 2 {
+		resp := get(h, "GET", "/a.qoi", "Accept", "image/webp,*/*", "Range", "bytes=0-9")
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		m, err := png.Decode(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, _ := qoi.Diff(m, testImage()); !d.Equal() {
+			t.Error("transcoded pixels differ")
+		}
+		if i == 0 {
+			first = body
+		} else if !bytes.Equal(body, first) {
+			t.Error("cached response differs")
+		}
+	}
+	if len(h.cache) != 1 || h.size != int64(len(first)) {
+		t.Errorf("cache holds %d entries, %d bytes", len(h.cache), h.size)
+	}
+
+	resp := get(h, "HEAD", "/a.qoi")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Length") != strconv.Itoa(len(first)) || len(body) != 0 {
+		t.Errorf("HEAD: Content-Length %q, %d bytes of body", resp.Header.Get("Content-Length"), len(body))
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	h, _ := newServer(t)
+	resp := get(h, "GET", "/notes.txt")
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("got %q", body)
+	}
+	if resp := get(h, "GET", "/missing.qoi"); resp.StatusCode != 404 {
+		t.Errorf("missing file: status %d", resp.StatusCode)
+	}
+	resp = get(h, "HEAD", "/notes.txt")
+	body, _ = io.ReadAll(resp.Body)
+	if len(body) != 0 {
+		t.Errorf("HEAD: %d bytes of body", len(body))
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	h := &Handler{CacheSize: 10}
+	h.store([32]byte{1}, make([]byte, 6))
+	h.store([32]byte{2}, make([]byte, 3))
+	h.lookup([32]byte{1})
+	h.store([32]byte{3}, make([]byte, 4))
+	if h.lookup([32]byte{2}) != nil || h.lookup([32]byte{1}) == nil || h.lookup([32]byte{3}) == nil {
+		t.Error("did not evict the least recently used entry")
+	}
+	h.store([32]byte{4}, make([]byte, 11))
+	if h.lookup([32]byte{4}) != nil {
+		t.Error("stored an entry larger than the cache")
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   bool
+	}{
+		{"image/qoi", true},
+		{"text/html, image/qoi;q=0.5", true},
+		{"image/qoi;q=0", false},
+		{"image/*", false},
+		{"*/*", false},
+		{"", false},
+	} {
+		if got := accepts(tc.accept, qoi.MIMEType); got != tc.want {
+			t.Errorf("accepts(%q) = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	_, data := newServer(t)
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"v1"`)
+		http.ServeContent(w, r, "a.qoi", time.Time{}, bytes.NewReader(data))
+	}))
+
+	resp := get(h, "GET", "/a.qoi")
+	etag := resp.Header.Get("Etag")
+	if resp.StatusCode != 200 || etag != `"v1-png"` {
+		t.Fatalf("got %d with ETag %q, want 200 with the PNG variant's ETag", resp.StatusCode, etag)
+	}
+
+	for _, inm := range []string{etag, `"other", W/"v1-png"`, "*"} {
+		resp = get(h, "GET", "/a.qoi", "If-None-Match", inm)
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+			t.Errorf("If-None-Match %s: got %d with %d bytes, want 304", inm, resp.StatusCode, len(body))
+		}
+		if got := resp.Header.Get("Etag"); got != etag {
+			t.Errorf("If-None-Match %s: ETag %q, want %q", inm, got, etag)
+		}
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Errorf("If-None-Match %s: Vary = %q", inm, resp.Header.Get("Vary"))
+		}
+	}
+
+	// The QOI variant's tag does not validate the PNG variant.
+	resp = get(h, "GET", "/a.qoi", "If-None-Match", `"v1"`)
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("If-None-Match of the QOI variant: got %d %q, want 200 image/png", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// Clients that accept QOI still get 304s from the wrapped handler.
+	resp = get(h, "GET", "/a.qoi", "Accept", qoi.MIMEType, "If-None-Match", `"v1"`)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("QOI variant: got %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestPassThroughConditional(t *testing.T) {
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"n1"`)
+		http.ServeContent(w, r, "notes.txt", time.Time{}, strings.NewReader("some notes"))
+	}))
+
+	resp := get(h, "GET", "/notes.txt", "Range", "bytes=0-3")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "some" {
+		t.Errorf("Range: got %d %q, want 206 %q", resp.StatusCode, body, "some")
+	}
+	resp = get(h, "GET", "/notes.txt", "If-None-Match", `"n1"`)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match: got %d, want 304", resp.StatusCode)
+	}
+	resp = get(h, "HEAD", "/notes.txt")
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Length") != "10" {
+		t.Errorf("HEAD: got %d with Content-Length %q", resp.StatusCode, resp.Header.Get("Content-Length"))
+	}
+}
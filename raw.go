@@ -0,0 +1,166 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// PixelFormat is the memory layout of raw pixels passed to EncodeRaw.
+type PixelFormat uint8
+
+// Pixel formats. Except in PixelRGB565, channels are 8 bits each and, where
+// present, alpha is not premultiplied.
+const (
+	PixelRGBA PixelFormat = iota
+	PixelRGB
+	PixelBGRA
+	PixelARGB
+	PixelRGBX   // RGBA with the fourth byte ignored; pixels are opaque
+	PixelRGB565 // 16-bit little-endian words, red in the top 5 bits
+)
+
+// bytesPerPixel returns the size of a pixel in format f, or 0 if f is not a
+// known format.
+func (f PixelFormat) bytesPerPixel() int {
+	switch f {
+	case PixelRGBA, PixelBGRA, PixelARGB, PixelRGBX:
+		return 4
+	case PixelRGB:
+		return 3
+	case PixelRGB565:
+		return 2
+	}
+	return 0
+}
+
+// nrgbaRow converts the pixels in src, which is in format f, to
+// non-premultiplied RGBA bytes in dst.
+func (f PixelFormat) nrgbaRow(dst, src []byte) {
+	switch f {
+	case PixelRGBA:
+		copy(dst, src)
+	case PixelRGB:
+		for i, j := 0, 0; i < len(dst); i, j = i+4, j+3 {
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = src[j], src[j+1], src[j+2], 0xff
+		}
+	case PixelBGRA:
+		for i := 0; i < len(dst); i += 4 {
+			s, d := src[i:i+4:i+4], dst[i:i+4:i+4]
+			d[0], d[1], d[2], d[3] = s[2], s[1], s[0], s[3]
+		}
+	case PixelARGB:
+		for i := 0; i < len(dst); i += 4 {
+			s, d := src[i:i+4:i+4], dst[i:i+4:i+4]
+			d[0], d[1], d[2], d[3] = s[1], s[2], s[3], s[0]
+		}
+	case PixelRGBX:
+		for i := 0; i < len(dst); i += 4 {
+			s, d := src[i:i+4:i+4], dst[i:i+4:i+4]
+			d[0], d[1], d[2], d[3] = s[0], s[1], s[2], 0xff
+		}
+	case PixelRGB565:
+		// Widen each channel by repeating its top bits, so that full
+		// intensity maps to 0xff.
+		for i, j := 0, 0; i < len(dst); i, j = i+4, j+2 {
+			v := uint16(src[j]) | uint16(src[j+1])<<8
+			r, g, b := uint8(v>>11), uint8(v>>5)&0x3f, uint8(v)&0x1f
+			d := dst[i : i+4 : i+4]
+			d[0], d[1], d[2], d[3] = r<<3|r>>2, g<<2|g>>4, b<<3|b>>2, 0xff
+		}
+	}
+}
+
+// rawImage adapts a raw pixel buffer to image.Image so the encoder can read
+// it a row at a time.
+type rawImage struct {
+	pix    []byte
+	stride int
+	rect   image.Rectangle
+	format PixelFormat
+}
+
+func (m *rawImage) ColorModel() color.Model { return color.NRGBAModel }
+func (m *rawImage) Bounds() image.Rectangle { return m.rect }
+
+func (m *rawImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(m.rect)) {
+		return color.NRGBA{}
+	}
+	bpp := m.format.bytesPerPixel()
+	var b [4]byte
+	i := y*m.stride + x*bpp
+	m.format.nrgbaRow(b[:], m.pix[i:i+bpp])
+	return color.NRGBA{b[0], b[1], b[2], b[3]}
+}
+
+// row returns the bytes of row y.
+func (m *rawImage) row(y int) []byte {
+	i := y * m.stride
+	return m.pix[i : i+m.rect.Dx()*m.format.bytesPerPixel()]
+}
+
+// EncodeRaw writes the raw pixels in pix to w in QOI format. The image is
+// width×height pixels in the given format, with rows starting stride bytes
+// apart.
+func EncodeRaw(w io.Writer, pix []byte, width, height, stride int, format PixelFormat) error {
+	var e Encoder
+	return e.EncodeRaw(w, pix, width, height, stride, format)
+}
+
+// EncodeRaw writes the raw pixels in pix to w in QOI format. The image is
+// width×height pixels in the given format, with rows starting stride bytes
+// apart.
+func (enc *Encoder) EncodeRaw(w io.Writer, pix []byte, width, height, stride int, format PixelFormat) error {
+	m, err := newRawImage(pix, width, height, stride, format)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(w, m)
+}
+
+func newRawImage(pix []byte, width, height, stride int, format PixelFormat) (image.Image, error) {
+	bpp := format.bytesPerPixel()
+	if bpp == 0 {
+		return nil, &kindError{ErrUnsupported, errors.New("qoi: unknown pixel format")}
+	}
+	if width <= 0 || height <= 0 || width > math.MaxInt/bpp {
+		return nil, errors.New("qoi: invalid raw image size")
+	}
+	row := width * bpp
+	if stride < row {
+		return nil, errors.New("qoi: stride is shorter than a row")
+	}
+	// The last row needs only row bytes. Dividing instead of multiplying
+	// keeps huge strides and heights from overflowing.
+	if len(pix) < row || height-1 > (len(pix)-row)/stride {
+		return nil, errors.New("qoi: pixel buffer is too short")
+	}
+	r := image.Rect(0, 0, width, height)
+	if format == PixelRGBA {
+		return &image.NRGBA{Pix: pix, Stride: stride, Rect: r}, nil
+	}
+	return &rawImage{pix: pix, stride: stride, rect: r, format: format}, nil
+}
+
+// DecodeRaw reads a QOI image from r into pix as non-premultiplied RGBA
+// bytes, top row first with no padding between rows. If pix is shorter than
+// 4×Width×Height bytes, DecodeRaw returns the header and io.ErrShortBuffer
+// without decoding any pixels, so callers can size a buffer and retry with a
+// fresh reader.
+func DecodeRaw(r io.Reader, pix []byte) (Header, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, err
+	}
+	n := 4 * d.hdr.Width * d.hdr.Height
+	if len(pix) < n {
+		return d.hdr, io.ErrShortBuffer
+	}
+	if err := d.decodeSpan(pix[:n]); err != nil {
+		return d.hdr, err
+	}
+	return d.hdr, d.readEnd()
+}
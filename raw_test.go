@@ -0,0 +1,126 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"math"
+	"testing"
+)
+
+// rawPixels returns the pixels of m in format f with pad bytes after each row.
+func rawPixels(m *image.NRGBA, f PixelFormat, pad int) (pix []byte, stride int) {
+	b := m.Bounds()
+	bpp := f.bytesPerPixel()
+	stride = b.Dx()*bpp + pad
+	pix = make([]byte, stride*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := m.NRGBAAt(x, y)
+			p := pix[y*stride+x*bpp:]
+			switch f {
+			case PixelRGBA:
+				p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+			case PixelRGB:
+				p[0], p[1], p[2] = c.R, c.G, c.B
+			case PixelBGRA:
+				p[0], p[1], p[2], p[3] = c.B, c.G, c.R, c.A
+			case PixelARGB:
+				p[0], p[1], p[2], p[3] = c.A, c.R, c.G, c.B
+			case PixelRGBX:
+				p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A^0x5a
+			case PixelRGB565:
+				v := uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+				p[0], p[1] = uint8(v), uint8(v>>8)
+			}
+		}
+	}
+	return pix, stride
+}
+
+func TestEncodeRaw(t *testing.T) {
+	src := testImage(33, 12)
+	opaque := image.NewNRGBA(src.Bounds())
+	copy(opaque.Pix, src.Pix)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 0xff
+	}
+
+	rgb565 := image.NewNRGBA(src.Bounds())
+	copy(rgb565.Pix, opaque.Pix)
+	for i := 0; i < len(rgb565.Pix); i += 4 {
+		p := rgb565.Pix[i : i+3]
+		p[0], p[1], p[2] = p[0]&0xf8|p[0]>>5, p[1]&0xfc|p[1]>>6, p[2]&0xf8|p[2]>>5
+	}
+
+	tests := []struct {
+		format PixelFormat
+		want   *image.NRGBA
+	}{
+		{PixelRGBA, src},
+		{PixelRGB, opaque},
+		{PixelBGRA, src},
+		{PixelARGB, src},
+		{PixelRGBX, opaque},
+		{PixelRGB565, rgb565},
+	}
+	for _, tt := range tests {
+		pix, stride := rawPixels(src, tt.format, 7)
+		var buf bytes.Buffer
+		if err := EncodeRaw(&buf, pix, 33, 12, stride, tt.format); err != nil {
+			t.Fatalf("format %d: %v", tt.format, err)
+		}
+		if want := encodeBytes(t, tt.want, nil); !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("format %d: output differs from encoding the equivalent image", tt.format)
+		}
+	}
+}
+
+func TestEncodeRawInvalid(t *testing.T) {
+	pix := make([]byte, 4*4*4)
+	tests := []struct {
+		name                  string
+		pix                   []byte
+		width, height, stride int
+		format                PixelFormat
+	}{
+		{"zero width", pix, 0, 4, 16, PixelRGBA},
+		{"short stride", pix, 4, 4, 15, PixelRGBA},
+		{"short buffer", pix[:len(pix)-1], 4, 4, 16, PixelRGBA},
+		{"unknown format", pix, 4, 4, 16, PixelFormat(0xff)},
+		{"huge stride", pix, 4, 4, math.MaxInt/2 + 1, PixelRGBA},
+		{"huge height", pix, 4, math.MaxInt, 16, PixelRGB},
+		{"huge width", pix, math.MaxInt / 2, 1, math.MaxInt, PixelRGBA},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := EncodeRaw(&buf, tt.pix, tt.width, tt.height, tt.stride, tt.format); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	m := testImage(29, 31)
+	data := encodeBytes(t, m, nil)
+
+	pix := make([]byte, len(m.Pix)+8)
+	h, err := DecodeRaw(bytes.NewReader(data), pix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 29 || h.Height != 31 {
+		t.Errorf("header: got %+v", h)
+	}
+	if !bytes.Equal(pix[:len(m.Pix)], m.Pix) {
+		t.Errorf("pixels differ")
+	}
+
+	h, err = DecodeRaw(bytes.NewReader(data), pix[:len(m.Pix)-1])
+	if err != io.ErrShortBuffer {
+		t.Errorf("short buffer: got %v, want %v", err, io.ErrShortBuffer)
+	}
+	if h.Width != 29 || h.Height != 31 {
+		t.Errorf("short buffer: header: got %+v", h)
+	}
+}
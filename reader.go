@@ -0,0 +1,557 @@
+package qoi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// reader is the interface the decoder reads through. Inputs that lack
+// ReadByte are wrapped in a bufio.Reader so chunks are not read with many
+// tiny Read calls.
+type reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type decoder struct {
+	r  reader
+	br *bufio.Reader // reused to wrap inputs that aren't readers
+	n  int64         // bytes consumed from r since reset
+
+	hdr Header
+
+	// strict enables the checks of DecodeOptions.Strict.
+	strict bool
+
+	// delta makes d read frames in delta mode, and keyed records that a
+	// key frame has been read since reset.
+	delta, keyed bool
+
+	// progress is DecodeOptions.Progress while an image is decoded, and
+	// ctx, if not nil, is checked after each row.
+	progress func(rows int, n int64)
+	ctx      context.Context
+
+	// trace, if not nil, is called with each chunk read.
+	trace func(TraceEntry)
+
+	index [64]color.NRGBA
+	px    color.NRGBA
+	run   int
+
+	// checksum enables computing crc, the checksum of the pixels decoded
+	// so far, and sum holds the checksum read from the extension blocks,
+	// if hasSum is set.
+	checksum bool
+	crc, sum uint32
+	hasSum   bool
+	sumBuf   []byte
+
+	// md holds the extension blocks read to verify the checksum, if they
+	// have been read.
+	md *Metadata
+
+	// hash, if not nil, is DecodeOptions.Hash while an image is decoded.
+	hash io.Writer
+
+	// Position of the current chunk, for errors.
+	pixel    int64 // pixels decoded so far
+	chunkOff int64
+
+	tmp [headerLen]byte
+}
+
+func newDecoder(r io.Reader) *decoder {
+	d := &decoder{}
+	d.reset(r)
+	return d
+}
+
+// reset prepares d to decode a new stream from r.
+func (d *decoder) reset(r io.Reader) {
+	if rr, ok := r.(reader); ok {
+		d.r = rr
+	} else {
+		if d.br == nil {
+			d.br = bufio.NewReader(r)
+		} else {
+			d.br.Reset(r)
+		}
+		d.r = d.br
+	}
+	d.n = 0
+	d.keyed = false
+	d.resetImage()
+}
+
+// resetImage prepares d to decode the next image from the same stream. In
+// delta mode, the index and previous pixel are left for readHeader to reset.
+func (d *decoder) resetImage() {
+	d.hdr = Header{}
+	if !d.delta {
+		d.index = [64]color.NRGBA{}
+		d.px = startPixel
+	}
+	d.run = 0
+	d.pixel = 0
+	d.md = nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		d.n++
+	}
+	return b, err
+}
+
+func (d *decoder) readFull(b []byte) error {
+	n, err := io.ReadFull(d.r, b)
+	d.n += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *decoder) readHeader() error {
+	if err := d.readFull(d.tmp[:headerLen]); err != nil {
+		return err
+	}
+	switch {
+	case string(d.tmp[:4]) == magic:
+		if d.delta {
+			d.index = [64]color.NRGBA{}
+			d.px = startPixel
+			d.keyed = true
+		}
+	case d.delta && string(d.tmp[:4]) == deltaMagic:
+		if !d.keyed {
+			return FormatError("continuation frame without a key frame")
+		}
+	default:
+		return &kindError{ErrBadMagic, FormatError("not a QOI file")}
+	}
+	w := binary.BigEndian.Uint32(d.tmp[4:8])
+	h := binary.BigEndian.Uint32(d.tmp[8:12])
+	if w == 0 || h == 0 {
+		return &kindError{ErrBadHeader, FormatError("invalid image size")}
+	}
+	// The decoded pixels must be addressable as a single []byte of NRGBA
+	// values, which also keeps both dimensions within an int.
+	if uint64(w)*uint64(h) > math.MaxInt/4 {
+		return &SizeError{Width: uint64(w), Height: uint64(h)}
+	}
+	d.hdr.Width, d.hdr.Height = int(w), int(h)
+
+	switch c := Channels(d.tmp[12]); c {
+	case RGB, RGBA:
+		d.hdr.Channels = c
+	default:
+		return &kindError{ErrBadHeader, FormatError("invalid channels")}
+	}
+	switch cs := ColorSpace(d.tmp[13]); cs {
+	case SRGB, Linear:
+		d.hdr.ColorSpace = cs
+	default:
+		return &kindError{ErrBadHeader, FormatError("invalid colorspace")}
+	}
+	return nil
+}
+
+// errorAt wraps err in a DecodeError for the current position if it reports
+// corrupt data, and otherwise returns it as it is. tag is the current
+// chunk's first byte, or -1.
+func (d *decoder) errorAt(tag int, err error) error {
+	var fe FormatError
+	if !errors.As(err, &fe) {
+		return err
+	}
+	return d.decodeError(tag, err)
+}
+
+// decodeError returns a DecodeError for err at the current position.
+func (d *decoder) decodeError(tag int, err error) *DecodeError {
+	return &DecodeError{
+		Offset: d.chunkOff,
+		X:      int(d.pixel % int64(d.hdr.Width)),
+		Y:      int(d.pixel / int64(d.hdr.Width)),
+		Tag:    tag,
+		Err:    err,
+	}
+}
+
+// advance decodes the next pixel into d.px.
+func (d *decoder) advance() error {
+	if d.run > 0 {
+		d.run--
+		d.pixel++
+		return nil
+	}
+
+	d.chunkOff = d.n
+	tag, err := d.readByte()
+	if err != nil {
+		return d.errorAt(-1, err)
+	}
+
+	switch {
+	case tag == opRGB:
+		if err := d.readFull(d.tmp[:3]); err != nil {
+			return d.errorAt(int(tag), err)
+		}
+		d.px.R, d.px.G, d.px.B = d.tmp[0], d.tmp[1], d.tmp[2]
+	case tag == opRGBA:
+		if err := d.readFull(d.tmp[:4]); err != nil {
+			return d.errorAt(int(tag), err)
+		}
+		d.px = color.NRGBA{d.tmp[0], d.tmp[1], d.tmp[2], d.tmp[3]}
+	case tag&opMask == opIndex:
+		d.px = d.index[tag]
+	case tag&opMask == opDiff:
+		d.px.R += (tag>>4)&0x03 - 2
+		d.px.G += (tag>>2)&0x03 - 2
+		d.px.B += tag&0x03 - 2
+	case tag&opMask == opLuma:
+		b, err := d.readByte()
+		if err != nil {
+			return d.errorAt(int(tag), err)
+		}
+		dg := tag&0x3f - 32
+		d.px.R += dg + b>>4 - 8
+		d.px.G += dg
+		d.px.B += dg + b&0x0f - 8
+	case tag&opMask == opRun:
+		d.run = int(tag & 0x3f)
+	}
+
+	d.index[hash(d.px)] = d.px
+	if d.trace != nil {
+		d.trace(TraceEntry{
+			Offset: d.chunkOff,
+			Type:   chunkType(tag),
+			Pixel:  d.pixel,
+			Len:    d.run + 1,
+			Color:  d.px,
+		})
+	}
+	d.pixel++
+	return nil
+}
+
+// readEnd consumes the end marker, leaving r positioned just past the image.
+func (d *decoder) readEnd() error {
+	d.chunkOff = d.n
+	if d.strict && d.run > 0 {
+		return d.errorAt(-1, FormatError("run extends past the last pixel"))
+	}
+	if err := d.readFull(d.tmp[:endLen]); err != nil {
+		return d.errorAt(-1, err)
+	}
+	if d.strict && [endLen]byte(d.tmp[:endLen]) != endMarker {
+		return d.errorAt(-1, FormatError("missing end marker"))
+	}
+	return nil
+}
+
+// Decode reads a QOI image from r and returns it as an *image.NRGBA.
+//
+// If r implements io.ByteReader, as *bufio.Reader and *bytes.Reader do,
+// Decode reads exactly through the end marker and no further, so data
+// following the image remains in r. Otherwise r is read through an internal
+// buffer and Decode may read more data than necessary from r.
+func Decode(r io.Reader) (image.Image, error) {
+	var o DecodeOptions
+	return o.Decode(r)
+}
+
+// DecodeContext is like Decode, but checks ctx after each row and returns
+// ctx.Err() if ctx is done before the image is complete.
+func DecodeContext(ctx context.Context, r io.Reader) (image.Image, error) {
+	var o DecodeOptions
+	return o.DecodeContext(ctx, r)
+}
+
+// decode reads an entire image from d.r.
+func (d *decoder) decode(o *DecodeOptions) (image.Image, error) {
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := o.checkLimits(d.hdr); err != nil {
+		return nil, err
+	}
+	return d.decodeImage(o)
+}
+
+// decodeImage reads the pixels of an image whose header has been read, and
+// verifies its checksum if o asks for it.
+func (d *decoder) decodeImage(o *DecodeOptions) (image.Image, error) {
+	d.hash = o.Hash
+	defer func() { d.hash = nil }()
+	if !o.VerifyChecksum {
+		return d.decodePix(o)
+	}
+	d.checksum, d.crc = true, 0
+	defer func() { d.checksum = false }()
+	m, err := d.decodePix(o)
+	if err != nil {
+		return m, err
+	}
+	if err := d.verifyChecksum(); err != nil {
+		if o.KeepPartial {
+			return m, err
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodePix reads the pixels of an image whose header has been read.
+func (d *decoder) decodePix(o *DecodeOptions) (image.Image, error) {
+	d.progress = o.Progress
+	t := o.orientation()
+	if t > OrientLeftBottom {
+		return nil, &kindError{ErrUnsupported, errors.New("qoi: unknown orientation")}
+	}
+	w, h := t.size(d.hdr.Width, d.hdr.Height)
+	r := image.Rect(0, 0, w, h)
+	post := o.rowFunc(d.hdr)
+
+	if o.PackRGB && d.hdr.Channels == RGB {
+		img := NewRGBImage(r)
+		var err error
+		if t == OrientTopLeft || t == OrientBottomLeft {
+			err = d.decodeRGB(img, t == OrientBottomLeft, post)
+		} else {
+			err = d.decodeOriented(img.Pix, img.Stride, 3, t, post)
+		}
+		if err != nil {
+			if o.KeepPartial {
+				return img, err
+			}
+			return nil, err
+		}
+		return img, nil
+	}
+	if o.Premultiply {
+		img := image.NewRGBA(r)
+		if post == nil {
+			post = premultiplyRow
+		} else {
+			convert := post
+			post = func(row []byte) {
+				convert(row)
+				premultiplyRow(row)
+			}
+		}
+		if err := d.decodeTransformed(img.Pix, img.Stride, t, post); err != nil {
+			if o.KeepPartial {
+				return img, err
+			}
+			return nil, err
+		}
+		return img, nil
+	}
+
+	img := d.newImage(o, w, h)
+	if err := d.decodeTransformed(img.Pix, img.Stride, t, post); err != nil {
+		if o.KeepPartial {
+			return img, err
+		}
+		if o.ImagePool != nil {
+			o.ImagePool.Put(img)
+		}
+		return nil, err
+	}
+	return img, nil
+}
+
+// newImage returns a w×h image, taking it from o.ImagePool if possible.
+func (d *decoder) newImage(o *DecodeOptions, w, h int) *image.NRGBA {
+	r := image.Rect(0, 0, w, h)
+	if o.ImagePool != nil {
+		if img := o.ImagePool.Get(w, h); img != nil && img.Rect == r {
+			if o.KeepPartial {
+				// Pixels that are never decoded must read as
+				// transparent, as in a new image.
+				for y := 0; y < h; y++ {
+					clear(img.Pix[y*img.Stride:][:4*w])
+				}
+			}
+			return img
+		}
+	}
+	return image.NewNRGBA(r)
+}
+
+// decodeTransformed decodes the pixels and end marker into pix, which holds
+// rows of 4-byte pixels stride bytes apart, applying transform t.
+func (d *decoder) decodeTransformed(pix []byte, stride int, t Orientation, post func(row []byte)) error {
+	switch t {
+	case OrientTopLeft:
+		return d.decodePixels(pix, stride, false, post)
+	case OrientBottomLeft:
+		return d.decodePixels(pix, stride, true, post)
+	}
+	return d.decodeOriented(pix, stride, 4, t, post)
+}
+
+// decodePixels decodes the pixels and end marker into pix, which holds rows
+// of 4-byte pixels stride bytes apart, filling it bottom row first if flip
+// is set. If post is not nil, it is applied to each row as soon as the row
+// is decoded, including a row only partly decoded when an error occurs; the
+// untouched pixels are zero.
+func (d *decoder) decodePixels(pix []byte, stride int, flip bool, post func(row []byte)) error {
+	n := 4 * d.hdr.Width
+	if stride == n && !flip && post == nil && d.progress == nil && d.ctx == nil {
+		if err := d.decodeSpan(pix[:n*d.hdr.Height]); err != nil {
+			return err
+		}
+		return d.readEnd()
+	}
+	for y := 0; y < d.hdr.Height; y++ {
+		i := y * stride
+		if flip {
+			i = (d.hdr.Height - 1 - y) * stride
+		}
+		row := pix[i : i+n]
+		err := d.decodeSpan(row)
+		if post != nil {
+			post(row)
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.rowDone(y); err != nil {
+			return err
+		}
+	}
+	return d.readEnd()
+}
+
+// rowDone reports that row y has been decoded, and returns an error if
+// decoding should stop.
+func (d *decoder) rowDone(y int) error {
+	if d.progress != nil {
+		d.progress(y+1, d.n)
+	}
+	if d.ctx != nil {
+		return d.ctx.Err()
+	}
+	return nil
+}
+
+// decodeSpan decodes len(pix)/4 pixels into pix as NRGBA bytes. Runs are
+// expanded with bulk copies, and may continue into the next span.
+func (d *decoder) decodeSpan(pix []byte) error {
+	for i := 0; i < len(pix); {
+		if d.run > 0 {
+			n := min(d.run, (len(pix)-i)/4)
+			fillPixel(pix[i:i+4*n], d.px)
+			d.run -= n
+			d.pixel += int64(n)
+			i += 4 * n
+			continue
+		}
+		if err := d.advance(); err != nil {
+			return err
+		}
+		p := pix[i : i+4 : i+4]
+		p[0], p[1], p[2], p[3] = d.px.R, d.px.G, d.px.B, d.px.A
+		i += 4
+	}
+	if d.checksum {
+		d.crc = updateCRC(d.crc, pix, d.hdr.Channels, &d.sumBuf)
+	}
+	if d.hash != nil {
+		d.hash.Write(pix)
+	}
+	return nil
+}
+
+// fillPixel fills b, whose length is a multiple of 4, with copies of c.
+func fillPixel(b []byte, c color.NRGBA) {
+	fillWord(b, pixelWordOf(c))
+}
+
+// DecodeHeader reads the header of a QOI image from r, including the
+// channel count and colorspace that DecodeConfig does not report. An
+// embedded ICC profile follows the pixels; DecodeICCProfile reports it.
+func DecodeHeader(r io.Reader) (Header, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, err
+	}
+	return d.hdr, nil
+}
+
+// DecodeConfig returns the color model and dimensions of a QOI image without
+// decoding the entire image. The color model is RGBModel if the header
+// declares three channels, and color.NRGBAModel otherwise.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return image.Config{}, err
+	}
+	model := color.NRGBAModel
+	if d.hdr.Channels == RGB {
+		model = RGBModel
+	}
+	return image.Config{
+		ColorModel: model,
+		Width:      d.hdr.Width,
+		Height:     d.hdr.Height,
+	}, nil
+}
+
+// DecodeInto reads a QOI image from r and draws it onto dst, converting each
+// pixel to dst's color model as it is decoded. The image is placed at
+// dst.Bounds().Min, and dst must be at least as large as the image. Once the
+// header has been read, the returned Header describes the image even if
+// decoding fails later.
+func DecodeInto(r io.Reader, dst draw.Image) (Header, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, err
+	}
+	b := dst.Bounds()
+	if b.Dx() < d.hdr.Width || b.Dy() < d.hdr.Height {
+		return d.hdr, errors.New("qoi: destination image is too small")
+	}
+
+	for y := 0; y < d.hdr.Height; y++ {
+		switch dst := dst.(type) {
+		case *image.NRGBA:
+			i := dst.PixOffset(b.Min.X, b.Min.Y+y)
+			if err := d.decodeSpan(dst.Pix[i : i+4*d.hdr.Width]); err != nil {
+				return d.hdr, err
+			}
+		case *image.RGBA:
+			i := dst.PixOffset(b.Min.X, b.Min.Y+y)
+			row := dst.Pix[i : i+4*d.hdr.Width]
+			err := d.decodeSpan(row)
+			premultiplyRow(row)
+			if err != nil {
+				return d.hdr, err
+			}
+		default:
+			for x := 0; x < d.hdr.Width; x++ {
+				if err := d.advance(); err != nil {
+					return d.hdr, err
+				}
+				dst.Set(b.Min.X+x, b.Min.Y+y, d.px)
+			}
+		}
+	}
+	return d.hdr, d.readEnd()
+}
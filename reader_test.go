@@ -0,0 +1,292 @@
+package qoi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/clfs/qoi/internal/naive"
+)
+
+func TestDecodeLeavesTrailingData(t *testing.T) {
+	m := testImage(40, 30)
+	trailer := []byte("more data follows the image")
+	stream := append(encodeBytes(t, m, nil), trailer...)
+
+	// A small buffer forces several refills while decoding; a large one
+	// buffers the trailer before Decode returns.
+	for _, size := range []int{16, 4096} {
+		br := bufio.NewReaderSize(bytes.NewReader(stream), size)
+		got, err := Decode(br)
+		if err != nil {
+			t.Fatalf("size %d: Decode: %v", size, err)
+		}
+		samePixels(t, m, got)
+		rest, err := io.ReadAll(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rest, trailer) {
+			t.Errorf("size %d: trailing data: got %q, want %q", size, rest, trailer)
+		}
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	src := testImage(23, 17)
+	data := encodeBytes(t, src, nil)
+	offset := image.Rect(5, 7, 5+23, 7+17)
+
+	for _, newDst := range []func() draw.Image{
+		func() draw.Image { return image.NewNRGBA(image.Rect(0, 0, 23, 17)) },
+		func() draw.Image { return image.NewNRGBA(offset) },
+		func() draw.Image { return image.NewRGBA(offset) },
+		func() draw.Image { return image.NewRGBA64(offset) },
+		func() draw.Image { return image.NewGray(offset) },
+		func() draw.Image { return image.NewPaletted(offset, palette.Plan9) },
+	} {
+		dst, want := newDst(), newDst()
+		draw.Draw(want, want.Bounds(), src, image.Point{}, draw.Src)
+
+		h, err := DecodeInto(bytes.NewReader(data), dst)
+		if err != nil {
+			t.Fatalf("%T: %v", dst, err)
+		}
+		if h.Width != 23 || h.Height != 17 {
+			t.Errorf("%T: header %+v", dst, h)
+		}
+		b := dst.Bounds()
+	loop:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if got, want := dst.At(x, y), want.At(x, y); got != want {
+					t.Errorf("%T: pixel (%d, %d): got %v, want %v", dst, x, y, got, want)
+					break loop
+				}
+			}
+		}
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, 22, 17))
+	if _, err := DecodeInto(bytes.NewReader(data), small); err == nil {
+		t.Errorf("decoding into a smaller image succeeded")
+	}
+}
+
+func benchmarkDecode(b *testing.B, m image.Image) {
+	data := encodeBytes(b, m, nil)
+	b.SetBytes(int64(4 * m.Bounds().Dx() * m.Bounds().Dy()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	benchmarkDecode(b, testImage(512, 512))
+}
+
+func BenchmarkDecodeRuns(b *testing.B) {
+	m := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 512; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x / 200), uint8(y / 100), 0x80, 0xff})
+		}
+	}
+	benchmarkDecode(b, m)
+}
+
+// plainReader hides any methods of its Reader besides Read.
+type plainReader struct{ io.Reader }
+
+func TestDecodePlainReader(t *testing.T) {
+	m := testImage(61, 13)
+	var opts DecodeOptions
+	got, err := opts.Decode(plainReader{bytes.NewReader(encodeBytes(t, m, nil))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, m, got)
+}
+
+func BenchmarkDecodePlainReader(b *testing.B) {
+	m := testImage(512, 512)
+	data := encodeBytes(b, m, nil)
+	b.SetBytes(int64(len(m.Pix)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(plainReader{bytes.NewReader(data)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	for _, want := range []Header{
+		{Width: 3, Height: 4, Channels: RGBA, ColorSpace: SRGB},
+		{Width: 70, Height: 1, Channels: RGB, ColorSpace: Linear},
+	} {
+		enc := &Encoder{Channels: want.Channels, ColorSpace: want.ColorSpace}
+		data := encodeBytes(t, testImage(want.Width, want.Height), enc)
+		got, err := DecodeHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	bad := []struct {
+		name string
+		hdr  string
+	}{
+		{"magic", "qoiX\x00\x00\x00\x01\x00\x00\x00\x01\x04\x00"},
+		{"zero width", "qoif\x00\x00\x00\x00\x00\x00\x00\x01\x04\x00"},
+		{"channels", "qoif\x00\x00\x00\x01\x00\x00\x00\x01\x05\x00"},
+		{"colorspace", "qoif\x00\x00\x00\x01\x00\x00\x00\x01\x04\x02"},
+	}
+	for _, tt := range bad {
+		if _, err := DecodeHeader(strings.NewReader(tt.hdr)); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+	if _, err := DecodeHeader(strings.NewReader("qoif")); err != io.ErrUnexpectedEOF {
+		t.Errorf("short header: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeConfigColorModel(t *testing.T) {
+	m := testImage(8, 8)
+	for _, tt := range []struct {
+		channels Channels
+		model    color.Model
+	}{
+		{RGBA, color.NRGBAModel},
+		{RGB, RGBModel},
+	} {
+		data := encodeBytes(t, m, &Encoder{Channels: tt.channels})
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ColorModel != tt.model {
+			t.Errorf("channels %d: got model %v, want %v", tt.channels, cfg.ColorModel, tt.model)
+		}
+	}
+
+	if got, want := RGBModel.Convert(color.NRGBA{10, 20, 30, 40}), (color.NRGBA{10, 20, 30, 0xff}); got != want {
+		t.Errorf("RGBModel.Convert: got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeHeaderTooLarge(t *testing.T) {
+	hdr := "qoif\xff\xff\xff\xff\xff\xff\xff\xff\x04\x00"
+	_, err := DecodeHeader(strings.NewReader(hdr))
+	var se *SizeError
+	if !errors.As(err, &se) || !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want a *SizeError matching ErrTooLarge", err)
+	}
+	if se.Width != 1<<32-1 || se.Height != 1<<32-1 {
+		t.Errorf("got size %dx%d", se.Width, se.Height)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	hdr := "qoif\x00\x00\x00\x03\x00\x00\x00\x02\x04\x00"
+	end := string(endMarker[:])
+	tests := []struct {
+		name string
+		data string
+		want DecodeError
+		msg  string
+	}{
+		{
+			name: "run past the last pixel",
+			data: hdr + "\xc6" + end,
+			want: DecodeError{Offset: 15, X: 0, Y: 2, Tag: -1, Err: FormatError("run extends past the last pixel")},
+			msg:  "qoi: invalid format: run extends past the last pixel at offset 15, pixel (0, 2)",
+		},
+		{
+			name: "bad end marker",
+			data: hdr + "\xc5" + "\x00\x00\x00\x00\x00\x00\x00\x00",
+			want: DecodeError{Offset: 15, X: 0, Y: 2, Tag: -1, Err: FormatError("missing end marker")},
+			msg:  "qoi: invalid format: missing end marker at offset 15, pixel (0, 2)",
+		},
+	}
+	opts := &DecodeOptions{Strict: true}
+	for _, tt := range tests {
+		_, err := opts.Decode(strings.NewReader(tt.data))
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			t.Errorf("%s: got %v, want a *DecodeError", tt.name, err)
+			continue
+		}
+		if *de != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *de, tt.want)
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("%s: message %q, want %q", tt.name, err.Error(), tt.msg)
+		}
+	}
+
+	// Truncation is an I/O error, not corruption, and is not wrapped.
+	for _, data := range []string{hdr + "\xc3" + "\xfe\x01", hdr + "\x55", hdr + "\xc5" + "\x00\x00"} {
+		_, err := Decode(strings.NewReader(data))
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("%q: got %#v, want io.ErrUnexpectedEOF", data, err)
+		}
+	}
+}
+
+// FuzzDecodeNaive checks that Decode, in both modes, and DecodeBytes accept
+// exactly the streams the naive decoder does, and decode them to the same
+// pixels.
+func FuzzDecodeNaive(f *testing.F) {
+	f.Add(encodeBytes(f, testImage(9, 7), nil))
+	f.Add(encodeBytes(f, testImage(9, 7), &Encoder{Channels: RGB}))
+	f.Add(encodeBytes(f, gradient(70, 3), nil)[:100])
+	f.Add([]byte("qoif\x00\x00\x00\x02\x00\x00\x00\x01\x04\x00\xc5\x00\x00\x00\x00\x00\x00\x00\x02"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) >= headerLen {
+			w, h := binary.BigEndian.Uint32(data[4:]), binary.BigEndian.Uint32(data[8:])
+			if uint64(w)*uint64(h) > 1<<16 {
+				return
+			}
+		}
+		for _, strict := range []bool{false, true} {
+			want, werr := naive.Decode(data, strict)
+			got, err := (&DecodeOptions{Strict: strict}).Decode(bytes.NewReader(data))
+			compareNaive(t, "Decode", strict, got, err, want, werr)
+			if !strict {
+				got, err = DecodeBytes(data)
+				compareNaive(t, "DecodeBytes", strict, got, err, want, werr)
+			}
+		}
+	})
+}
+
+func compareNaive(t *testing.T, name string, strict bool, got image.Image, err error, want *naive.Image, werr error) {
+	t.Helper()
+	if (err == nil) != (werr == nil) {
+		t.Fatalf("%s, strict %v: got error %v, naive decoder %v", name, strict, err, werr)
+	}
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(got.(*image.NRGBA).Pix, want.Pix) {
+		t.Fatalf("%s, strict %v: pixels differ from the naive decoder's", name, strict)
+	}
+}
@@ -0,0 +1,122 @@
+//go:build cgo && qoih
+
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clfs/qoi/internal/cqoi"
+)
+
+// referenceCorpus returns the images TestReference compares on: synthetic
+// ones covering every chunk type, plus the PNG files in the directory named
+// by $QOI_CORPUS, if set.
+func referenceCorpus(t *testing.T) map[string]*image.NRGBA {
+	flat := image.NewNRGBA(image.Rect(0, 0, 200, 3))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x7f
+	}
+	edges := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			// Wrapping differences and every alpha value.
+			edges.SetNRGBA(x, y, color.NRGBA{uint8(x * 37), uint8(255 - x), uint8(y * 91), uint8(x + 64*y)})
+		}
+	}
+	corpus := map[string]*image.NRGBA{
+		"testImage":  testImage(97, 61),
+		"gradient":   gradient(120, 80),
+		"noiseFrame": noiseFrame(3),
+		"flat":       flat,
+		"edges":      edges,
+		"1x1":        testImage(1, 1),
+	}
+
+	dir := os.Getenv("QOI_CORPUS")
+	if dir == "" {
+		return corpus
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m := image.NewNRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(m, m.Rect, src, src.Bounds().Min, draw.Src)
+		corpus[filepath.Base(name)] = m
+	}
+	return corpus
+}
+
+// TestReference checks that this package and qoi.h encode every image to
+// the same bytes and decode each other's output to the same pixels.
+func TestReference(t *testing.T) {
+	for name, m := range referenceCorpus(t) {
+		w, h := m.Rect.Dx(), m.Rect.Dy()
+		for _, ch := range []Channels{RGB, RGBA} {
+			pix := m.Pix
+			opaque := m
+			if ch == RGB {
+				pix = make([]byte, 0, 3*w*h)
+				opaque = image.NewNRGBA(m.Rect)
+				for i := 0; i < len(m.Pix); i += 4 {
+					pix = append(pix, m.Pix[i:i+3]...)
+					opaque.Pix = append(opaque.Pix[:i], m.Pix[i], m.Pix[i+1], m.Pix[i+2], 0xff)
+				}
+			}
+
+			want, err := cqoi.Encode(pix, w, h, int(ch), int(Linear))
+			if err != nil {
+				t.Fatalf("%s/%d: %v", name, ch, err)
+			}
+			got := encodeBytes(t, m, &Encoder{Channels: ch, ColorSpace: Linear})
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s/%d: encoded %d bytes, qoi.h %d, first difference at offset %d",
+					name, ch, len(got), len(want), firstDiff(got, want))
+			}
+
+			dec, err := Decode(bytes.NewReader(want))
+			if err != nil {
+				t.Fatalf("%s/%d: decoding qoi.h output: %v", name, ch, err)
+			}
+			if !bytes.Equal(dec.(*image.NRGBA).Pix, opaque.Pix) {
+				t.Errorf("%s/%d: qoi.h output decodes to different pixels", name, ch)
+			}
+			cpix, cw, chh, cch, ccs, err := cqoi.Decode(got, 4)
+			if err != nil {
+				t.Fatalf("%s/%d: qoi.h decoding our output: %v", name, ch, err)
+			}
+			if cw != w || chh != h || cch != int(ch) || ccs != int(Linear) {
+				t.Errorf("%s/%d: qoi.h read header %dx%d, %d channels, colorspace %d", name, ch, cw, chh, cch, ccs)
+			}
+			if !bytes.Equal(cpix, opaque.Pix) {
+				t.Errorf("%s/%d: qoi.h decodes our output to different pixels", name, ch)
+			}
+		}
+	}
+}
+
+func firstDiff(a, b []byte) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return min(len(a), len(b))
+}
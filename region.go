@@ -0,0 +1,89 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// EncodeRegion writes the part of m inside r to w in QOI format, as if it
+// were an image with bounds r. r is clipped to m's bounds. m's pixels are
+// read in place, without copying the region into a new image.
+func EncodeRegion(w io.Writer, m image.Image, r image.Rectangle) error {
+	var e Encoder
+	return e.EncodeRegion(w, m, r)
+}
+
+// EncodeRegion writes the part of m inside r to w in QOI format, as if it
+// were an image with bounds r. r is clipped to m's bounds.
+func (enc *Encoder) EncodeRegion(w io.Writer, m image.Image, r image.Rectangle) error {
+	return enc.Encode(w, subImage(m, r))
+}
+
+// subImage returns the part of m inside r. Images with a SubImage method,
+// including all of the image package's types, keep their concrete type so
+// the encoder's fast paths still apply.
+func subImage(m image.Image, r image.Rectangle) image.Image {
+	r = r.Intersect(m.Bounds())
+	if s, ok := m.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return regionImage{m, r}
+}
+
+// regionImage restricts an image to a smaller rectangle.
+type regionImage struct {
+	image.Image
+	r image.Rectangle
+}
+
+func (m regionImage) Bounds() image.Rectangle { return m.r }
+
+// DecodeRegion reads a QOI image from r and returns the part of it inside
+// rect, which is clipped to the image's bounds. The result has bounds rect,
+// as if it were a SubImage of the full image, but only the region is
+// allocated; other pixels are decoded and discarded. Reading stops once the
+// last row of the region is decoded, so the rest of the stream is neither
+// read nor checked.
+func DecodeRegion(r io.Reader, rect image.Rectangle) (image.Image, error) {
+	return Limits{}.DecodeRegion(r, rect)
+}
+
+// DecodeRegion is like the package-level DecodeRegion. The limits apply to
+// the full image, not the region.
+func (l Limits) DecodeRegion(r io.Reader, rect image.Rectangle) (image.Image, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := l.check(d.hdr); err != nil {
+		return nil, err
+	}
+	w, h := d.hdr.Width, d.hdr.Height
+	rect = rect.Intersect(image.Rect(0, 0, w, h))
+	if rect.Empty() {
+		return nil, errors.New("qoi: region does not overlap the image")
+	}
+
+	img := image.NewNRGBA(rect)
+	if err := d.skip(int64(rect.Min.Y) * int64(w)); err != nil {
+		return nil, err
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if err := d.skip(int64(rect.Min.X)); err != nil {
+			return nil, err
+		}
+		i := img.PixOffset(rect.Min.X, y)
+		if err := d.decodeSpan(img.Pix[i : i+4*rect.Dx()]); err != nil {
+			return nil, err
+		}
+		if y < rect.Max.Y-1 {
+			if err := d.skip(int64(w - rect.Max.X)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return img, nil
+}
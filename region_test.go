@@ -0,0 +1,74 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestEncodeRegion(t *testing.T) {
+	m := testImage(40, 30)
+	r := image.Rect(5, 3, 33, 21)
+	crop := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := range r.Dy() {
+		copy(crop.Pix[y*crop.Stride:], m.Pix[m.PixOffset(r.Min.X, r.Min.Y+y):][:4*r.Dx()])
+	}
+	want := encodeBytes(t, crop, nil)
+
+	for _, src := range []image.Image{m, generic{m}} {
+		var buf bytes.Buffer
+		if err := EncodeRegion(&buf, src, r); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%T: output differs from encoding a copy of the region", src)
+		}
+	}
+
+	// The region is clipped to the image.
+	var buf bytes.Buffer
+	if err := EncodeRegion(&buf, m, image.Rect(30, 20, 100, 100)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := DecodeHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 10 || h.Height != 10 {
+		t.Errorf("clipped region is %dx%d, want 10x10", h.Width, h.Height)
+	}
+
+	if err := EncodeRegion(&buf, m, image.Rect(50, 50, 60, 60)); err == nil {
+		t.Error("empty region encoded without error")
+	}
+}
+
+func TestDecodeRegion(t *testing.T) {
+	m := testImage(40, 30)
+	data := encodeBytes(t, m, nil)
+	for _, r := range []image.Rectangle{
+		image.Rect(5, 3, 33, 21),
+		image.Rect(0, 0, 40, 30),
+		image.Rect(39, 29, 40, 30),
+		image.Rect(-10, 25, 10, 100),
+	} {
+		got, err := DecodeRegion(bytes.NewReader(data), r)
+		if err != nil {
+			t.Fatalf("%v: %v", r, err)
+		}
+		want := m.SubImage(r)
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("%v: got bounds %v, want %v", r, got.Bounds(), want.Bounds())
+		}
+		samePixels(t, got, want)
+	}
+
+	// Data after the region is never needed.
+	r := image.Rect(0, 0, 40, 10)
+	if _, err := DecodeRegion(bytes.NewReader(data[:len(data)/2]), r); err != nil {
+		t.Errorf("truncated after the region: %v", err)
+	}
+	if _, err := DecodeRegion(bytes.NewReader(data), image.Rect(50, 0, 60, 10)); err == nil {
+		t.Error("region outside the image decoded without error")
+	}
+}
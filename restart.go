@@ -0,0 +1,149 @@
+package qoi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// Restart points let a stripe of rows be decoded without the chunks before
+// it. At a restart point the encoder ends any run and writes the next pixel
+// as an RGBA chunk, so the stripe does not depend on the previous pixel, and
+// it never refers to index entries set before the restart point. The
+// stream stays a valid QOI image.
+//
+// The restart points follow the end marker and any metadata blocks as a
+// trailer that is read from the end of the data:
+//
+//	offsets  [n]uint64  stream offset of the first chunk of stripes 1 to n
+//	interval uint32     rows per stripe
+//	n        uint32
+//	magic    "qoix"
+//
+// All integers are big-endian.
+const restartMagic = "qoix"
+
+// restart starts a new stripe at the next pixel.
+func (e *encoder) restart() {
+	e.flushRun()
+	e.restarts = append(e.restarts, e.written())
+	e.literal = true
+	// Fill each index slot with a color that hashes to a different slot,
+	// so that it can never match a pixel.
+	for i := range e.index {
+		e.index[i] = color.NRGBA{}
+		if i == 0 {
+			e.index[i].R = 1
+		}
+	}
+}
+
+// writeRestarts appends the restart trailer to e.buf.
+func (e *encoder) writeRestarts() {
+	for _, off := range e.restarts {
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(off))
+	}
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(e.enc.RestartInterval))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(len(e.restarts)))
+	e.buf = append(e.buf, restartMagic...)
+}
+
+// readRestarts returns the restart points recorded in data, which holds an
+// image with header h, or nil if there are none.
+func readRestarts(data []byte, h Header) (interval int, offsets []int64, err error) {
+	if len(data) < headerLen+endLen+12 || string(data[len(data)-4:]) != restartMagic {
+		return 0, nil, nil
+	}
+	n := binary.BigEndian.Uint32(data[len(data)-8:])
+	k := binary.BigEndian.Uint32(data[len(data)-12:])
+	start := len(data) - 12 - 8*int(n)
+	if k == 0 || uint64(n) > uint64(len(data))/8 || start < headerLen+endLen ||
+		uint64(n) != (uint64(h.Height)-1)/uint64(k) {
+		return 0, nil, FormatError("invalid restart points")
+	}
+	offsets = make([]int64, n)
+	prev := uint64(headerLen)
+	for i := range offsets {
+		off := binary.BigEndian.Uint64(data[start+8*i:])
+		if off <= prev || off >= uint64(start-endLen) {
+			return 0, nil, FormatError("invalid restart points")
+		}
+		offsets[i], prev = int64(off), off
+	}
+	return int(k), offsets, nil
+}
+
+// DecodeParallel decodes the QOI image in data, using up to workers
+// goroutines if the image was encoded with Encoder.RestartInterval set.
+// Images without restart points are decoded as Decode would.
+func DecodeParallel(data []byte, workers int) (image.Image, error) {
+	return Limits{}.DecodeParallel(data, workers)
+}
+
+// DecodeParallel is like the package-level DecodeParallel.
+func (l Limits) DecodeParallel(data []byte, workers int) (image.Image, error) {
+	h, err := DecodeHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := l.check(h); err != nil {
+		return nil, err
+	}
+	interval, offsets, err := readRestarts(data, h)
+	if err != nil {
+		return nil, err
+	}
+	if offsets == nil || workers <= 1 {
+		return Decode(bytes.NewReader(data))
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, h.Width, h.Height))
+	stripes := make(chan int)
+	errs := make([]error, len(offsets)+1)
+	var wg sync.WaitGroup
+	for range min(workers, len(errs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range stripes {
+				errs[s] = decodeStripe(data, h, img, s, interval, offsets)
+			}
+		}()
+	}
+	for s := range errs {
+		stripes <- s
+	}
+	close(stripes)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// decodeStripe decodes stripe s of the image in data into img.
+func decodeStripe(data []byte, h Header, img *image.NRGBA, s, interval int, offsets []int64) error {
+	off := int64(headerLen)
+	if s > 0 {
+		off = offsets[s-1]
+	}
+	d := newDecoder(bytes.NewReader(data[off:]))
+	d.hdr = h
+	d.n = off
+	y0, y1 := s*interval, min((s+1)*interval, h.Height)
+	d.pixel = int64(y0) * int64(h.Width)
+	if err := d.decodeSpan(img.Pix[y0*img.Stride : y1*img.Stride]); err != nil {
+		return err
+	}
+	if y1 == h.Height {
+		return d.readEnd()
+	}
+	if d.run > 0 || d.n != offsets[s] {
+		return d.errorAt(-1, FormatError("stripe does not end at a restart point"))
+	}
+	return nil
+}
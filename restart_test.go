@@ -0,0 +1,105 @@
+package qoi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"testing"
+)
+
+func TestDecodeParallel(t *testing.T) {
+	src := testImage(53, 50)
+	pal := image.NewPaletted(src.Rect, palette.Plan9)
+	draw.Draw(pal, pal.Rect, src, image.Point{}, draw.Src)
+
+	for _, tt := range []struct {
+		m   image.Image
+		enc Encoder
+	}{
+		{src, Encoder{RestartInterval: 7}},
+		{src, Encoder{RestartInterval: 1, Channels: RGB}},
+		{src, Encoder{RestartInterval: 50}},
+		{pal, Encoder{RestartInterval: 4, Workers: 2}},
+	} {
+		data := encodeBytes(t, tt.m, &tt.enc)
+
+		// Other decoders see a plain QOI image.
+		want, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%+v: Decode: %v", tt.enc, err)
+		}
+		plain := tt.enc
+		plain.RestartInterval = 0
+		ref, err := Decode(bytes.NewReader(encodeBytes(t, tt.m, &plain)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, want, ref)
+		if err := Validate(bytes.NewReader(data)); err != nil {
+			t.Errorf("%+v: Validate: %v", tt.enc, err)
+		}
+
+		for _, workers := range []int{1, 3, 100} {
+			got, err := DecodeParallel(data, workers)
+			if err != nil {
+				t.Fatalf("%+v, %d workers: %v", tt.enc, workers, err)
+			}
+			if !bytes.Equal(got.(*image.NRGBA).Pix, want.(*image.NRGBA).Pix) {
+				t.Errorf("%+v, %d workers: pixels differ from Decode", tt.enc, workers)
+			}
+		}
+	}
+
+	// Without restart points, DecodeParallel decodes sequentially.
+	data := encodeBytes(t, src, nil)
+	got, err := DecodeParallel(data, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, src)
+}
+
+func TestDecodeParallelInvalid(t *testing.T) {
+	data := encodeBytes(t, testImage(20, 20), &Encoder{RestartInterval: 5})
+	n := binary.BigEndian.Uint32(data[len(data)-8:])
+	first := len(data) - 12 - 8*int(n)
+
+	for name, corrupt := range map[string]func([]byte){
+		"wrong count":    func(b []byte) { b[len(b)-5]++ },
+		"zero interval":  func(b []byte) { binary.BigEndian.PutUint32(b[len(b)-12:], 0) },
+		"offset order":   func(b []byte) { binary.BigEndian.PutUint64(b[first:], 1<<40) },
+		"shifted offset": func(b []byte) { b[first+7]++ },
+	} {
+		b := bytes.Clone(data)
+		corrupt(b)
+		if _, err := DecodeParallel(b, 4); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
+
+func TestDecodeParallelWriter(t *testing.T) {
+	m := testImage(20, 20)
+	enc := &Encoder{RestartInterval: 5}
+	var buf bytes.Buffer
+	qw, err := NewWriter(&buf, 20, 20, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qw.Write(m.Pix); err != nil {
+		t.Fatal(err)
+	}
+	if err := qw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeParallel(buf.Bytes(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, m)
+	if !bytes.Equal(buf.Bytes(), encodeBytes(t, m, nil)) {
+		t.Error("Writer with RestartInterval differs from a plain encoding")
+	}
+}
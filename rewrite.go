@@ -0,0 +1,59 @@
+package qoi
+
+import (
+	"bytes"
+	"io"
+)
+
+// RewriteHeader copies the QOI stream in src to dst, replacing the channel
+// count and colorspace in its header, as when correcting a wrongly tagged
+// colorspace. The chunks and anything after them are copied unchanged, so
+// the pixels still decode to the same values: in particular, marking an
+// image with translucent pixels as RGB does not drop their alpha.
+func RewriteHeader(dst io.Writer, src io.Reader, c Channels, cs ColorSpace) error {
+	if err := checkHeaderFields(c, cs); err != nil {
+		return err
+	}
+	d := newDecoder(src)
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+	d.tmp[12], d.tmp[13] = byte(c), byte(cs)
+	if _, err := dst.Write(d.tmp[:headerLen]); err != nil {
+		return err
+	}
+	_, err := io.Copy(dst, d.r)
+	return err
+}
+
+// RewriteHeaderAt is like RewriteHeader, but changes the header of the
+// stream stored in f in place, writing only the two bytes that change. This
+// makes fixing a large file cheap.
+func RewriteHeaderAt(f interface {
+	io.ReaderAt
+	io.WriterAt
+}, c Channels, cs ColorSpace) error {
+	if err := checkHeaderFields(c, cs); err != nil {
+		return err
+	}
+	var hdr [headerLen]byte
+	if n, err := f.ReadAt(hdr[:], 0); n < headerLen {
+		return truncated(err)
+	}
+	if err := newDecoder(bytes.NewReader(hdr[:])).readHeader(); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte{byte(c), byte(cs)}, 12)
+	return err
+}
+
+// checkHeaderFields reports whether c and cs may be stored in a header.
+func checkHeaderFields(c Channels, cs ColorSpace) error {
+	if c != RGB && c != RGBA {
+		return &kindError{ErrBadHeader, FormatError("invalid channels")}
+	}
+	if cs != SRGB && cs != Linear {
+		return &kindError{ErrBadHeader, FormatError("invalid colorspace")}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteHeader(t *testing.T) {
+	m := testImage(11, 6)
+	data := encodeBytes(t, m, &Encoder{Metadata: &Metadata{Text: map[string]string{"k": "v"}}})
+
+	var buf bytes.Buffer
+	if err := RewriteHeader(&buf, bytes.NewReader(data), RGBA, Linear); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+	if !bytes.Equal(got[:12], data[:12]) || !bytes.Equal(got[headerLen:], data[headerLen:]) {
+		t.Error("RewriteHeader changed more than the channels and colorspace")
+	}
+	h, err := DecodeHeader(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Channels != RGBA || h.ColorSpace != Linear {
+		t.Errorf("got header %+v", h)
+	}
+	dm, err := Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, dm, m)
+}
+
+func TestRewriteHeaderAt(t *testing.T) {
+	data := encodeBytes(t, testImage(4, 4), nil)
+	name := filepath.Join(t.TempDir(), "img.qoi")
+	if err := os.WriteFile(name, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := RewriteHeaderAt(f, RGB, Linear); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Clone(data)
+	want[12], want[13] = byte(RGB), byte(Linear)
+	if !bytes.Equal(got, want) {
+		t.Error("RewriteHeaderAt wrote the wrong bytes")
+	}
+}
+
+func TestRewriteHeaderInvalid(t *testing.T) {
+	data := encodeBytes(t, testImage(4, 4), nil)
+	if err := RewriteHeader(new(bytes.Buffer), bytes.NewReader(data), 5, SRGB); !errors.Is(err, ErrBadHeader) {
+		t.Errorf("invalid channels: got %v, want ErrBadHeader", err)
+	}
+	if err := RewriteHeader(new(bytes.Buffer), bytes.NewReader(data), RGB, 2); !errors.Is(err, ErrBadHeader) {
+		t.Errorf("invalid colorspace: got %v, want ErrBadHeader", err)
+	}
+	if err := RewriteHeader(new(bytes.Buffer), bytes.NewReader([]byte("qoif")), RGB, SRGB); !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated header: got %v, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,115 @@
+package qoi
+
+import (
+	"image"
+	"image/color"
+)
+
+// RGBImage is an in-memory image of opaque pixels stored as packed RGB
+// triples, using three quarters of the memory of an *image.NRGBA. Its
+// pixels are reported as fully opaque color.NRGBA values.
+type RGBImage struct {
+	// Pix holds the image's pixels, in R, G, B order. The pixel at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
+	Pix []uint8
+	// Stride is the Pix stride (in bytes) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewRGBImage returns a new RGBImage with the given bounds.
+func NewRGBImage(r image.Rectangle) *RGBImage {
+	w, h := r.Dx(), r.Dy()
+	return &RGBImage{Pix: make([]uint8, 3*w*h), Stride: 3 * w, Rect: r}
+}
+
+func (p *RGBImage) ColorModel() color.Model { return RGBModel }
+
+func (p *RGBImage) Bounds() image.Rectangle { return p.Rect }
+
+func (p *RGBImage) At(x, y int) color.Color {
+	return p.NRGBAAt(x, y)
+}
+
+// NRGBAAt returns the color of the pixel at (x, y).
+func (p *RGBImage) NRGBAAt(x, y int) color.NRGBA {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.NRGBA{}
+	}
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+3 : i+3]
+	return color.NRGBA{s[0], s[1], s[2], 0xff}
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds
+// to the pixel at (x, y).
+func (p *RGBImage) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*3
+}
+
+// Set sets the pixel at (x, y) to c, dropping its alpha the way RGBModel
+// does.
+func (p *RGBImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	n := RGBModel.Convert(c).(color.NRGBA)
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+3 : i+3]
+	s[0], s[1], s[2] = n.R, n.G, n.B
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *RGBImage) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &RGBImage{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &RGBImage{Pix: p.Pix[i:], Stride: p.Stride, Rect: r}
+}
+
+// Opaque reports whether the image is fully opaque, which it always is.
+func (p *RGBImage) Opaque() bool { return true }
+
+// packRGB copies the RGB bytes of the NRGBA pixels in src to dst.
+func packRGB(dst, src []byte) {
+	for i, j := 0, 0; i < len(src); i, j = i+4, j+3 {
+		s := src[i : i+3 : i+3]
+		d := dst[j : j+3 : j+3]
+		d[0], d[1], d[2] = s[0], s[1], s[2]
+	}
+}
+
+// decodeRGB decodes the pixels and end marker into img, which must have
+// bounds (0, 0)-(d.hdr.Width, d.hdr.Height), bottom row first if flip is
+// set. If post is not nil, it is applied to each decoded row before the row
+// is packed.
+func (d *decoder) decodeRGB(img *RGBImage, flip bool, post func(row []byte)) error {
+	row := make([]byte, 4*d.hdr.Width)
+	for y := 0; y < d.hdr.Height; y++ {
+		dst := img.Pix[y*img.Stride:]
+		if flip {
+			dst = img.Pix[(d.hdr.Height-1-y)*img.Stride:]
+		}
+		if err := d.decodeSpan(row); err != nil {
+			// Keep the pixels of this row that were decoded.
+			row = row[:4*(d.pixel-int64(y)*int64(d.hdr.Width))]
+			if post != nil {
+				post(row)
+			}
+			packRGB(dst, row)
+			return err
+		}
+		if post != nil {
+			post(row)
+		}
+		packRGB(dst, row)
+		if err := d.rowDone(y); err != nil {
+			return err
+		}
+	}
+	return d.readEnd()
+}
@@ -0,0 +1,51 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestDecodePackRGB(t *testing.T) {
+	m := testImage(37, 21)
+	opts := &DecodeOptions{PackRGB: true}
+
+	data := encodeBytes(t, m, &Encoder{Channels: RGB})
+	got, err := opts.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgb, ok := got.(*RGBImage)
+	if !ok {
+		t.Fatalf("got %T, want *RGBImage", got)
+	}
+	if len(rgb.Pix) != 3*37*21 {
+		t.Errorf("len(Pix) = %d, want %d", len(rgb.Pix), 3*37*21)
+	}
+	want, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, rgb, want)
+
+	// Images with alpha are decoded as usual.
+	got, err = opts.Decode(bytes.NewReader(encodeBytes(t, m, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*image.NRGBA); !ok {
+		t.Errorf("4-channel image decoded as %T, want *image.NRGBA", got)
+	}
+
+	opts.KeepPartial = true
+	got, err = opts.Decode(bytes.NewReader(data[:len(data)/2]))
+	if err == nil {
+		t.Fatal("truncated stream decoded without error")
+	}
+	rgb = got.(*RGBImage)
+	for x := range 37 {
+		if rgb.NRGBAAt(x, 0) != want.(*image.NRGBA).NRGBAAt(x, 0) {
+			t.Fatalf("first row was not kept")
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package qoi
+
+import (
+	"image"
+	"image/color"
+)
+
+// loadRow returns row y of e.m as non-premultiplied RGBA bytes. The result
+// may alias the image's pixels or e.row, so it is only valid until the next
+// call.
+func (e *encoder) loadRow(y int) []byte {
+	b := e.m.Bounds()
+	n := 4 * b.Dx()
+	if m, ok := e.m.(*image.NRGBA); ok {
+		i := m.PixOffset(b.Min.X, y)
+		return m.Pix[i : i+n : i+n]
+	}
+	row := e.rowBuf(n)
+	readRow(e.m, y, row)
+	return row
+}
+
+// readRow converts row y of m to non-premultiplied RGBA bytes in row, which
+// must hold 4×m.Bounds().Dx() bytes. It only reads m, so it may be called
+// for different rows concurrently.
+func readRow(m image.Image, y int, row []byte) {
+	b := m.Bounds()
+	n := len(row)
+
+	switch m := m.(type) {
+	case *image.NRGBA:
+		i := m.PixOffset(b.Min.X, y)
+		copy(row, m.Pix[i:i+n])
+		return
+	case *image.RGBA:
+		i := m.PixOffset(b.Min.X, y)
+		unpremultiplyRow(row, m.Pix[i:i+n])
+		return
+	case *image.Gray:
+		i := m.PixOffset(b.Min.X, y)
+		for j, v := range m.Pix[i : i+b.Dx()] {
+			p := row[4*j : 4*j+4 : 4*j+4]
+			p[0], p[1], p[2], p[3] = v, v, v, 0xff
+		}
+		return
+	case *image.Gray16:
+		// The high byte of each big-endian sample is what color.NRGBAModel
+		// would keep.
+		i := m.PixOffset(b.Min.X, y)
+		src := m.Pix[i : i+2*b.Dx()]
+		for j := 0; j < len(src); j += 2 {
+			v := src[j]
+			p := row[2*j : 2*j+4 : 2*j+4]
+			p[0], p[1], p[2], p[3] = v, v, v, 0xff
+		}
+		return
+	case *image.YCbCr:
+		for x, i := b.Min.X, 0; i < n; x, i = x+1, i+4 {
+			yi, ci := m.YOffset(x, y), m.COffset(x, y)
+			r, g, bb := color.YCbCrToRGB(m.Y[yi], m.Cb[ci], m.Cr[ci])
+			p := row[i : i+4 : i+4]
+			p[0], p[1], p[2], p[3] = r, g, bb, 0xff
+		}
+		return
+	case *image.CMYK:
+		i := m.PixOffset(b.Min.X, y)
+		src := m.Pix[i : i+n]
+		for j := 0; j < n; j += 4 {
+			s := src[j : j+4 : j+4]
+			r, g, bb := color.CMYKToRGB(s[0], s[1], s[2], s[3])
+			p := row[j : j+4 : j+4]
+			p[0], p[1], p[2], p[3] = r, g, bb, 0xff
+		}
+		return
+	case *RGBImage:
+		i := m.PixOffset(b.Min.X, y)
+		PixelRGB.nrgbaRow(row, m.Pix[i:i+3*b.Dx()])
+		return
+	case *rawImage:
+		m.format.nrgbaRow(row, m.row(y))
+		return
+	}
+
+	if m, ok := m.(image.RGBA64Image); ok {
+		for x, i := b.Min.X, 0; i < n; x, i = x+1, i+4 {
+			c := unpremultiply64(m.RGBA64At(x, y))
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		return
+	}
+	for x, i := b.Min.X, 0; i < n; x, i = x+1, i+4 {
+		c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+		row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+// loadPalette fills e.pal and e.palHash from p. Indices beyond the palette
+// map to transparent black.
+func (e *encoder) loadPalette(p color.Palette) {
+	for i := range e.pal {
+		var c color.NRGBA
+		if i < len(p) {
+			c = color.NRGBAModel.Convert(p[i]).(color.NRGBA)
+		}
+		if e.channels == RGB {
+			c.A = 0xff
+		}
+		if e.zero && c.A == 0 {
+			c = color.NRGBA{}
+		}
+		e.pal[i] = c
+		e.palHash[i] = uint8(hash(c))
+	}
+}
+
+// rowBuf returns e.row resized to n bytes.
+func (e *encoder) rowBuf(n int) []byte {
+	if cap(e.row) < n {
+		e.row = make([]byte, n)
+	}
+	e.row = e.row[:n]
+	return e.row
+}
+
+// unpremultiplyRow converts premultiplied RGBA bytes in src to
+// non-premultiplied bytes in dst, rounding exactly as color.NRGBAModel does.
+func unpremultiplyRow(dst, src []byte) {
+	for i := 0; i < len(src); i += 4 {
+		s := src[i : i+4 : i+4]
+		d := dst[i : i+4 : i+4]
+		switch a := uint32(s[3]); a {
+		case 0xff:
+			d[0], d[1], d[2], d[3] = s[0], s[1], s[2], 0xff
+		case 0:
+			d[0], d[1], d[2], d[3] = 0, 0, 0, 0
+		default:
+			a |= a << 8
+			d[0] = uint8((uint32(s[0]) * 0x101 * 0xffff / a) >> 8)
+			d[1] = uint8((uint32(s[1]) * 0x101 * 0xffff / a) >> 8)
+			d[2] = uint8((uint32(s[2]) * 0x101 * 0xffff / a) >> 8)
+			d[3] = s[3]
+		}
+	}
+}
+
+// unpremultiply64 converts c to non-premultiplied 8-bit color, rounding
+// exactly as color.NRGBAModel does.
+func unpremultiply64(c color.RGBA64) color.NRGBA {
+	r, g, b, a := uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+	switch a {
+	case 0xffff:
+		return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+	case 0:
+		return color.NRGBA{}
+	}
+	r = (r * 0xffff) / a
+	g = (g * 0xffff) / a
+	b = (b * 0xffff) / a
+	return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// premultiplyRowGeneric converts non-premultiplied RGBA bytes in b to
+// premultiplied bytes in place, rounding exactly as color.RGBAModel does.
+//
+// color.RGBAModel computes c*0x101*a/0xff>>8 for each channel c; over the
+// products c*a of 8-bit values that equals c*a*0x8101>>23, which needs no
+// division and fits in 16-bit vector lanes as a high multiply and a shift.
+func premultiplyRowGeneric(b []byte) {
+	for i := 0; i < len(b); i += 4 {
+		p := b[i : i+4 : i+4]
+		a := uint32(p[3])
+		if a == 0xff {
+			continue
+		}
+		p[0] = uint8(uint32(p[0]) * a * 0x8101 >> 23)
+		p[1] = uint8(uint32(p[1]) * a * 0x8101 >> 23)
+		p[2] = uint8(uint32(p[2]) * a * 0x8101 >> 23)
+	}
+}
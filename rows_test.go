@@ -0,0 +1,132 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"strconv"
+	"testing"
+)
+
+// generic hides the concrete type of an image so the encoder takes its
+// generic At path.
+type generic struct{ image.Image }
+
+func TestEncodeFastPaths(t *testing.T) {
+	src := testImage(45, 19)
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, image.Point{}, draw.Src)
+
+	var ycbcr []image.Image
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	} {
+		m := image.NewYCbCr(src.Bounds(), ratio)
+		for i := range m.Y {
+			m.Y[i] = uint8(i * 7)
+		}
+		for i := range m.Cb {
+			m.Cb[i], m.Cr[i] = uint8(i*3), uint8(255-i*5)
+		}
+		ycbcr = append(ycbcr, m, m.SubImage(image.Rect(3, 2, 40, 17)))
+	}
+
+	pal := image.NewPaletted(src.Bounds(), palette.Plan9[:200])
+	draw.Draw(pal, pal.Rect, src, image.Point{}, draw.Src)
+	pal.Palette = append(pal.Palette, color.NRGBA{0x10, 0x20, 0x30, 0x40})
+	pal.Pix[5] = 200
+
+	for _, m := range append([]image.Image{
+		src,
+		src.SubImage(image.Rect(3, 2, 40, 17)),
+		rgba,
+		rgba.SubImage(image.Rect(3, 2, 40, 17)),
+		convertImage(image.NewGray(src.Bounds()), src),
+		convertImage(image.NewGray(src.Bounds()), src).(*image.Gray).SubImage(image.Rect(3, 2, 40, 17)),
+		convertImage(image.NewGray16(src.Bounds()), src),
+		convertImage(image.NewGray16(src.Bounds()), src).(*image.Gray16).SubImage(image.Rect(3, 2, 40, 17)),
+		convertImage(image.NewNRGBA64(src.Bounds()), src),
+		convertImage(image.NewAlpha(src.Bounds()), src),
+		convertImage(image.NewCMYK(src.Bounds()), src),
+		convertImage(image.NewCMYK(src.Bounds()), src).(*image.CMYK).SubImage(image.Rect(3, 2, 40, 17)),
+		convertImage(NewRGBImage(src.Bounds()), src),
+		convertImage(NewRGBImage(src.Bounds()), src).(*RGBImage).SubImage(image.Rect(3, 2, 40, 17)),
+		pal,
+		pal.SubImage(image.Rect(3, 2, 40, 17)),
+	}, ycbcr...) {
+		for _, enc := range []*Encoder{nil, {Channels: RGB}} {
+			want := encodeBytes(t, generic{m}, enc)
+			if got := encodeBytes(t, m, enc); !bytes.Equal(got, want) {
+				t.Errorf("%T %v, %+v: fast path output differs from the generic path", m, m.Bounds(), enc)
+			}
+		}
+	}
+}
+
+// convertImage draws src onto dst and returns dst.
+func convertImage(dst draw.Image, src image.Image) draw.Image {
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+	return dst
+}
+
+func TestEncodeWorkers(t *testing.T) {
+	src := testImage(45, 67)
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, image.Point{}, draw.Src)
+	for _, m := range []image.Image{src, rgba, generic{src}, generic{image.NewNRGBA(image.Rect(0, 0, 3, 1))}} {
+		for _, enc := range []Encoder{{Workers: 4}, {Workers: 3, FlipVertical: true}, {Workers: 64}} {
+			plain := enc
+			plain.Workers = 0
+			want := encodeBytes(t, m, &plain)
+			if got := encodeBytes(t, m, &enc); !bytes.Equal(got, want) {
+				t.Errorf("%T, %+v: output differs from encoding on one goroutine", m, enc)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeWorkers(b *testing.B) {
+	m := generic{testImage(512, 512)}
+	for _, workers := range []int{1, 4} {
+		enc := &Encoder{Workers: workers}
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.SetBytes(512 * 512 * 4)
+			for range b.N {
+				if err := enc.Encode(io.Discard, m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPremultiplyRow(t *testing.T) {
+	// Every color and alpha pair, with rows of all lengths so that both
+	// the vector loop and its tail see them.
+	var row []byte
+	for a := range 256 {
+		for c := range 256 {
+			row = append(row, uint8(c), uint8(255-c), uint8(c/2), uint8(a))
+		}
+	}
+	for _, f := range []func([]byte){premultiplyRow, premultiplyRowGeneric} {
+		for _, n := range []int{len(row), 4, 8, 12, 20, 36} {
+			b := append([]byte(nil), row[:n]...)
+			f(b)
+			for i := 0; i < n; i += 4 {
+				want := color.RGBAModel.Convert(color.NRGBA{row[i], row[i+1], row[i+2], row[i+3]})
+				if got := (color.RGBA{b[i], b[i+1], b[i+2], b[i+3]}); got != want {
+					t.Fatalf("%v: got %v, want %v", row[i:i+4], got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Salvage decodes as much of the QOI image in r as it can, for recovering
+// damaged files. Pixels past the point where the stream goes bad are set to
+// fill, or left transparent black if fill is nil.
+//
+// If the header is readable, Salvage always returns an image. The error then
+// reports what was wrong with the rest of the stream; unlike Decode, it is a
+// *DecodeError even when the stream is only cut short. Its Offset is where
+// the corruption starts and its X and Y give the first pixel that could not
+// be decoded.
+func Salvage(r io.Reader, fill color.Color) (*image.NRGBA, error) {
+	return Limits{}.Salvage(r, fill)
+}
+
+// Salvage is like the package-level Salvage, but returns no image if the
+// header declares one larger than the limits.
+func (l Limits) Salvage(r io.Reader, fill color.Color) (*image.NRGBA, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := l.check(d.hdr); err != nil {
+		return nil, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, d.hdr.Width, d.hdr.Height))
+	err := d.decodePixels(img.Pix, img.Stride, false, nil)
+	var de *DecodeError
+	if err != nil && !errors.As(err, &de) {
+		err = d.decodeError(-1, err)
+	}
+	if err != nil && fill != nil {
+		c := color.NRGBAModel.Convert(fill).(color.NRGBA)
+		fillPixel(img.Pix[4*min(d.pixel, int64(d.hdr.Width)*int64(d.hdr.Height)):], c)
+	}
+	return img, err
+}
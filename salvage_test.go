@@ -0,0 +1,51 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestSalvage(t *testing.T) {
+	src := testImage(16, 16)
+	data := encodeBytes(t, src, nil)
+	cut := len(data) / 2
+	fill := color.NRGBA{0xff, 0, 0xff, 0xff}
+
+	m, err := Salvage(bytes.NewReader(data[:cut]), fill)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("got error %v, want a *DecodeError", err)
+	}
+	if de.Offset > int64(cut) {
+		t.Errorf("corruption offset %d is past the end of the data (%d)", de.Offset, cut)
+	}
+	if m == nil {
+		t.Fatal("Salvage returned no image")
+	}
+	first := de.Y*16 + de.X
+	if first == 0 {
+		t.Fatal("no pixels were recovered")
+	}
+	for i := range 16 * 16 {
+		x, y := i%16, i/16
+		got := m.NRGBAAt(x, y)
+		want := fill
+		if i < first {
+			want = color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+		}
+		if got != want {
+			t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+		}
+	}
+
+	m, err = Salvage(bytes.NewReader(data), fill)
+	if err != nil {
+		t.Fatalf("intact stream: %v", err)
+	}
+	samePixels(t, m, src)
+	if m, err := Salvage(bytes.NewReader(data[:10]), fill); m != nil || !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated header: got %v, %v", m, err)
+	}
+}
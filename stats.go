@@ -0,0 +1,79 @@
+package qoi
+
+import "strconv"
+
+// ChunkType is the kind of a QOI chunk.
+type ChunkType uint8
+
+// Chunk types, in the order the specification lists them.
+const (
+	ChunkRGB ChunkType = iota
+	ChunkRGBA
+	ChunkIndex
+	ChunkDiff
+	ChunkLuma
+	ChunkRun
+
+	numChunkTypes
+)
+
+var chunkNames = [numChunkTypes]string{
+	"QOI_OP_RGB", "QOI_OP_RGBA", "QOI_OP_INDEX", "QOI_OP_DIFF", "QOI_OP_LUMA", "QOI_OP_RUN",
+}
+
+// String returns the name the specification gives the chunk type, such as
+// "QOI_OP_RGB".
+func (t ChunkType) String() string {
+	if t < numChunkTypes {
+		return chunkNames[t]
+	}
+	return "ChunkType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// chunkType returns the type of the chunk that starts with tag.
+func chunkType(tag byte) ChunkType {
+	switch {
+	case tag == opRGB:
+		return ChunkRGB
+	case tag == opRGBA:
+		return ChunkRGBA
+	case tag&opMask == opIndex:
+		return ChunkIndex
+	case tag&opMask == opDiff:
+		return ChunkDiff
+	case tag&opMask == opLuma:
+		return ChunkLuma
+	default:
+		return ChunkRun
+	}
+}
+
+// EncodeStats describes how an image was encoded.
+type EncodeStats struct {
+	// Chunks and Bytes hold the number of chunks of each type and the
+	// bytes they take, indexed by ChunkType.
+	Chunks [numChunkTypes]int64
+	Bytes  [numChunkTypes]int64
+
+	// Pixels is the number of pixels in the image, and InputSize the
+	// size of their samples uncompressed, with as many bytes per pixel as
+	// the header's channel count.
+	Pixels    int64
+	InputSize int64
+
+	// OutputSize is the length of the encoded stream, including the
+	// header, the end marker and anything written after it.
+	OutputSize int64
+}
+
+// Ratio returns the compression ratio, OutputSize/InputSize.
+func (s *EncodeStats) Ratio() float64 {
+	return float64(s.OutputSize) / float64(s.InputSize)
+}
+
+// count records the chunk in b.
+func (s *EncodeStats) count(b []byte) {
+	t := chunkType(b[0])
+	s.Chunks[t]++
+	s.Bytes[t] += int64(len(b))
+}
@@ -0,0 +1,63 @@
+package qoi
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// chunkLen is the length of each type of chunk.
+var chunkLen = [numChunkTypes]int{4, 5, 1, 1, 2, 1}
+
+func TestEncodeStats(t *testing.T) {
+	m := testImage(40, 30)
+	for y := 10; y < 20; y++ {
+		for x := range 40 {
+			m.SetNRGBA(x, y, color.NRGBA{9, 9, 9, 0xff})
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		m    image.Image
+		enc  Encoder
+	}{
+		{"RGBA", m, Encoder{}},
+		{"RGB", m, Encoder{Channels: RGB}},
+		{"restarts", m, Encoder{RestartInterval: 7}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *EncodeStats
+			tc.enc.Stats = func(s *EncodeStats) { got = s }
+			data := encodeBytes(t, tc.m, &tc.enc)
+			if got == nil {
+				t.Fatal("Stats was not called")
+			}
+
+			// Count the chunks independently.
+			var want EncodeStats
+			pix := data[headerLen:]
+			for n := int64(0); n < 40*30; {
+				ct := chunkType(pix[0])
+				want.Chunks[ct]++
+				want.Bytes[ct] += int64(chunkLen[ct])
+				n++
+				if ct == ChunkRun {
+					n += int64(pix[0] & 0x3f)
+				}
+				pix = pix[chunkLen[ct]:]
+			}
+			want.Pixels = 40 * 30
+			want.InputSize = want.Pixels * int64(tc.enc.Channels)
+			if tc.enc.Channels == 0 {
+				want.InputSize = want.Pixels * 4
+			}
+			want.OutputSize = int64(len(data))
+			if *got != want {
+				t.Errorf("got %+v\nwant %+v", *got, want)
+			}
+			if r := got.Ratio(); r <= 0 || r >= 1 {
+				t.Errorf("got ratio %v", r)
+			}
+		})
+	}
+}
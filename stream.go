@@ -0,0 +1,229 @@
+package qoi
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"iter"
+)
+
+// A Writer encodes an image whose pixels are supplied incrementally, such as
+// one row at a time, without holding the whole image in memory.
+type Writer struct {
+	e      encoder
+	enc    Encoder
+	width  int
+	left   int64 // pixels not yet written
+	tail   [4]byte
+	ntail  int
+	closed bool
+}
+
+// NewWriter returns a Writer that encodes a width×height image to w. If
+// opts is nil, the defaults of Encode are used; opts.BufferPool and
+// opts.RestartInterval are ignored. Pixels are written as non-premultiplied
+// RGBA bytes, top row first, and the image is complete once Close returns
+// nil.
+func NewWriter(w io.Writer, width, height int, opts *Encoder) (*Writer, error) {
+	if err := checkSize(int64(width), int64(height)); err != nil {
+		return nil, err
+	}
+	qw := &Writer{width: width, left: int64(width) * int64(height)}
+	if opts != nil {
+		qw.enc = *opts
+	}
+	// A Writer does not see rows as such, so it records no restart points,
+	// and an empty restart trailer would be invalid.
+	qw.enc.RestartInterval = 0
+	if err := qw.enc.Metadata.check(); err != nil {
+		return nil, err
+	}
+	qw.e.enc = &qw.enc
+	qw.e.w = w
+	qw.e.reset()
+	qw.e.resetBuf()
+	qw.e.writeHeader(width, height)
+	return qw, nil
+}
+
+// Write encodes the pixels in p. Pixels may be split across calls.
+func (qw *Writer) Write(p []byte) (int, error) {
+	if qw.closed {
+		return 0, errors.New("qoi: write to closed Writer")
+	}
+	if qw.e.err != nil {
+		return 0, qw.e.err
+	}
+	if int64(qw.ntail+len(p)) > 4*qw.left {
+		return 0, errors.New("qoi: write past the end of the image")
+	}
+	n := len(p)
+
+	if qw.ntail > 0 {
+		k := copy(qw.tail[qw.ntail:], p)
+		qw.ntail += k
+		p = p[k:]
+		if qw.ntail < 4 {
+			return n, nil
+		}
+		qw.e.writePixels(qw.tail[:])
+		qw.ntail = 0
+		qw.left--
+	}
+	whole := len(p) &^ 3
+	qw.e.writePixels(p[:whole])
+	qw.left -= int64(whole / 4)
+	qw.ntail = copy(qw.tail[:], p[whole:])
+
+	if qw.e.err != nil {
+		return 0, qw.e.err
+	}
+	return n, nil
+}
+
+// WriteRow encodes one row of pixels. The row must hold exactly 4×width
+// bytes.
+func (qw *Writer) WriteRow(row []byte) error {
+	if len(row) != 4*qw.width {
+		return errors.New("qoi: row length does not match the image width")
+	}
+	_, err := qw.Write(row)
+	return err
+}
+
+// Close finishes the image by writing the end marker. It reports an error,
+// and leaves the output without an end marker, if fewer pixels than the
+// image holds were written. Close does not close the underlying writer.
+func (qw *Writer) Close() error {
+	if qw.closed {
+		return qw.e.err
+	}
+	qw.closed = true
+	if qw.e.err == nil && (qw.left > 0 || qw.ntail > 0) {
+		qw.e.flush()
+		if qw.e.err == nil {
+			qw.e.err = errors.New("qoi: Close before the image was complete")
+		}
+		return qw.e.err
+	}
+	qw.e.flushRun()
+	qw.e.writeEnd()
+	return qw.e.err
+}
+
+// NewReader reads the header of a QOI image from r and returns it with a
+// reader that yields the image's pixels as non-premultiplied RGBA bytes, top
+// row first with no padding between rows. Pixels are decoded as they are
+// read, so memory use does not depend on the image size. The pixel reader
+// returns io.EOF after the last pixel once the end marker has been read.
+func NewReader(r io.Reader) (Header, io.Reader, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, nil, err
+	}
+	pr := &pixelReader{d: d, left: int64(d.hdr.Width) * int64(d.hdr.Height)}
+	return d.hdr, pr, nil
+}
+
+type pixelReader struct {
+	d    *decoder
+	left int64 // pixels not yet decoded
+
+	// tail holds a pixel that was only partly read.
+	tail       [4]byte
+	toff, tlen int
+
+	err error
+}
+
+func (pr *pixelReader) Read(p []byte) (int, error) {
+	n := copy(p, pr.tail[pr.toff:pr.tlen])
+	pr.toff += n
+	p = p[n:]
+	if len(p) == 0 || pr.err != nil {
+		return n, pr.err
+	}
+
+	if pr.left == 0 {
+		pr.err = pr.d.readEnd()
+		if pr.err == nil {
+			pr.err = io.EOF
+		}
+		return n, pr.err
+	}
+
+	if whole := min(int64(len(p)/4), pr.left); whole > 0 {
+		if err := pr.d.decodeSpan(p[:4*whole]); err != nil {
+			pr.err = err
+			return n, err
+		}
+		pr.left -= whole
+		return n + 4*int(whole), nil
+	}
+
+	// p is too short for a whole pixel.
+	if err := pr.d.decodeSpan(pr.tail[:]); err != nil {
+		pr.err = err
+		return n, err
+	}
+	pr.left--
+	pr.toff = copy(p, pr.tail[:])
+	pr.tlen = 4
+	return n + pr.toff, nil
+}
+
+// DecodeRows reads a QOI image from r and calls fn with each row of pixels
+// in order, top row first. The row slice is reused between calls, so fn must
+// not retain it. If fn returns an error, DecodeRows stops and returns it.
+// Once the header has been read, the returned Header describes the image
+// even if decoding fails later.
+func DecodeRows(r io.Reader, fn func(y int, row []color.NRGBA) error) (Header, error) {
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return Header{}, err
+	}
+	row := make([]color.NRGBA, d.hdr.Width)
+	for y := 0; y < d.hdr.Height; y++ {
+		for x := range row {
+			if err := d.advance(); err != nil {
+				return d.hdr, err
+			}
+			row[x] = d.px
+		}
+		if err := fn(y, row); err != nil {
+			return d.hdr, err
+		}
+	}
+	return d.hdr, d.readEnd()
+}
+
+// EncodeSeq writes a width×height image whose pixels, in row order, are
+// produced by pixels. It is an error for pixels to yield more or fewer than
+// width×height values.
+func EncodeSeq(w io.Writer, width, height int, pixels iter.Seq[color.NRGBA]) error {
+	var e Encoder
+	return e.EncodeSeq(w, width, height, pixels)
+}
+
+// EncodeSeq writes a width×height image whose pixels, in row order, are
+// produced by pixels. It is an error for pixels to yield more or fewer than
+// width×height values.
+func (enc *Encoder) EncodeSeq(w io.Writer, width, height int, pixels iter.Seq[color.NRGBA]) error {
+	qw, err := NewWriter(w, width, height, enc)
+	if err != nil {
+		return err
+	}
+	var px [4]byte
+	for c := range pixels {
+		if qw.left == 0 {
+			return errors.New("qoi: pixel sequence is longer than the image")
+		}
+		px[0], px[1], px[2], px[3] = c.R, c.G, c.B, c.A
+		qw.e.writePixels(px[:])
+		qw.left--
+		if qw.e.err != nil {
+			return qw.e.err
+		}
+	}
+	return qw.Close()
+}
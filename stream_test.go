@@ -0,0 +1,174 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+	"iter"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	m := testImage(53, 21)
+	want := encodeBytes(t, m, &Encoder{ColorSpace: Linear})
+
+	// Rows, then odd-sized pieces that split pixels.
+	for _, split := range []int{4 * 53, 7, 1, 1 << 20} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, 53, 21, &Encoder{ColorSpace: Linear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for p := m.Pix; len(p) > 0; {
+			n := min(split, len(p))
+			if split == 4*53 {
+				err = w.WriteRow(p[:n])
+			} else {
+				_, err = w.Write(p[:n])
+			}
+			if err != nil {
+				t.Fatalf("split %d: %v", split, err)
+			}
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("split %d: Close: %v", split, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("split %d: output differs from Encode", split)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, 0, 1, nil); err == nil {
+		t.Error("NewWriter with zero width succeeded")
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 2, 2, nil)
+	if err := w.WriteRow(make([]byte, 4)); err == nil {
+		t.Error("WriteRow with a short row succeeded")
+	}
+	if _, err := w.Write(make([]byte, 17)); err == nil {
+		t.Error("Write past the end of the image succeeded")
+	}
+	if err := w.WriteRow(make([]byte, 8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close of an incomplete image succeeded")
+	}
+	if bytes.HasSuffix(buf.Bytes(), endMarker[:]) {
+		t.Error("incomplete image ends with an end marker")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	m := testImage(41, 27)
+	data := append(encodeBytes(t, m, nil), "trailer"...)
+
+	for _, size := range []int{1, 3, 5, 4 * 41, 1 << 16} {
+		br := bytes.NewReader(data)
+		h, pr, err := NewReader(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Width != 41 || h.Height != 27 {
+			t.Errorf("header: got %+v", h)
+		}
+		var got []byte
+		buf := make([]byte, size)
+		for {
+			n, err := pr.Read(buf)
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("size %d: %v", size, err)
+			}
+		}
+		if !bytes.Equal(got, m.Pix) {
+			t.Errorf("size %d: pixels differ", size)
+		}
+		if rest, _ := io.ReadAll(br); string(rest) != "trailer" {
+			t.Errorf("size %d: left %q unread, want %q", size, rest, "trailer")
+		}
+	}
+}
+
+func TestNewReaderTruncated(t *testing.T) {
+	data := encodeBytes(t, testImage(16, 16), nil)
+	_, pr, err := NewReader(bytes.NewReader(data[:len(data)/2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(pr); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeRows(t *testing.T) {
+	m := testImage(19, 23)
+	data := encodeBytes(t, m, nil)
+
+	next := 0
+	h, err := DecodeRows(bytes.NewReader(data), func(y int, row []color.NRGBA) error {
+		if y != next {
+			t.Fatalf("got row %d, want %d", y, next)
+		}
+		next++
+		for x, c := range row {
+			if want := m.NRGBAAt(x, y); c != want {
+				t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, c, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 23 || h.Height != 23 {
+		t.Errorf("got %d rows, header %+v", next, h)
+	}
+
+	stop := errors.New("stop")
+	_, err = DecodeRows(bytes.NewReader(data), func(y int, row []color.NRGBA) error {
+		if y == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("got %v, want the callback's error", err)
+	}
+}
+
+func TestEncodeSeq(t *testing.T) {
+	m := testImage(17, 14)
+	seq := func(n int) iter.Seq[color.NRGBA] {
+		return func(yield func(color.NRGBA) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(m.NRGBAAt(i%17, i/17)) {
+					return
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeSeq(&buf, 17, 14, seq(17*14)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), encodeBytes(t, m, nil)) {
+		t.Errorf("output differs from Encode")
+	}
+
+	for _, n := range []int{17*14 - 1, 17*14 + 1} {
+		if err := EncodeSeq(io.Discard, 17, 14, seq(n)); err == nil {
+			t.Errorf("%d pixels for a %d-pixel image: got no error", n, 17*14)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package qoi
+
+import (
+	"encoding/binary"
+	"image/color"
+)
+
+// The encoder's inner loop works on pixels packed into little-endian words,
+// R in the low byte, so that comparing, hashing and differencing a pixel
+// take a few word operations instead of one per channel. Scanning for runs,
+// the one step that looks at many pixels at once, has an assembly version
+// in equal_amd64.s; the rest is plain Go, since a call into assembly costs
+// more than the work it would do on a single pixel. The decoder's run fills
+// and premultiplication likewise have versions in decode_amd64.s.
+//
+// Only amd64 has assembly. Other architectures, arm64 among them, and builds
+// with the purego tag use the plain Go versions below, which work on eight
+// bytes at a time.
+
+// pixelWord returns the pixel at the start of pix as a word.
+func pixelWord(pix []byte) uint32 {
+	return binary.LittleEndian.Uint32(pix)
+}
+
+// hashWord is hash for a pixel word. Spreading the channels into 16-bit
+// lanes lets one multiplication form the weighted sum in the top lane.
+func hashWord(w uint32) int {
+	x := uint64(w&0xff) | uint64(w>>8&0xff)<<16 | uint64(w>>16&0xff)<<32 | uint64(w>>24)<<48
+	return int(x * (11 | 7<<16 | 5<<32 | 3<<48) >> 48 & 63)
+}
+
+// diffWord returns the differences c-p of each channel of two pixel words,
+// wrapping around as the decoder's arithmetic does.
+func diffWord(c, p uint32) uint32 {
+	const h = 0x80808080
+	return ((c | h) - (p &^ h)) ^ ((c ^ ^p) & h)
+}
+
+// addWord adds k to each channel of the pixel word w without carrying
+// between channels.
+func addWord(w, k uint32) uint32 {
+	const h = 0x80808080
+	return ((w &^ h) + (k &^ h)) ^ ((w ^ k) & h)
+}
+
+// equalRunGeneric is equalRun in plain Go, comparing two pixels at a time.
+func equalRunGeneric(pix []byte, v, mask uint32) int {
+	v2, m2 := uint64(v)*0x100000001, uint64(mask)*0x100000001
+	n := 0
+	for ; len(pix) >= 8 && binary.LittleEndian.Uint64(pix)&m2 == v2; pix = pix[8:] {
+		n += 2
+	}
+	for ; len(pix) >= 4 && binary.LittleEndian.Uint32(pix)&mask == v; pix = pix[4:] {
+		n++
+	}
+	return n
+}
+
+// wordPixel returns the pixel word w as a color.
+func wordPixel(w uint32) color.NRGBA {
+	return color.NRGBA{uint8(w), uint8(w >> 8), uint8(w >> 16), uint8(w >> 24)}
+}
+
+// pixelWordOf returns the color c as a pixel word.
+func pixelWordOf(c color.NRGBA) uint32 {
+	return uint32(c.R) | uint32(c.G)<<8 | uint32(c.B)<<16 | uint32(c.A)<<24
+}
+
+// fillWordGeneric is fillWord in plain Go. It stores two pixels at a time
+// for the short fills of typical runs, and doubles the filled prefix with
+// copy for long ones.
+func fillWordGeneric(b []byte, w uint32) {
+	w2 := uint64(w) * 0x100000001
+	n := 0
+	for ; n+8 <= len(b) && n < 64; n += 8 {
+		binary.LittleEndian.PutUint64(b[n:], w2)
+	}
+	if n == len(b)-4 {
+		binary.LittleEndian.PutUint32(b[n:], w)
+		return
+	}
+	for ; n < len(b); n *= 2 {
+		copy(b[n:], b[:n])
+	}
+}
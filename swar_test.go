@@ -0,0 +1,101 @@
+package qoi
+
+import (
+	"image/color"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestHashWord(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 100000 {
+		w := r.Uint32()
+		if got, want := hashWord(w), hash(wordPixel(w)); got != want {
+			t.Fatalf("hashWord(%#08x) = %d, want %d", w, got, want)
+		}
+	}
+	if got, want := hashWord(0xffffffff), hash(color.NRGBA{0xff, 0xff, 0xff, 0xff}); got != want {
+		t.Errorf("hashWord(0xffffffff) = %d, want %d", got, want)
+	}
+}
+
+func TestDiffWord(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for range 100000 {
+		c, p := r.Uint32(), r.Uint32()
+		d := diffWord(c, p)
+		for s := 0; s < 32; s += 8 {
+			if got, want := uint8(d>>s), uint8(c>>s)-uint8(p>>s); got != want {
+				t.Fatalf("diffWord(%#08x, %#08x) = %#08x: byte %d is %#02x, want %#02x", c, p, d, s/8, got, want)
+			}
+		}
+		k := r.Uint32()
+		a := addWord(c, k)
+		for s := 0; s < 32; s += 8 {
+			if got, want := uint8(a>>s), uint8(c>>s)+uint8(k>>s); got != want {
+				t.Fatalf("addWord(%#08x, %#08x) = %#08x: byte %d is %#02x, want %#02x", c, k, a, s/8, got, want)
+			}
+		}
+	}
+}
+
+func TestEqualRun(t *testing.T) {
+	const v = 0x11223344
+	for n := range 40 {
+		for stop := 0; stop <= n; stop++ {
+			pix := make([]byte, 4*n+3) // A partial pixel at the end is ignored.
+			for i := range n {
+				pixelPut(pix[4*i:], v)
+			}
+			if stop < n {
+				pix[4*stop+3] ^= 0x80
+			}
+			for _, mask := range []uint32{0xffffffff, 0x00ffffff} {
+				want := stop
+				if mask == 0x00ffffff {
+					want = n
+				}
+				if got := equalRun(pix, v&mask, mask); got != want {
+					t.Errorf("%d pixels differing at %d, mask %#08x: equalRun = %d, want %d", n, stop, mask, got, want)
+				}
+				if got := equalRunGeneric(pix, v&mask, mask); got != want {
+					t.Errorf("%d pixels differing at %d, mask %#08x: equalRunGeneric = %d, want %d", n, stop, mask, got, want)
+				}
+			}
+		}
+	}
+}
+
+// pixelPut stores the pixel word w at the start of pix.
+func pixelPut(pix []byte, w uint32) {
+	pix[0], pix[1], pix[2], pix[3] = uint8(w), uint8(w>>8), uint8(w>>16), uint8(w>>24)
+}
+
+func BenchmarkEqualRun(b *testing.B) {
+	pix := make([]byte, 4*4096)
+	for i := 0; i < len(pix); i += 4 {
+		pixelPut(pix[i:], 0xff204060)
+	}
+	b.SetBytes(int64(len(pix)))
+	for i := 0; i < b.N; i++ {
+		equalRun(pix, 0xff204060, 0xffffffff)
+	}
+}
+
+func TestFillWord(t *testing.T) {
+	const w = 0x11223344
+	for n := range 100 {
+		for _, fill := range []func([]byte, uint32){fillWord, fillWordGeneric} {
+			b := make([]byte, 4*n+4)
+			fill(b[:4*n], w)
+			for i := range n {
+				if got := pixelWord(b[4*i:]); got != w {
+					t.Fatalf("%d pixels: pixel %d is %#08x, want %#08x", n, i, got, uint32(w))
+				}
+			}
+			if got := pixelWord(b[4*n:]); got != 0 {
+				t.Fatalf("%d pixels: wrote past the end", n)
+			}
+		}
+	}
+}
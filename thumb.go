@@ -0,0 +1,72 @@
+package qoi
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// DecodeThumbnail reads a QOI image from r and returns it scaled down by the
+// smallest integer factor that makes it fit within maxW×maxH, averaging each
+// block of pixels as it is decoded. Only the thumbnail and a row of
+// accumulators are allocated, never the full-size image. Images that already
+// fit are returned at full size.
+func DecodeThumbnail(r io.Reader, maxW, maxH int) (*image.NRGBA, error) {
+	return Limits{}.DecodeThumbnail(r, maxW, maxH)
+}
+
+// DecodeThumbnail is like the package-level DecodeThumbnail. The limits
+// apply to the full-size image.
+func (l Limits) DecodeThumbnail(r io.Reader, maxW, maxH int) (*image.NRGBA, error) {
+	if maxW <= 0 || maxH <= 0 {
+		return nil, errors.New("qoi: invalid thumbnail size")
+	}
+	d := newDecoder(r)
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := l.check(d.hdr); err != nil {
+		return nil, err
+	}
+	w, h := d.hdr.Width, d.hdr.Height
+	f := max((w+maxW-1)/maxW, (h+maxH-1)/maxH, 1)
+	tw, th := (w+f-1)/f, (h+f-1)/f
+	img := image.NewNRGBA(image.Rect(0, 0, tw, th))
+
+	// Colors are averaged weighted by alpha, so that transparent pixels
+	// do not darken their neighbors.
+	row := make([]byte, 4*w)
+	sums := make([][4]uint64, tw) // alpha-weighted R, G, B, and alpha
+	counts := make([]uint64, tw)
+	for y := range h {
+		if err := d.decodeSpan(row); err != nil {
+			return nil, err
+		}
+		for x := range w {
+			p := row[4*x : 4*x+4 : 4*x+4]
+			a := uint64(p[3])
+			s := &sums[x/f]
+			s[0] += uint64(p[0]) * a
+			s[1] += uint64(p[1]) * a
+			s[2] += uint64(p[2]) * a
+			s[3] += a
+			counts[x/f]++
+		}
+		if (y+1)%f != 0 && y != h-1 {
+			continue
+		}
+		out := img.Pix[(y/f)*img.Stride:]
+		for i, s := range sums {
+			p := out[4*i : 4*i+4 : 4*i+4]
+			if s[3] > 0 {
+				p[0] = uint8((s[0] + s[3]/2) / s[3])
+				p[1] = uint8((s[1] + s[3]/2) / s[3])
+				p[2] = uint8((s[2] + s[3]/2) / s[3])
+				p[3] = uint8((s[3] + counts[i]/2) / counts[i])
+			}
+		}
+		clear(sums)
+		clear(counts)
+	}
+	return img, d.readEnd()
+}
@@ -0,0 +1,51 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeThumbnail(t *testing.T) {
+	m := testImage(100, 41)
+	data := encodeBytes(t, m, nil)
+
+	for _, tt := range []struct {
+		maxW, maxH int
+		want       image.Point
+	}{
+		{100, 41, image.Pt(100, 41)},
+		{1000, 1000, image.Pt(100, 41)},
+		{50, 50, image.Pt(50, 21)},
+		{30, 41, image.Pt(25, 11)},
+		{1, 1, image.Pt(1, 1)},
+	} {
+		got, err := DecodeThumbnail(bytes.NewReader(data), tt.maxW, tt.maxH)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := got.Rect.Size(); s != tt.want {
+			t.Errorf("DecodeThumbnail(%d, %d) is %v, want %v", tt.maxW, tt.maxH, s, tt.want)
+		}
+	}
+
+	full, err := DecodeThumbnail(bytes.NewReader(data), 100, 41)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, full, m)
+
+	// A 2×2 block of opaque red, opaque blue, and two transparent pixels
+	// averages to half-transparent purple.
+	m = image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	m.SetNRGBA(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	m.SetNRGBA(1, 1, color.NRGBA{0, 0, 0xff, 0xff})
+	got, err := DecodeThumbnail(bytes.NewReader(encodeBytes(t, m, nil)), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, want := got.NRGBAAt(0, 0), (color.NRGBA{0x80, 0, 0x80, 0x80}); c != want {
+		t.Errorf("got %v, want %v", c, want)
+	}
+}
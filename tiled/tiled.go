@@ -0,0 +1,241 @@
+// Package tiled implements a container that stores a large image as a grid
+// of independently encoded QOI tiles, so that any tile can be decoded
+// without reading the others.
+//
+// A container starts with the 4-byte magic "qoit" followed by the image
+// width, image height, tile width and tile height as 4-byte big-endian
+// integers. An index of 8-byte big-endian offsets follows, one per tile in
+// row-major order plus one marking the end of the last tile. Each tile is a
+// complete QOI stream starting at its offset, which is counted from the
+// start of the container. Tiles in the last column and row are cropped to
+// the image bounds.
+package tiled
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+
+	"github.com/clfs/qoi"
+)
+
+const (
+	magic     = "qoit"
+	headerLen = 20
+
+	// maxTiles bounds the index, which is read into memory.
+	maxTiles = 1 << 24
+)
+
+// DefaultTileSize is the tile width and height used when an Encoder does
+// not set them.
+const DefaultTileSize = 256
+
+// Encoder configures encoding tiled images.
+type Encoder struct {
+	// Encoder configures how each tile is encoded.
+	qoi.Encoder
+
+	// TileWidth and TileHeight are the size of the tiles. Zero means
+	// DefaultTileSize.
+	TileWidth, TileHeight int
+}
+
+// A FormatError reports that the input is not a valid tiled image.
+type FormatError string
+
+func (e FormatError) Error() string { return "tiled: invalid format: " + string(e) }
+
+// Encode writes m to w as a tiled image with the default tile size.
+func Encode(w io.Writer, m image.Image) error {
+	var e Encoder
+	return e.Encode(w, m)
+}
+
+// Encode writes m to w as a tiled image. The tiles are encoded in memory
+// before anything is written, since the index precedes them.
+func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
+	tw, th := enc.TileWidth, enc.TileHeight
+	if tw == 0 {
+		tw = DefaultTileSize
+	}
+	if th == 0 {
+		th = DefaultTileSize
+	}
+	b := m.Bounds()
+	if tw < 0 || th < 0 || uint64(tw) >= 1<<32 || uint64(th) >= 1<<32 {
+		return errors.New("tiled: invalid tile size")
+	}
+	if b.Empty() || uint64(b.Dx()) >= 1<<32 || uint64(b.Dy()) >= 1<<32 {
+		return errors.New("tiled: invalid image size")
+	}
+	cols, rows, ok := grid(b.Dx(), b.Dy(), tw, th)
+	if !ok {
+		return errors.New("tiled: too many tiles")
+	}
+
+	var tiles bytes.Buffer
+	offsets := make([]uint64, 0, cols*rows+1)
+	base := uint64(headerLen + 8*(cols*rows+1))
+	for ty := range rows {
+		for tx := range cols {
+			offsets = append(offsets, base+uint64(tiles.Len()))
+			r := image.Rect(tx*tw, ty*th, (tx+1)*tw, (ty+1)*th).Add(b.Min)
+			if err := enc.Encoder.EncodeRegion(&tiles, m, r); err != nil {
+				return err
+			}
+		}
+	}
+	offsets = append(offsets, base+uint64(tiles.Len()))
+
+	hdr := make([]byte, 0, base)
+	hdr = append(hdr, magic...)
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(b.Dx()))
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(b.Dy()))
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(tw))
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(th))
+	for _, off := range offsets {
+		hdr = binary.BigEndian.AppendUint64(hdr, off)
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := tiles.WriteTo(w)
+	return err
+}
+
+// grid returns the number of tile columns and rows of a w×h image cut into
+// tw×th tiles, and whether there are at most maxTiles of them. The sizes are
+// positive and below 1<<32, so the arithmetic cannot overflow a uint64.
+func grid(w, h, tw, th int) (cols, rows int, ok bool) {
+	c := (uint64(w) + uint64(tw) - 1) / uint64(tw)
+	r := (uint64(h) + uint64(th) - 1) / uint64(th)
+	if c*r > maxTiles {
+		return 0, 0, false
+	}
+	return int(c), int(r), true
+}
+
+// A Reader gives random access to the tiles of a tiled image.
+type Reader struct {
+	r       io.ReaderAt
+	width   int
+	height  int
+	tileW   int
+	tileH   int
+	cols    int
+	offsets []uint64
+}
+
+// NewReader reads the header and index of the tiled image in r.
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	var hdr [headerLen]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, noEOF(err)
+	}
+	if string(hdr[:4]) != magic {
+		return nil, FormatError("not a tiled image")
+	}
+	t := &Reader{
+		r:      r,
+		width:  int(binary.BigEndian.Uint32(hdr[4:])),
+		height: int(binary.BigEndian.Uint32(hdr[8:])),
+		tileW:  int(binary.BigEndian.Uint32(hdr[12:])),
+		tileH:  int(binary.BigEndian.Uint32(hdr[16:])),
+	}
+	if t.width == 0 || t.height == 0 || t.tileW == 0 || t.tileH == 0 {
+		return nil, FormatError("invalid size")
+	}
+	cols, rows, ok := grid(t.width, t.height, t.tileW, t.tileH)
+	if !ok {
+		return nil, FormatError("too many tiles")
+	}
+	t.cols = cols
+
+	index := make([]byte, 8*(cols*rows+1))
+	if _, err := r.ReadAt(index, headerLen); err != nil {
+		return nil, noEOF(err)
+	}
+	t.offsets = make([]uint64, cols*rows+1)
+	prev := uint64(headerLen + len(index))
+	for i := range t.offsets {
+		off := binary.BigEndian.Uint64(index[8*i:])
+		if off < prev {
+			return nil, FormatError("invalid tile offset")
+		}
+		t.offsets[i], prev = off, off
+	}
+	return t, nil
+}
+
+// Bounds returns the bounds of the whole image.
+func (t *Reader) Bounds() image.Rectangle {
+	return image.Rect(0, 0, t.width, t.height)
+}
+
+// TileSize returns the width and height of the tiles.
+func (t *Reader) TileSize() (w, h int) {
+	return t.tileW, t.tileH
+}
+
+// Tiles returns the number of tile columns and rows.
+func (t *Reader) Tiles() (cols, rows int) {
+	return t.cols, (len(t.offsets) - 1) / t.cols
+}
+
+// TileBounds returns the part of the image covered by the tile in column x
+// and row y.
+func (t *Reader) TileBounds(x, y int) image.Rectangle {
+	r := image.Rect(x*t.tileW, y*t.tileH, (x+1)*t.tileW, (y+1)*t.tileH)
+	return r.Intersect(t.Bounds())
+}
+
+// DecodeTile decodes the tile in column x and row y. The result's bounds are
+// TileBounds(x, y), so tiles can be drawn into place with draw.Draw.
+func (t *Reader) DecodeTile(x, y int) (*image.NRGBA, error) {
+	cols, rows := t.Tiles()
+	if x < 0 || y < 0 || x >= cols || y >= rows {
+		return nil, errors.New("tiled: tile out of range")
+	}
+	i := y*cols + x
+	start, end := t.offsets[i], t.offsets[i+1]
+	m, err := qoi.Decode(io.NewSectionReader(t.r, int64(start), int64(end-start)))
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	tile := m.(*image.NRGBA)
+	want := t.TileBounds(x, y)
+	if tile.Rect.Size() != want.Size() {
+		return nil, FormatError("tile has the wrong size")
+	}
+	tile.Rect = want
+	return tile, nil
+}
+
+// Decode decodes the whole image.
+func (t *Reader) Decode() (*image.NRGBA, error) {
+	img := image.NewNRGBA(t.Bounds())
+	cols, rows := t.Tiles()
+	for y := range rows {
+		for x := range cols {
+			tile, err := t.DecodeTile(x, y)
+			if err != nil {
+				return nil, err
+			}
+			r := tile.Rect
+			for row := r.Min.Y; row < r.Max.Y; row++ {
+				copy(img.Pix[img.PixOffset(r.Min.X, row):][:4*r.Dx()], tile.Pix[tile.PixOffset(r.Min.X, row):])
+			}
+		}
+	}
+	return img, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package tiled
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"testing"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), uint8(0xff - x)})
+		}
+	}
+	return m
+}
+
+func TestRoundtrip(t *testing.T) {
+	m := testImage(100, 70)
+	var buf bytes.Buffer
+	enc := Encoder{TileWidth: 32, TileHeight: 16}
+	if err := enc.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols, rows := r.Tiles(); cols != 4 || rows != 5 {
+		t.Fatalf("got %dx%d tiles, want 4x5", cols, rows)
+	}
+
+	tile, err := r.DecodeTile(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(96, 64, 100, 70); tile.Rect != want {
+		t.Fatalf("last tile has bounds %v, want %v", tile.Rect, want)
+	}
+	for y := tile.Rect.Min.Y; y < tile.Rect.Max.Y; y++ {
+		for x := tile.Rect.Min.X; x < tile.Rect.Max.X; x++ {
+			if tile.NRGBAAt(x, y) != m.NRGBAAt(x, y) {
+				t.Fatalf("pixel (%d, %d) differs", x, y)
+			}
+		}
+	}
+
+	all, err := r.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all.Pix, m.Pix) {
+		t.Error("decoded image differs")
+	}
+
+	if _, err := r.DecodeTile(4, 0); err == nil {
+		t.Error("out of range tile decoded without error")
+	}
+}
+
+func TestRandomAccess(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&Encoder{TileWidth: 10, TileHeight: 10}).Encode(&buf, testImage(30, 30)); err != nil {
+		t.Fatal(err)
+	}
+	// Damage every tile but the middle one, which must still decode.
+	data := buf.Bytes()
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 9 {
+		if i != 4 {
+			data[r.offsets[i]] = 'X'
+		}
+	}
+	if _, err := r.DecodeTile(1, 1); err != nil {
+		t.Errorf("middle tile: %v", err)
+	}
+	if _, err := r.DecodeTile(0, 0); err == nil {
+		t.Error("damaged tile decoded without error")
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, err := NewReader(bytes.NewReader(data[:headerLen+4])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated index: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	bad := bytes.Clone(data)
+	bad[0] = 'x'
+	var fe FormatError
+	if _, err := NewReader(bytes.NewReader(bad)); !errors.As(err, &fe) {
+		t.Errorf("bad magic: got %v, want a FormatError", err)
+	}
+}
+
+// sizeImage is an empty image with arbitrary bounds.
+type sizeImage image.Rectangle
+
+func (m sizeImage) ColorModel() color.Model { return color.NRGBAModel }
+func (m sizeImage) Bounds() image.Rectangle { return image.Rectangle(m) }
+func (m sizeImage) At(x, y int) color.Color { return color.NRGBA{} }
+
+func TestTooManyTiles(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("dimensions up to 2^32 need a 64-bit int")
+	}
+	// Counted in an int, the tiles of the largest images overflow.
+	const max = 1<<32 - 1
+	for _, tc := range []struct{ w, h, tw, th int }{
+		{max, max, 1, 1},
+		{max, 1 << 24, 1, 1},
+		{1 << 13, 1 << 13, 1, 1},
+	} {
+		m := sizeImage{Max: image.Pt(tc.w, tc.h)}
+		enc := &Encoder{TileWidth: tc.tw, TileHeight: tc.th}
+		if err := enc.Encode(io.Discard, m); err == nil {
+			t.Errorf("%dx%d in %dx%d tiles: Encode succeeded", tc.w, tc.h, tc.tw, tc.th)
+		}
+
+		hdr := []byte(magic)
+		for _, v := range []int{tc.w, tc.h, tc.tw, tc.th} {
+			hdr = binary.BigEndian.AppendUint32(hdr, uint32(v))
+		}
+		var fe FormatError
+		if _, err := NewReader(bytes.NewReader(hdr)); !errors.As(err, &fe) {
+			t.Errorf("%dx%d in %dx%d tiles: NewReader got %v, want a FormatError", tc.w, tc.h, tc.tw, tc.th, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package qoi
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// A TraceEntry records a chunk read by DecodeVerbose.
+type TraceEntry struct {
+	Offset int64       // byte offset of the chunk in the stream
+	Type   ChunkType   // type of the chunk
+	Pixel  int64       // index of the first pixel the chunk produces, in row order
+	Len    int         // number of pixels the chunk produces
+	Color  color.NRGBA // color of those pixels
+}
+
+// String formats e as one line of a trace dump, such as
+// "14: QOI_OP_RGB pixel 0 = #1080f0ff".
+func (e TraceEntry) String() string {
+	b := strconv.AppendInt(nil, e.Offset, 10)
+	b = append(b, ": "...)
+	b = append(b, e.Type.String()...)
+	b = append(b, " pixel "...)
+	b = strconv.AppendInt(b, e.Pixel, 10)
+	if e.Len != 1 {
+		b = append(b, " x"...)
+		b = strconv.AppendInt(b, int64(e.Len), 10)
+	}
+	b = append(b, " = #"...)
+	for _, v := range [4]uint8{e.Color.R, e.Color.G, e.Color.B, e.Color.A} {
+		b = append(b, "0123456789abcdef"[v>>4], "0123456789abcdef"[v&0x0f])
+	}
+	return string(b)
+}
+
+// DecodeVerbose reads a QOI image from r like Decode, and also returns a
+// trace of every chunk it read, for debugging tools and for diagnosing
+// differences between implementations. If decoding fails, the trace ends
+// with the last chunk read successfully, and the image holds the pixels
+// decoded so far, as with DecodeOptions.KeepPartial.
+func DecodeVerbose(r io.Reader) (image.Image, []TraceEntry, error) {
+	var trace []TraceEntry
+	d := newDecoder(r)
+	d.trace = func(e TraceEntry) { trace = append(trace, e) }
+	m, err := d.decode(&DecodeOptions{KeepPartial: true})
+	return m, trace, err
+}
@@ -0,0 +1,65 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeVerbose(t *testing.T) {
+	// A 5×1 image: RGB, RUN of 2, INDEX, DIFF.
+	data := []byte("qoif\x00\x00\x00\x05\x00\x00\x00\x01\x04\x00" +
+		"\xfe\x10\x80\xf0" + "\xc1" + "\x00" + "\x7f" +
+		"\x00\x00\x00\x00\x00\x00\x00\x01")
+	m, trace, err := DecodeVerbose(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"14: QOI_OP_RGB pixel 0 = #1080f0ff",
+		"18: QOI_OP_RUN pixel 1 x2 = #1080f0ff",
+		"19: QOI_OP_INDEX pixel 3 = #00000000",
+		"20: QOI_OP_DIFF pixel 4 = #01010100",
+	}
+	if len(trace) != len(want) {
+		t.Fatalf("got %d entries, want %d: %v", len(trace), len(want), trace)
+	}
+	for i, e := range trace {
+		if e.String() != want[i] {
+			t.Errorf("entry %d: got %q, want %q", i, e, want[i])
+		}
+	}
+	if got := m.At(4, 0); got != (color.NRGBA{1, 1, 1, 0}) {
+		t.Errorf("last pixel: got %v", got)
+	}
+
+	// A failed decode keeps the trace so far.
+	_, trace, err = DecodeVerbose(bytes.NewReader(data[:headerLen+6]))
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated: got %v, want ErrTruncated", err)
+	}
+	if len(trace) != 3 {
+		t.Errorf("truncated: got %d entries, want 3", len(trace))
+	}
+}
+
+func TestDecodeVerboseMatchesDecode(t *testing.T) {
+	m := testImage(31, 17)
+	data := encodeBytes(t, m, nil)
+	got, trace, err := DecodeVerbose(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, m)
+	var pixels int64
+	for _, e := range trace {
+		if e.Pixel != pixels {
+			t.Fatalf("entry at offset %d starts at pixel %d, want %d", e.Offset, e.Pixel, pixels)
+		}
+		pixels += int64(e.Len)
+	}
+	if pixels != 31*17 {
+		t.Errorf("trace covers %d pixels, want %d", pixels, 31*17)
+	}
+}
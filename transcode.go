@@ -0,0 +1,224 @@
+package qoi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Format is an image file format that Transcode reads or writes.
+type Format int
+
+// Formats.
+const (
+	// FormatAuto, as the source format, detects the format from the data.
+	// It is not a valid destination format.
+	FormatAuto Format = iota
+	FormatQOI
+	FormatPNG
+	FormatJPEG
+	FormatGIF
+)
+
+var formatNames = [...]string{"auto", "qoi", "png", "jpeg", "gif"}
+
+func (f Format) String() string {
+	if f >= 0 && int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// Extension returns the usual file name extension of f, such as ".png". It
+// is meaningless for FormatAuto.
+func (f Format) Extension() string {
+	switch f {
+	case FormatQOI:
+		return Extension
+	case FormatJPEG:
+		return ".jpg"
+	}
+	return "." + f.String()
+}
+
+// TranscodeOptions configures Transcode. The zero value uses each codec's
+// defaults.
+type TranscodeOptions struct {
+	// Encoder and Decode configure writing and reading QOI images. If
+	// their BufferPool is nil, Transcode uses a shared pool.
+	Encoder Encoder
+	Decode  DecodeOptions
+
+	// PNG, JPEG and GIF configure writing the other formats. If
+	// PNG.BufferPool is nil, Transcode uses a shared pool.
+	PNG  png.Encoder
+	JPEG *jpeg.Options
+	GIF  *gif.Options
+
+	// KeepEXIF carries EXIF data from JPEG or QOI sources to JPEG or QOI
+	// destinations, in the QOI metadata block described in Metadata.
+	KeepEXIF bool
+}
+
+// Shared buffers for transcoding with options that do not set their own.
+var (
+	transcodeEncoders syncEncoderPool
+	transcodeDecoders syncDecoderPool
+	transcodePNG      pngBufferPool
+)
+
+type pngBufferPool struct{ p sync.Pool }
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.p.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) { p.p.Put(b) }
+
+// Transcode reads an image from src in the format from and writes it to dst
+// in the format to. If opts is nil, the defaults of each codec are used. Only the
+// first frame of an animated GIF is read.
+func Transcode(dst io.Writer, src io.Reader, from, to Format, opts *TranscodeOptions) error {
+	var o TranscodeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Encoder.BufferPool == nil {
+		o.Encoder.BufferPool = &transcodeEncoders
+	}
+	if o.Decode.BufferPool == nil {
+		o.Decode.BufferPool = &transcodeDecoders
+	}
+	if o.PNG.BufferPool == nil {
+		o.PNG.BufferPool = &transcodePNG
+	}
+	if to <= FormatAuto || to > FormatGIF {
+		return &kindError{ErrUnsupported, errors.New("qoi: invalid destination format " + to.String())}
+	}
+
+	m, exif, err := o.decode(src, from)
+	if err != nil {
+		return err
+	}
+
+	switch to {
+	case FormatQOI:
+		if exif != nil {
+			md := Metadata{EXIF: exif}
+			if o.Encoder.Metadata != nil {
+				md = *o.Encoder.Metadata
+				md.EXIF = exif
+			}
+			o.Encoder.Metadata = &md
+		}
+		return o.Encoder.Encode(dst, m)
+	case FormatPNG:
+		return o.PNG.Encode(dst, m)
+	case FormatJPEG:
+		if exif == nil {
+			return jpeg.Encode(dst, m, o.JPEG)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, m, o.JPEG); err != nil {
+			return err
+		}
+		data, err := AddJPEGEXIF(buf.Bytes(), exif)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(data)
+		return err
+	default:
+		return gif.Encode(dst, m, o.GIF)
+	}
+}
+
+// decode reads an image in format f from r, along with its EXIF data if
+// o.KeepEXIF is set.
+func (o *TranscodeOptions) decode(r io.Reader, f Format) (m image.Image, exif []byte, err error) {
+	if f == FormatAuto {
+		var name string
+		r, name, err = sniff(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch name {
+		case "qoi":
+			f = FormatQOI
+		case "png":
+			f = FormatPNG
+		case "jpeg":
+			f = FormatJPEG
+		case "gif":
+			f = FormatGIF
+		default:
+			return nil, nil, image.ErrFormat
+		}
+	}
+
+	switch f {
+	case FormatQOI:
+		if !o.KeepEXIF {
+			m, err = o.Decode.Decode(r)
+			return m, nil, err
+		}
+		m, md, err := o.Decode.DecodeMetadata(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return m, md.EXIF, nil
+	case FormatPNG:
+		m, err = png.Decode(r)
+		return m, nil, err
+	case FormatJPEG:
+		if !o.KeepEXIF {
+			m, err = jpeg.Decode(r)
+			return m, nil, err
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exif, err = JPEGEXIF(data); err != nil {
+			return nil, nil, err
+		}
+		m, err = jpeg.Decode(bytes.NewReader(data))
+		return m, exif, err
+	case FormatGIF:
+		m, err = gif.Decode(r)
+		return m, nil, err
+	}
+	return nil, nil, &kindError{ErrUnsupported, errors.New("qoi: invalid source format " + f.String())}
+}
+
+// sniff returns a reader with the same data as r, and the format that data
+// starts with, or "" if it is not one Transcode reads.
+func sniff(r io.Reader) (io.Reader, string, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	b, err := br.Peek(8)
+	if len(b) < 8 && err != io.EOF && err != nil {
+		return nil, "", err
+	}
+	for _, f := range []struct{ magic, name string }{
+		{magic, "qoi"},
+		{"\x89PNG\r\n\x1a\n", "png"},
+		{"\xff\xd8", "jpeg"},
+		{"GIF8", "gif"},
+	} {
+		if bytes.HasPrefix(b, []byte(f.magic)) {
+			return br, f.name, nil
+		}
+	}
+	return br, "", nil
+}
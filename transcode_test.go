@@ -0,0 +1,99 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	m := testImage(12, 10)
+	var src bytes.Buffer
+	if err := png.Encode(&src, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// PNG to QOI to PNG, with the source format given and detected.
+	for _, from := range []Format{FormatPNG, FormatAuto} {
+		var q bytes.Buffer
+		if err := Transcode(&q, bytes.NewReader(src.Bytes()), from, FormatQOI, nil); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decode(&q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samePixels(t, got, m)
+	}
+
+	var q, p bytes.Buffer
+	if err := Encode(&q, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := Transcode(&p, &q, FormatAuto, FormatPNG, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := png.Decode(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samePixels(t, got, m)
+
+	// Lossy and paletted formats only need to produce a decodable image.
+	for _, to := range []Format{FormatJPEG, FormatGIF} {
+		var out bytes.Buffer
+		if err := Transcode(&out, bytes.NewReader(encodeBytes(t, m, nil)), FormatQOI, to, nil); err != nil {
+			t.Fatal(err)
+		}
+		got, name, err := image.Decode(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != to.String() || got.Bounds() != m.Bounds() {
+			t.Errorf("%v: got a %s image with bounds %v", to, name, got.Bounds())
+		}
+	}
+}
+
+func TestTranscodeEXIF(t *testing.T) {
+	exif := []byte("II\x2a\x00\x08\x00\x00\x00\x00\x00")
+	opts := &TranscodeOptions{
+		KeepEXIF: true,
+		Encoder:  Encoder{Metadata: &Metadata{Text: map[string]string{"k": "v"}}},
+	}
+	data := encodeBytes(t, testImage(8, 8), &Encoder{Metadata: &Metadata{EXIF: exif}})
+
+	var j, q bytes.Buffer
+	if err := Transcode(&j, bytes.NewReader(data), FormatQOI, FormatJPEG, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := JPEGEXIF(j.Bytes()); err != nil || !bytes.Equal(got, exif) {
+		t.Errorf("JPEG: got EXIF data %q, %v, want %q", got, err, exif)
+	}
+	if err := Transcode(&q, &j, FormatJPEG, FormatQOI, opts); err != nil {
+		t.Fatal(err)
+	}
+	_, md, err := DecodeMetadata(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(md.EXIF, exif) || md.Text["k"] != "v" {
+		t.Errorf("QOI: got EXIF data %q and text %q", md.EXIF, md.Text)
+	}
+}
+
+func TestTranscodeInvalid(t *testing.T) {
+	data := encodeBytes(t, testImage(2, 2), nil)
+	for _, tc := range []struct{ from, to Format }{{FormatQOI, FormatAuto}, {FormatQOI, 99}, {99, FormatPNG}} {
+		err := Transcode(new(bytes.Buffer), bytes.NewReader(data), tc.from, tc.to, nil)
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%v to %v: got %v, want ErrUnsupported", tc.from, tc.to, err)
+		}
+	}
+	err := Transcode(new(bytes.Buffer), bytes.NewReader([]byte("not an image")), FormatAuto, FormatQOI, nil)
+	if !errors.Is(err, image.ErrFormat) {
+		t.Errorf("unknown format: got %v, want image.ErrFormat", err)
+	}
+}
@@ -0,0 +1,47 @@
+package qoi
+
+import "io"
+
+// Validate reads a QOI stream from r and reports whether it is a valid
+// image: a well-formed header, chunks covering exactly Width×Height pixels,
+// and the end marker. It checks as strictly as DecodeOptions.Strict but
+// never allocates pixel memory.
+func Validate(r io.Reader) error {
+	var o DecodeOptions
+	return o.Validate(r)
+}
+
+// Validate is like the package-level Validate, but also enforces the size
+// limits in o.
+func (o *DecodeOptions) Validate(r io.Reader) error {
+	d := newDecoder(r)
+	d.strict = true
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+	if err := o.checkLimits(d.hdr); err != nil {
+		return err
+	}
+	if err := d.skip(int64(d.hdr.Width) * int64(d.hdr.Height)); err != nil {
+		return err
+	}
+	return d.readEnd()
+}
+
+// skip decodes n pixels without storing them.
+func (d *decoder) skip(n int64) error {
+	for n > 0 {
+		if d.run > 0 {
+			k := min(int64(d.run), n)
+			d.run -= int(k)
+			d.pixel += k
+			n -= k
+			continue
+		}
+		if err := d.advance(); err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
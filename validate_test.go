@@ -0,0 +1,56 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := encodeBytes(t, testImage(40, 40), nil)
+	hdr := "qoif\x00\x00\x00\x02\x00\x00\x00\x01\x04\x00"
+	end := string(endMarker[:])
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+		want error // if set, the error must match it
+	}{
+		{"valid", valid, true, nil},
+		{"truncated", valid[:len(valid)-3], false, ErrTruncated},
+		{"bad magic", append([]byte("QOIF"), valid[4:]...), false, ErrBadMagic},
+		{"run overshoot", []byte(hdr + "\xc2" + end), false, nil},
+		{"bad end marker", []byte(hdr + "\xc1" + "\x00\x00\x00\x00\x00\x00\x00\x00"), false, nil},
+	}
+	for _, tt := range tests {
+		err := Validate(bytes.NewReader(tt.data))
+		switch {
+		case tt.ok:
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		case err == nil:
+			t.Errorf("%s: Validate succeeded", tt.name)
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	opts := &DecodeOptions{MaxPixels: 100}
+	if err := opts.Validate(bytes.NewReader(valid)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("limits: got %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestValidateDoesNotAllocatePixels(t *testing.T) {
+	data := encodeBytes(t, testImage(512, 512), nil)
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := Validate(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 4 {
+		t.Errorf("Validate made %v allocations per run", allocs)
+	}
+}
@@ -0,0 +1,712 @@
+package qoi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Encoder configures encoding QOI images. An Encoder holds no per-image
+// state, so one may be used by multiple goroutines simultaneously as long as
+// its fields are not changed and its BufferPool, if any, is safe for
+// concurrent use.
+type Encoder struct {
+	// Channels is the channel count written to the header. The zero value
+	// means RGBA. When it is RGB, the alpha channel of the source is ignored.
+	Channels Channels
+
+	// AutoChannels makes Encode choose the channel count itself, writing RGB
+	// when every pixel of the image is opaque and RGBA otherwise; Channels
+	// is then ignored. Opaque images are recognized without a scan if they
+	// have an Opaque method, as the image package's types do. It has no
+	// effect on a Writer or EncodeSeq, which write the header before seeing
+	// any pixels.
+	AutoChannels bool
+
+	// Background, if not nil, is the color that translucent pixels are
+	// composited over when Channels is RGB, instead of having their alpha
+	// dropped. A translucent Background is itself composited over black.
+	Background color.Color
+
+	// ColorSpace is the colorspace written to the header.
+	ColorSpace ColorSpace
+
+	// ConvertColorSpace makes an encoder with ColorSpace set to Linear
+	// convert source colors, which image/color treats as sRGB, to linear
+	// light, so the samples match the header. Without it, ColorSpace only
+	// labels the samples as they are.
+	ConvertColorSpace bool
+
+	// Tolerance, if positive, makes encoding lossy: each sample of a
+	// decoded pixel may differ from the source by up to Tolerance, which
+	// lets the encoder pick shorter chunks, such as runs over slight
+	// gradients. The output is still a standard QOI stream.
+	Tolerance uint8
+
+	// Optimize makes lossy encoding look a few pixels ahead when choosing
+	// how to approximate each pixel, since that choice decides what later
+	// pixels can refer to, and pick the choice that takes the fewest bytes
+	// overall. It makes encoding several times slower. Without Tolerance it
+	// has no effect: the decoder's state then depends only on the pixels,
+	// not on the chunks that encode them, so choosing the shortest chunk for
+	// each pixel already gives the smallest stream.
+	Optimize bool
+
+	// AlphaLevels, if between 2 and 255, makes encoding lossy by rounding
+	// alpha to that many evenly spaced levels, always including fully
+	// transparent and fully opaque, so that anti-aliased edges take fewer
+	// chunks. It is independent of Tolerance, and has no effect when
+	// Channels is RGB.
+	AlphaLevels int
+
+	// ZeroTransparent makes Encode store every fully transparent pixel as
+	// transparent black, after AlphaLevels rounds alpha. Sprites and screen
+	// captures often keep stray color under transparent areas; discarding
+	// it, which changes nothing once the image is composited, turns those
+	// areas into runs and index hits. It has no effect when Channels is
+	// RGB.
+	ZeroTransparent bool
+
+	// FlipVertical makes Encode read the source image bottom row first,
+	// for bottom-up sources such as OpenGL readbacks.
+	FlipVertical bool
+
+	// Workers, if greater than 1, is the number of goroutines that convert
+	// source rows to non-premultiplied RGBA while a single goroutine emits
+	// chunks. This speeds up images that must be converted pixel by pixel;
+	// *image.NRGBA and *image.Paletted sources need no conversion and are
+	// unaffected. The image's methods must be safe for concurrent use, as
+	// those of the image package's types are.
+	Workers int
+
+	// RestartInterval, if positive, makes Encode record a restart point
+	// every RestartInterval rows, from which DecodeParallel can decode the
+	// rows that follow independently. This is a non-standard extension:
+	// each restart point costs a literal pixel in the stream, and the list
+	// of restart points is written after the end marker, where other
+	// decoders ignore it. Writers and EncodeSeq do not record restart
+	// points.
+	RestartInterval int
+
+	// Metadata, if not nil, is written in extension blocks after the end
+	// marker.
+	Metadata *Metadata
+
+	// Checksum appends a CRC-32 of the pixels to the metadata, which
+	// DecodeOptions.VerifyChecksum checks, so that corruption is caught
+	// end to end.
+	Checksum bool
+
+	// Stats, if not nil, is called once an image has been encoded with
+	// statistics about its chunks and size, for tuning and inspecting
+	// encodings.
+	Stats func(*EncodeStats)
+
+	// Progress, if not nil, is called after each row is encoded with the
+	// number of rows done so far and the number of encoded bytes produced.
+	// It is not called by a Writer or EncodeSeq.
+	Progress func(rows int, n int64)
+
+	// BufferPool optionally specifies a buffer pool to get temporary
+	// EncoderBuffers when encoding an image.
+	BufferPool EncoderBufferPool
+}
+
+// EncoderBufferPool is an interface for getting and returning temporary
+// instances of the EncoderBuffer struct. This can be used to reuse buffers
+// when encoding multiple images.
+type EncoderBufferPool interface {
+	Get() *EncoderBuffer
+	Put(*EncoderBuffer)
+}
+
+// EncoderBuffer holds the buffers used for encoding QOI images.
+type EncoderBuffer encoder
+
+type encoder struct {
+	enc *Encoder
+	w   io.Writer
+	m   image.Image
+
+	channels Channels
+
+	// row holds the current source row converted to NRGBA bytes, for
+	// images whose pixels can't be read in place.
+	row []byte
+
+	// Per-pixel adjustments applied before encoding: flatten composites
+	// over bg, table, if not nil, converts the color channels, alpha, if
+	// not nil, quantizes alpha, and zero clears the color of transparent
+	// pixels. conv holds the adjusted pixels.
+	flatten bool
+	bg      color.NRGBA
+	table   *[256]uint8
+	alpha   *[256]uint8
+	zero    bool
+	conv    []byte
+
+	// cands holds the colors Encoder.Optimize tries for a pixel.
+	cands []color.NRGBA
+
+	// pal and palHash hold the NRGBA value and hash of each palette entry
+	// of an *image.Paletted source, so its pixels are never converted.
+	pal     [256]color.NRGBA
+	palHash [256]uint8
+
+	index [64]color.NRGBA
+	prev  color.NRGBA
+	run   int
+
+	// buf accumulates encoded bytes until there are enough to be worth a
+	// Write call.
+	buf []byte
+	err error
+
+	// out counts the bytes flushed to w, and base is the length of the
+	// caller's slice when appending, for reporting progress.
+	out, base int64
+
+	// literal forces the next pixel to be written as an RGBA chunk, and
+	// restarts holds the offsets of restart points.
+	literal  bool
+	restarts []int64
+
+	// checksum enables computing crc, the checksum of the pixels encoded
+	// so far; sumBuf is scratch space for it, and dec holds the decoded
+	// pixels of a row encoded lossily.
+	checksum bool
+	crc      uint32
+	sumBuf   []byte
+	dec      []byte
+
+	// stats, if not nil, collects the statistics for Encoder.Stats.
+	stats *EncodeStats
+}
+
+// flushSize is the amount of encoded data the encoder buffers before writing.
+const flushSize = 32 << 10
+
+// Encode writes the Image m to w in QOI format. Any Image may be encoded,
+// but images that are not image.NRGBA might be encoded lossily.
+func Encode(w io.Writer, m image.Image) error {
+	var e Encoder
+	return e.Encode(w, m)
+}
+
+// Encode writes the Image m to w in QOI format.
+func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
+	_, err := enc.encode(context.Background(), w, nil, m)
+	return err
+}
+
+// AppendEncode appends the QOI encoding of m to dst and returns the extended
+// buffer. If opts is nil, the defaults of Encode are used. On error, dst is
+// returned unextended.
+func AppendEncode(dst []byte, m image.Image, opts *Encoder) ([]byte, error) {
+	if opts == nil {
+		opts = &Encoder{}
+	}
+	b, err := opts.encode(context.Background(), nil, dst, m)
+	if err != nil {
+		return dst, err
+	}
+	return b, nil
+}
+
+// EncodeContext is like Encode, but checks ctx before each scanline. If ctx
+// is done before the image is complete, EncodeContext returns ctx.Err() and
+// leaves w holding a truncated stream without an end marker, which decoders
+// reject.
+func EncodeContext(ctx context.Context, w io.Writer, m image.Image, opts Encoder) error {
+	_, err := opts.encode(ctx, w, nil, m)
+	return err
+}
+
+// MaxEncodedLen returns the largest possible size of a width×height image
+// encoded with c channels and nothing after the end marker, or -1 if the
+// dimensions are invalid or the size overflows an int. A zero c means RGBA,
+// as in Encoder. Encoder.MaxEncodedLen also counts what the Metadata,
+// Checksum and RestartInterval options add.
+func MaxEncodedLen(width, height int, c Channels) int {
+	enc := Encoder{Channels: c}
+	return enc.MaxEncodedLen(width, height)
+}
+
+// MaxEncodedLen returns the largest possible size of a width×height image
+// encoded by enc, including its extension blocks and restart points, or -1
+// if the dimensions are invalid or the size overflows an int.
+func (enc *Encoder) MaxEncodedLen(width, height int) int {
+	if width <= 0 || height <= 0 || uint64(width) >= 1<<32 || uint64(height) >= 1<<32 {
+		return -1
+	}
+	// Every pixel is at worst an RGB chunk, or an RGBA chunk unless alpha
+	// is ignored.
+	per := uint64(maxChunkLen)
+	if enc.Channels == RGB && !enc.AutoChannels {
+		per = 4
+	}
+	px := uint64(width) * uint64(height)
+	if px > math.MaxInt/per {
+		return -1
+	}
+	n := px*per + headerLen + endLen
+	if k := enc.RestartInterval; k > 0 {
+		// Each restart point forces an RGBA chunk and takes an offset in
+		// the trailer.
+		r := uint64(height-1) / uint64(k)
+		n += r*(maxChunkLen-per) + 8*r + 12
+	}
+	if enc.Metadata != nil {
+		n += enc.Metadata.encodedLen()
+	}
+	if enc.Checksum {
+		n += blockHdrLen + 4
+	}
+	if n > math.MaxInt {
+		return -1
+	}
+	return int(n)
+}
+
+// EstimateEncodedSize returns the exact size of the encoding of m, running
+// the encoder without keeping its output, so that a Content-Length can be
+// sent or storage reserved before encoding for real. If opts is nil, the
+// defaults of Encode are used. Its callbacks are called as Encode would
+// call them.
+func EstimateEncodedSize(m image.Image, opts *Encoder) (int64, error) {
+	if opts == nil {
+		opts = &Encoder{}
+	}
+	var cw countWriter
+	if _, err := opts.encode(context.Background(), &cw, nil, m); err != nil {
+		return 0, err
+	}
+	return cw.n, nil
+}
+
+// countWriter counts the bytes written to it and discards them.
+type countWriter struct{ n int64 }
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// encode encodes m to w, or appends it to dst if w is nil.
+func (enc *Encoder) encode(ctx context.Context, w io.Writer, dst []byte, m image.Image) ([]byte, error) {
+	if err := checkSize(int64(m.Bounds().Dx()), int64(m.Bounds().Dy())); err != nil {
+		return nil, err
+	}
+	if err := enc.Metadata.check(); err != nil {
+		return nil, err
+	}
+
+	var e *encoder
+	if enc.BufferPool != nil {
+		buffer := enc.BufferPool.Get()
+		e = (*encoder)(buffer)
+	}
+	if e == nil {
+		e = &encoder{}
+	}
+	if enc.BufferPool != nil {
+		defer enc.BufferPool.Put((*EncoderBuffer)(e))
+	}
+
+	e.enc = enc
+	e.w = w
+	e.m = m
+	// Don't let a pooled buffer keep the caller's values alive.
+	defer func() { e.enc, e.w, e.m = nil, nil, nil }()
+	e.reset()
+	if w == nil {
+		// Encode straight into dst, keeping e's own buffer for later use.
+		own := e.buf
+		e.buf = dst
+		e.base = int64(len(dst))
+		defer func() { e.buf = own }()
+	} else {
+		e.resetBuf()
+	}
+
+	e.writeHeader(m.Bounds().Dx(), m.Bounds().Dy())
+	e.writeChunks(ctx)
+	e.writeEnd()
+	return e.buf, e.err
+}
+
+// checkSize reports whether an image of width×height can be encoded.
+func checkSize(w, h int64) error {
+	// Obviously, negative widths and heights are invalid. Furthermore, the
+	// spec stores them as 4-byte unsigned integers.
+	if w <= 0 || h <= 0 || w >= 1<<32 || h >= 1<<32 {
+		return errors.New("qoi: invalid image size: " + strconv.FormatInt(w, 10) + "x" + strconv.FormatInt(h, 10) +
+			" (width and height must be between 1 and " + strconv.FormatInt(1<<32-1, 10) + ")")
+	}
+	return nil
+}
+
+func (e *encoder) reset() {
+	e.channels = e.enc.Channels
+	if e.enc.AutoChannels && e.m != nil {
+		e.channels = RGBA
+		if e.opaque() {
+			e.channels = RGB
+		}
+	}
+	if e.channels == 0 {
+		e.channels = RGBA
+	}
+	e.flatten = e.channels == RGB && e.enc.Background != nil
+	if e.flatten {
+		r, g, b, _ := e.enc.Background.RGBA()
+		e.bg = color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+	}
+	e.table = nil
+	if e.enc.ConvertColorSpace {
+		e.table = colorTable(SRGB, e.enc.ColorSpace)
+	}
+	e.alpha = nil
+	if n := e.enc.AlphaLevels; n >= 2 && n < 256 && e.channels == RGBA {
+		e.alpha = alphaTable(n)
+	}
+	e.zero = e.enc.ZeroTransparent && e.channels == RGBA
+	e.index = [64]color.NRGBA{}
+	e.prev = startPixel
+	e.run = 0
+	e.err = nil
+	e.out, e.base = 0, 0
+	e.literal = false
+	e.restarts = e.restarts[:0]
+	e.checksum, e.crc = e.enc.Checksum, 0
+	e.stats = nil
+	if e.enc.Stats != nil {
+		e.stats = &EncodeStats{}
+	}
+}
+
+// opaque reports whether every pixel of e.m is fully opaque.
+func (e *encoder) opaque() bool {
+	if m, ok := e.m.(interface{ Opaque() bool }); ok {
+		return m.Opaque()
+	}
+	b := e.m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := e.loadRow(y)
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 0xff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resetBuf empties e.buf, allocating it if needed.
+func (e *encoder) resetBuf() {
+	if cap(e.buf) < flushSize+maxChunkLen {
+		e.buf = make([]byte, 0, flushSize+maxChunkLen)
+	}
+	e.buf = e.buf[:0]
+}
+
+// flush writes any buffered bytes to e.w. It does nothing when appending to
+// a caller's slice.
+func (e *encoder) flush() {
+	if e.w == nil {
+		return
+	}
+	if e.err == nil && len(e.buf) > 0 {
+		_, e.err = e.w.Write(e.buf)
+		e.out += int64(len(e.buf))
+	}
+	e.buf = e.buf[:0]
+}
+
+func (e *encoder) writeHeader(width, height int) {
+	e.buf = append(e.buf, magic...)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(width))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(height))
+	e.buf = append(e.buf, byte(e.channels), byte(e.enc.ColorSpace))
+	if e.stats != nil {
+		e.stats.Pixels = int64(width) * int64(height)
+		e.stats.InputSize = e.stats.Pixels * int64(e.channels)
+	}
+}
+
+func (e *encoder) writeChunks(ctx context.Context) {
+	b := e.m.Bounds()
+	p, _ := e.m.(*image.Paletted)
+	if p != nil && (e.flatten || e.table != nil || e.alpha != nil || e.checksum || e.enc.Tolerance > 0) {
+		p = nil
+	}
+	if p != nil {
+		e.loadPalette(p.Palette)
+	}
+	rowY := func(n int) int {
+		if e.enc.FlipVertical {
+			return b.Max.Y - 1 - n
+		}
+		return b.Min.Y + n
+	}
+	loadRow := e.loadRow
+	if _, ok := e.m.(*image.NRGBA); !ok && p == nil && e.enc.Workers > 1 {
+		pl := startPipeline(e.m, b.Dy(), rowY, e.enc.Workers)
+		defer pl.stop()
+		loadRow = func(int) []byte { return pl.row() }
+	}
+	for n := range b.Dy() {
+		y := rowY(n)
+		if e.err != nil {
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			e.flush()
+			if e.err == nil {
+				e.err = err
+			}
+			return
+		}
+		if k := e.enc.RestartInterval; k > 0 && n > 0 && n%k == 0 {
+			e.restart()
+		}
+		if p != nil {
+			i := p.PixOffset(b.Min.X, y)
+			e.writeIndices(p.Pix[i : i+b.Dx()])
+		} else {
+			e.writePixels(loadRow(y))
+		}
+		if e.enc.Progress != nil {
+			e.enc.Progress(n+1, e.written())
+		}
+	}
+	e.flushRun()
+}
+
+// writeIndices emits the chunks for pix, which holds indices into e.pal.
+func (e *encoder) writeIndices(pix []uint8) {
+	for _, i := range pix {
+		if c := e.pal[i]; c == e.prev && !e.literal {
+			e.extendRun()
+		} else {
+			e.emit(c, int(e.palHash[i]))
+		}
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+}
+
+// writePixels emits the chunks for pix, which holds non-premultiplied RGBA
+// bytes, flushing the output as it grows.
+func (e *encoder) writePixels(pix []byte) {
+	if e.flatten || e.table != nil || e.alpha != nil || e.zero {
+		pix = e.adjust(pix)
+	}
+	if e.enc.Tolerance > 0 {
+		e.writeLossy(pix)
+		return
+	}
+	if e.checksum {
+		e.crc = updateCRC(e.crc, pix, e.channels, &e.sumBuf)
+	}
+	if len(pix) > 4 && bytes.Equal(pix[4:], pix[:len(pix)-4]) {
+		// A row of a single color, as in solid backgrounds and
+		// placeholders, is a run after its first pixel.
+		c := color.NRGBA{pix[0], pix[1], pix[2], pix[3]}
+		if e.channels == RGB {
+			c.A = 0xff
+		}
+		e.writeRepeat(c, len(pix)/4)
+		return
+	}
+	// In RGB images the source alpha is ignored: mask clears it before
+	// comparisons, and opaque sets it in the pixels emitted.
+	mask, opaque := uint32(0xffffffff), uint32(0)
+	if e.channels == RGB {
+		mask, opaque = 0x00ffffff, 0xff000000
+	}
+	prev := pixelWordOf(e.prev)
+	for i := 0; i < len(pix); {
+		w := pixelWord(pix[i:])&mask | opaque
+		if w != prev || e.literal {
+			e.emitDiff(wordPixel(w), hashWord(w), diffWord(w, prev))
+			prev = w
+			i += 4
+		} else {
+			// Scan ahead for the rest of the run instead of taking it a
+			// pixel at a time.
+			n := 1 + equalRun(pix[i+4:], w&mask, mask)
+			e.extendRunBy(n)
+			i += 4 * n
+		}
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+}
+
+// writeRepeat emits the chunks for n copies of c, one run chunk at a time.
+func (e *encoder) writeRepeat(c color.NRGBA, n int) {
+	if c != e.prev || e.literal {
+		e.emit(c, hash(c))
+		n--
+	}
+	e.extendRunBy(n)
+}
+
+// extendRunBy adds n repeats of the previous pixel to the current run,
+// emitting run chunks as they fill up.
+func (e *encoder) extendRunBy(n int) {
+	for n > 0 {
+		k := min(n, maxRun-e.run)
+		e.run += k
+		n -= k
+		if e.run == maxRun {
+			e.flushRun()
+		}
+		if len(e.buf) >= flushSize {
+			e.flush()
+		}
+	}
+}
+
+// adjust returns pix with the encoder's per-pixel adjustments applied,
+// leaving pix itself unchanged.
+func (e *encoder) adjust(pix []byte) []byte {
+	if cap(e.conv) < len(pix) {
+		e.conv = make([]byte, len(pix))
+	}
+	e.conv = e.conv[:len(pix)]
+	src := pix
+	if e.flatten {
+		flattenRow(e.conv, src, e.bg)
+		src = e.conv
+	}
+	if e.table != nil {
+		convertRow(e.conv, src, e.table)
+		src = e.conv
+	}
+	if e.alpha != nil {
+		quantizeAlpha(e.conv, src, e.alpha)
+		src = e.conv
+	}
+	if e.zero {
+		zeroTransparent(e.conv, src)
+	}
+	return e.conv
+}
+
+// flattenRow composites the NRGBA pixels in src over the opaque color bg,
+// storing opaque pixels in dst. dst and src may be the same slice.
+func flattenRow(dst, src []byte, bg color.NRGBA) {
+	for i := 0; i < len(src); i += 4 {
+		s := src[i : i+4 : i+4]
+		d := dst[i : i+4 : i+4]
+		a := uint32(s[3])
+		d[0] = uint8((uint32(s[0])*a + uint32(bg.R)*(0xff-a) + 0x7f) / 0xff)
+		d[1] = uint8((uint32(s[1])*a + uint32(bg.G)*(0xff-a) + 0x7f) / 0xff)
+		d[2] = uint8((uint32(s[2])*a + uint32(bg.B)*(0xff-a) + 0x7f) / 0xff)
+		d[3] = 0xff
+	}
+}
+
+// extendRun adds a repeat of the previous pixel to the current run.
+func (e *encoder) extendRun() {
+	e.run++
+	if e.run == maxRun {
+		e.flushRun()
+	}
+}
+
+// emit emits the chunks for a pixel c that differs from the previous one. i
+// is hash(c).
+func (e *encoder) emit(c color.NRGBA, i int) {
+	e.emitDiff(c, i, diffWord(pixelWordOf(c), pixelWordOf(e.prev)))
+}
+
+// emitDiff is emit for callers that already have d, the difference between
+// the pixel words of c and the previous pixel.
+func (e *encoder) emitDiff(c color.NRGBA, i int, d uint32) {
+	if e.run > 0 {
+		e.flushRun()
+	}
+	n := len(e.buf)
+
+	switch {
+	case e.literal:
+		e.buf = append(e.buf, opRGBA, c.R, c.G, c.B, c.A)
+		e.literal = false
+	case e.index[i] == c:
+		e.buf = append(e.buf, opIndex|byte(i))
+	case c.A != e.prev.A:
+		e.buf = append(e.buf, opRGBA, c.R, c.G, c.B, c.A)
+	default:
+		// Differences wrap around, matching the decoder's modular
+		// arithmetic. Biasing all three channels at once leaves them in
+		// range exactly when no bit above the chunk's field width is set.
+		// luma holds dr-dg, dg and db-dg.
+		g := d >> 8 & 0xff
+		luma := diffWord(d, g|g<<16)&0xff00ff | g<<8
+		diff, luma := addWord(d, 0x020202), addWord(luma, 0x082008)
+		switch {
+		case diff&0xfcfcfc == 0:
+			e.buf = append(e.buf, opDiff|byte(diff)<<4|byte(diff>>8)<<2|byte(diff>>16))
+		case luma&0xf0c0f0 == 0:
+			e.buf = append(e.buf, opLuma|byte(luma>>8), byte(luma)<<4|byte(luma>>16))
+		default:
+			e.buf = append(e.buf, opRGB, c.R, c.G, c.B)
+		}
+	}
+
+	if e.stats != nil {
+		e.stats.count(e.buf[n:])
+	}
+	e.index[i] = c
+	e.prev = c
+}
+
+func (e *encoder) flushRun() {
+	if e.run == 0 {
+		return
+	}
+	e.buf = append(e.buf, opRun|byte(e.run-1))
+	e.run = 0
+	if e.stats != nil {
+		e.stats.count(e.buf[len(e.buf)-1:])
+	}
+}
+
+func (e *encoder) writeEnd() {
+	if e.err != nil {
+		return
+	}
+	e.buf = append(e.buf, endMarker[:]...)
+	if e.enc.Metadata != nil {
+		e.writeMetadata(e.enc.Metadata)
+	}
+	if e.checksum {
+		e.writeChecksum()
+	}
+	if e.enc.RestartInterval > 0 {
+		e.writeRestarts()
+	}
+	if e.stats != nil {
+		e.stats.OutputSize = e.written()
+	}
+	e.flush()
+	if e.stats != nil && e.err == nil {
+		e.enc.Stats(e.stats)
+	}
+}
+
+// written returns the number of encoded bytes produced so far.
+func (e *encoder) written() int64 {
+	return e.out + int64(len(e.buf)) - e.base
+}
@@ -0,0 +1,656 @@
+package qoi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// hookImage wraps an image and calls fn the first time a pixel in row y is
+// read.
+type hookImage struct {
+	image.Image
+	y    int
+	fn   func()
+	done bool
+}
+
+func (m *hookImage) At(x, y int) color.Color {
+	if y == m.y && !m.done {
+		m.done = true
+		m.fn()
+	}
+	return m.Image.At(x, y)
+}
+
+func TestEncodeContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := &hookImage{Image: testImage(32, 32), y: 10, fn: cancel}
+	var buf bytes.Buffer
+	err := EncodeContext(ctx, &buf, m, Encoder{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("EncodeContext: got %v, want %v", err, context.Canceled)
+	}
+	if bytes.HasSuffix(buf.Bytes(), endMarker[:]) {
+		t.Errorf("partial output ends with an end marker")
+	}
+	if _, err := Decode(&buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode of partial output: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestEncodeContextComplete(t *testing.T) {
+	m := testImage(32, 32)
+	var buf bytes.Buffer
+	if err := EncodeContext(context.Background(), &buf, m, Encoder{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), encodeBytes(t, m, nil)) {
+		t.Errorf("EncodeContext output differs from Encode")
+	}
+}
+
+// sizeImage is an empty image with arbitrary bounds.
+type sizeImage image.Rectangle
+
+func (m sizeImage) ColorModel() color.Model { return color.NRGBAModel }
+func (m sizeImage) Bounds() image.Rectangle { return image.Rectangle(m) }
+func (m sizeImage) At(x, y int) color.Color { return color.NRGBA{} }
+
+func TestEncodeDimensions(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("dimensions up to 2^32 need a 64-bit int")
+	}
+	big := int64(1) << 32
+	tests := []struct {
+		w, h int64
+		ok   bool
+	}{
+		{0, 1, false},
+		{1, 0, false},
+		{-1, 1, false},
+		{1, 1, true},
+		{big - 1, 1, true},
+		{1, big - 1, true},
+		{big - 1, big - 1, true},
+		{big, 1, false},
+		{1, big, false},
+	}
+
+	// A cancelled context stops a valid encode before the first scanline,
+	// so huge but valid sizes return quickly.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range tests {
+		// Build the rectangle directly: image.Rect would canonicalize it.
+		m := sizeImage{Max: image.Pt(int(tt.w), int(tt.h))}
+		err := EncodeContext(ctx, io.Discard, m, Encoder{})
+		if tt.ok {
+			if err != context.Canceled {
+				t.Errorf("%dx%d: got %v, want %v", tt.w, tt.h, err, context.Canceled)
+			}
+			continue
+		}
+		if err == nil || err == context.Canceled {
+			t.Errorf("%dx%d: got %v, want an invalid size error", tt.w, tt.h, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "between 1 and 4294967295") {
+			t.Errorf("%dx%d: error %q does not state the valid range", tt.w, tt.h, err)
+		}
+	}
+}
+
+func TestEncodeChunks(t *testing.T) {
+	pixels := []color.NRGBA{
+		{0, 0, 0, 0xff},       // run: same as the start pixel
+		{1, 1, 1, 0xff},       // diff
+		{11, 10, 12, 0xff},    // luma
+		{200, 0, 50, 0xff},    // rgb
+		{1, 1, 1, 0xff},       // index
+		{1, 1, 1, 0},          // rgba
+		{1, 1, 1, 0},          // run
+		{1, 1, 1, 0},          // run
+		{1, 1, 1, 0},          // run
+		{0xff, 0, 0, 0},       // diff, wrapping around
+		{0x1e, 0x1f, 0x20, 0}, // luma, wrapping around
+	}
+	m := image.NewNRGBA(image.Rect(0, 0, len(pixels), 1))
+	for x, c := range pixels {
+		m.SetNRGBA(x, 0, c)
+	}
+
+	want := []byte{
+		'q', 'o', 'i', 'f', 0, 0, 0, byte(len(pixels)), 0, 0, 0, 1, 4, 0,
+		0xc0,
+		0x7f,
+		0xa9, 0x9a,
+		0xfe, 200, 0, 50,
+		0x04,
+		0xff, 1, 1, 1, 0,
+		0xc2,
+		0x45,
+		0xbf, 0x89,
+	}
+	want = append(want, endMarker[:]...)
+	if got := encodeBytes(t, m, nil); !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+}
+
+func TestEncodeLongRun(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 2*maxRun+1, 1))
+	got := encodeBytes(t, m, nil)
+	// Transparent black hashes to the zeroed first index slot, so the
+	// first pixel is an index chunk rather than an RGBA chunk.
+	chunks := got[headerLen : len(got)-endLen]
+	want := []byte{0x00, opRun | (maxRun - 1), opRun | (maxRun - 1)}
+	if !bytes.Equal(chunks, want) {
+		t.Errorf("got  % x\nwant % x", chunks, want)
+	}
+}
+
+func TestEncodeConstantRows(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 150, 6))
+	draw.Draw(m, image.Rect(0, 0, 150, 2), image.NewUniform(startPixel), image.Point{}, draw.Src)
+	draw.Draw(m, image.Rect(0, 2, 150, 4), image.NewUniform(color.NRGBA{9, 8, 7, 0x80}), image.Point{}, draw.Src)
+	m.SetNRGBA(75, 4, color.NRGBA{1, 2, 3, 4})
+
+	for _, enc := range []*Encoder{nil, {Channels: RGB}} {
+		// Writing one pixel at a time never takes the constant-row path.
+		var want bytes.Buffer
+		var opts Encoder
+		if enc != nil {
+			opts = *enc
+		}
+		w, err := NewWriter(&want, 150, 6, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(m.Pix); i += 4 {
+			if _, err := w.Write(m.Pix[i : i+4]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := encodeBytes(t, m, enc); !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("%+v: got  % x\nwant % x", enc, got, want.Bytes())
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, m image.Image) {
+	b.SetBytes(int64(4 * m.Bounds().Dx() * m.Bounds().Dy()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Encode(io.Discard, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeNRGBA(b *testing.B) {
+	benchmarkEncode(b, testImage(512, 512))
+}
+
+func BenchmarkEncodeGradient(b *testing.B) {
+	benchmarkEncode(b, gradient(1920, 1080))
+}
+
+func BenchmarkEncodeRGBA(b *testing.B) {
+	src := testImage(512, 512)
+	m := image.NewRGBA(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodeYCbCr(b *testing.B) {
+	m := image.NewYCbCr(image.Rect(0, 0, 512, 512), image.YCbCrSubsampleRatio420)
+	for i := range m.Y {
+		m.Y[i] = uint8(i)
+	}
+	for i := range m.Cb {
+		m.Cb[i], m.Cr[i] = uint8(i>>3), uint8(i>>5)
+	}
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodePaletted(b *testing.B) {
+	src := testImage(512, 512)
+	m := image.NewPaletted(src.Bounds(), palette.WebSafe)
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodeSolid(b *testing.B) {
+	m := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+	draw.Draw(m, m.Rect, image.NewUniform(color.NRGBA{0x20, 0x40, 0x60, 0xff}), image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodeCMYK(b *testing.B) {
+	src := testImage(512, 512)
+	m := image.NewCMYK(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodeGray(b *testing.B) {
+	src := testImage(512, 512)
+	m := image.NewGray(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+func BenchmarkEncodeGray16(b *testing.B) {
+	src := testImage(512, 512)
+	m := image.NewGray16(src.Bounds())
+	draw.Draw(m, m.Bounds(), src, image.Point{}, draw.Src)
+	benchmarkEncode(b, m)
+}
+
+// countingWriter counts calls to Write.
+type countingWriter struct {
+	io.Writer
+	calls int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	return w.Writer.Write(p)
+}
+
+func TestEncodeBuffersWrites(t *testing.T) {
+	m := testImage(256, 256)
+	var buf bytes.Buffer
+	w := &countingWriter{Writer: &buf}
+	if err := Encode(w, m); err != nil {
+		t.Fatal(err)
+	}
+	if max := buf.Len()/flushSize + 1; w.calls > max {
+		t.Errorf("%d writes for %d bytes, want at most %d", w.calls, buf.Len(), max)
+	}
+	if !bytes.Equal(buf.Bytes(), encodeBytes(t, m, nil)) {
+		t.Errorf("output differs")
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	m := testImage(50, 40)
+	want := encodeBytes(t, m, nil)
+	prefix := []byte("prefix")
+
+	got, err := AppendEncode(prefix, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(got, prefix) || !bytes.Equal(got[len(prefix):], want) {
+		t.Errorf("AppendEncode did not append the encoding to dst")
+	}
+
+	// Appending must leave a pooled encoder's own buffer alone.
+	enc := &Encoder{BufferPool: &singlePool{}}
+	dst := make([]byte, 0, 1<<20)
+	for i := 0; i < 2; i++ {
+		if got, err := AppendEncode(dst, m, enc); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("pooled AppendEncode #%d: err %v, equal %t", i, err, bytes.Equal(got, want))
+		}
+		if got := encodeBytes(t, m, enc); !bytes.Equal(got, want) {
+			t.Fatalf("pooled Encode #%d differs", i)
+		}
+	}
+
+	if _, err := AppendEncode(prefix, sizeImage{}, nil); err == nil {
+		t.Errorf("AppendEncode of an empty image succeeded")
+	}
+}
+
+// singlePool is an EncoderBufferPool holding at most one buffer.
+type singlePool struct{ b *EncoderBuffer }
+
+func (p *singlePool) Get() *EncoderBuffer {
+	b := p.b
+	p.b = nil
+	return b
+}
+
+func (p *singlePool) Put(b *EncoderBuffer) { p.b = b }
+
+func TestMaxEncodedLen(t *testing.T) {
+	// Noise with random alpha is close to the worst case.
+	m := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	seed := uint32(7)
+	for i := range m.Pix {
+		seed = seed*1664525 + 1013904223
+		m.Pix[i] = byte(seed >> 24)
+	}
+	for _, c := range []Channels{0, RGB, RGBA} {
+		n := len(encodeBytes(t, m, &Encoder{Channels: c}))
+		if max := MaxEncodedLen(64, 64, c); n > max {
+			t.Errorf("channels %d: encoded %d bytes, MaxEncodedLen says %d", c, n, max)
+		}
+	}
+
+	if got, want := MaxEncodedLen(2, 3, RGBA), 14+2*3*5+8; got != want {
+		t.Errorf("MaxEncodedLen(2, 3, RGBA) = %d, want %d", got, want)
+	}
+	if got, want := MaxEncodedLen(2, 3, RGB), 14+2*3*4+8; got != want {
+		t.Errorf("MaxEncodedLen(2, 3, RGB) = %d, want %d", got, want)
+	}
+	for _, size := range [][2]int{{0, 1}, {1, -1}, {math.MaxInt, 1}} {
+		if got := MaxEncodedLen(size[0], size[1], RGBA); got != -1 {
+			t.Errorf("MaxEncodedLen(%d, %d, RGBA) = %d, want -1", size[0], size[1], got)
+		}
+	}
+
+	// Restart points turn the first pixel of each stripe into an RGBA
+	// chunk even in RGB images, and they and the extension blocks are
+	// written after the end marker.
+	md := &Metadata{Text: map[string]string{"k": "v"}, ICCProfile: make([]byte, 100), EXIF: make([]byte, 10)}
+	for _, enc := range []*Encoder{
+		{Channels: RGB, RestartInterval: 1},
+		{AutoChannels: true, RestartInterval: 5},
+		{Channels: RGB, Metadata: md, Checksum: true},
+		{RestartInterval: 3, Metadata: md, Checksum: true},
+	} {
+		n := len(encodeBytes(t, m, enc))
+		if max := enc.MaxEncodedLen(64, 64); n > max {
+			t.Errorf("%+v: encoded %d bytes, MaxEncodedLen says %d", enc, n, max)
+		}
+	}
+	enc := &Encoder{Channels: RGB, RestartInterval: 1, Checksum: true}
+	if got, want := enc.MaxEncodedLen(2, 3), 14+2*3*4+2+8+(8*2+12)+(12+4); got != want {
+		t.Errorf("Encoder.MaxEncodedLen(2, 3) = %d, want %d", got, want)
+	}
+}
+
+func TestEncodeBackground(t *testing.T) {
+	m := testImage(40, 12)
+	bg := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	want := image.NewRGBA(m.Rect)
+	draw.Draw(want, want.Rect, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(want, want.Rect, m, image.Point{}, draw.Over)
+
+	data := encodeBytes(t, m, &Encoder{Channels: RGB, Background: bg})
+	got, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range got.(*image.NRGBA).Pix {
+		if d := int(b) - int(want.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("byte %d: got %#02x, want %#02x", i, b, want.Pix[i])
+		}
+	}
+
+	// Background only applies to RGB output.
+	if !bytes.Equal(encodeBytes(t, m, &Encoder{Background: bg}), encodeBytes(t, m, nil)) {
+		t.Error("Background changed RGBA output")
+	}
+}
+
+func TestEncodeAutoChannels(t *testing.T) {
+	opaque := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(opaque, opaque.Rect, image.NewUniform(color.NRGBA{1, 2, 3, 0xff}), image.Point{}, draw.Src)
+	translucent := testImage(40, 8)
+
+	for _, tt := range []struct {
+		m    image.Image
+		want Channels
+	}{
+		{opaque, RGB},
+		{generic{opaque}, RGB},
+		{translucent, RGBA},
+		{generic{translucent}, RGBA},
+	} {
+		data := encodeBytes(t, tt.m, &Encoder{Channels: RGBA, AutoChannels: true})
+		h, err := DecodeHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Channels != tt.want {
+			t.Errorf("%T: channels = %d, want %d", tt.m, h.Channels, tt.want)
+		}
+	}
+}
+
+// flipped returns a copy of m turned upside down.
+func flipped(m *image.NRGBA) *image.NRGBA {
+	f := image.NewNRGBA(m.Rect)
+	h := m.Rect.Dy()
+	for y := range h {
+		copy(f.Pix[y*f.Stride:][:4*m.Rect.Dx()], m.Pix[(h-1-y)*m.Stride:])
+	}
+	return f
+}
+
+func TestEncodeFlipVertical(t *testing.T) {
+	m := testImage(35, 13)
+	want := encodeBytes(t, flipped(m), nil)
+	for _, src := range []image.Image{m, generic{m}} {
+		if got := encodeBytes(t, src, &Encoder{FlipVertical: true}); !bytes.Equal(got, want) {
+			t.Errorf("%T: output differs from encoding the flipped image", src)
+		}
+	}
+}
+
+func TestEncodeProgress(t *testing.T) {
+	m := testImage(300, 200)
+	var rows []int
+	var last int64
+	enc := &Encoder{Progress: func(n int, written int64) {
+		rows = append(rows, n)
+		if written < last {
+			t.Errorf("row %d: byte count went from %d to %d", n, last, written)
+		}
+		last = written
+	}}
+	for _, dst := range [][]byte{nil, make([]byte, 100)} {
+		rows, last = nil, 0
+		out, err := AppendEncode(dst, m, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 200 || rows[0] != 1 || rows[199] != 200 {
+			t.Errorf("progress rows: got %d calls, %v...", len(rows), rows[:min(len(rows), 3)])
+		}
+		// Only the run and end marker are pending after the last row.
+		if n := int64(len(out) - len(dst)); last > n || last < n-endLen-1 {
+			t.Errorf("last progress reported %d bytes of %d", last, n)
+		}
+	}
+
+	var buf bytes.Buffer
+	last = 0
+	if err := enc.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if n := int64(buf.Len()); last > n || last < n-endLen-1 {
+		t.Errorf("last progress reported %d bytes of %d", last, n)
+	}
+}
+
+func TestEncoderConcurrent(t *testing.T) {
+	images := []image.Image{testImage(64, 48), testImage(17, 90), testImage(1, 1)}
+	want := make([][]byte, len(images))
+	for i, m := range images {
+		want[i] = encodeBytes(t, m, nil)
+	}
+
+	enc := &Encoder{BufferPool: &syncEncoderPool{}}
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 20 {
+				i := (g + n) % len(images)
+				var buf bytes.Buffer
+				if err := enc.Encode(&buf, images[i]); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(buf.Bytes(), want[i]) {
+					t.Errorf("goroutine %d: image %d encoded differently", g, i)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEstimateEncodedSize(t *testing.T) {
+	m := testImage(50, 40)
+	for _, enc := range []*Encoder{
+		nil,
+		{Channels: RGB},
+		{RestartInterval: 6, Checksum: true, Metadata: &Metadata{Text: map[string]string{"k": "v"}}},
+	} {
+		n, err := EstimateEncodedSize(m, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := len(encodeBytes(t, m, enc)); n != int64(want) {
+			t.Errorf("got %d, want %d", n, want)
+		}
+	}
+	if _, err := EstimateEncodedSize(image.NewNRGBA(image.Rect(0, 0, 0, 3)), nil); err == nil {
+		t.Error("EstimateEncodedSize succeeded for an empty image")
+	}
+}
+
+// atImage hides the concrete type of an image, so that the encoder reads
+// it through At.
+type atImage struct{ image.Image }
+
+// fuzzImage builds an image of a color model picked by model, with bounds
+// of size w×h starting at (x0, y0), from the bytes in data, repeated as
+// needed. Images are cut from larger ones so that strides exceed widths.
+func fuzzImage(model uint8, w, h int, x0, y0 int, data []byte) image.Image {
+	next := func() func() uint8 {
+		i := 0
+		return func() uint8 {
+			if len(data) == 0 {
+				return 0
+			}
+			b := data[i%len(data)]
+			i++
+			return b
+		}
+	}()
+	r := image.Rect(x0, y0, x0+w, y0+h)
+	outer := image.Rect(x0-1, y0-2, x0+w+3, y0+h+1)
+	fill := func(pix []byte) {
+		for i := range pix {
+			pix[i] = next()
+		}
+	}
+	var m image.Image
+	switch model % 10 {
+	case 0:
+		n := image.NewNRGBA(outer)
+		fill(n.Pix)
+		m = n.SubImage(r)
+	case 1:
+		p := image.NewRGBA(outer)
+		fill(p.Pix)
+		// Keep the color channels premultiplied.
+		for i := 0; i < len(p.Pix); i += 4 {
+			a := p.Pix[i+3]
+			p.Pix[i], p.Pix[i+1], p.Pix[i+2] = min(p.Pix[i], a), min(p.Pix[i+1], a), min(p.Pix[i+2], a)
+		}
+		m = p.SubImage(r)
+	case 2:
+		g := image.NewGray(outer)
+		fill(g.Pix)
+		m = g.SubImage(r)
+	case 3:
+		g := image.NewGray16(outer)
+		fill(g.Pix)
+		m = g.SubImage(r)
+	case 4:
+		ratios := []image.YCbCrSubsampleRatio{
+			image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420,
+			image.YCbCrSubsampleRatio440, image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410,
+		}
+		y := image.NewYCbCr(outer, ratios[int(next())%len(ratios)])
+		fill(y.Y)
+		fill(y.Cb)
+		fill(y.Cr)
+		m = y.SubImage(r)
+	case 5:
+		c := image.NewCMYK(outer)
+		fill(c.Pix)
+		m = c.SubImage(r)
+	case 6:
+		pal := make(color.Palette, 1+int(next())%256)
+		for i := range pal {
+			pal[i] = color.NRGBA{next(), next(), next(), next()}
+		}
+		p := image.NewPaletted(outer, pal)
+		for i := range p.Pix {
+			p.Pix[i] = uint8(int(next()) % len(pal))
+		}
+		m = p.SubImage(r)
+	case 7:
+		n := image.NewNRGBA64(outer)
+		fill(n.Pix)
+		m = n.SubImage(r)
+	case 8:
+		a := image.NewAlpha(outer)
+		fill(a.Pix)
+		m = a.SubImage(r)
+	default:
+		n := image.NewNRGBA(outer)
+		fill(n.Pix)
+		m = atImage{n.SubImage(r)}
+	}
+	return m
+}
+
+func FuzzEncode(f *testing.F) {
+	for model := range uint8(10) {
+		f.Add(model, uint8(5), uint8(3), int8(-2), int8(7), false, testImage(4, 4).Pix)
+	}
+	f.Add(uint8(4), uint8(17), uint8(9), int8(1), int8(1), true, []byte{0x10, 0x80, 0xf0})
+	f.Fuzz(func(t *testing.T, model, w, h uint8, x0, y0 int8, rgb bool, data []byte) {
+		if w == 0 || h == 0 || w > 64 || h > 64 {
+			return
+		}
+		m := fuzzImage(model, int(w), int(h), int(x0), int(y0), data)
+		enc := &Encoder{}
+		want := color.Model(color.NRGBAModel)
+		if rgb {
+			enc.Channels, want = RGB, RGBModel
+		}
+		got, err := Decode(bytes.NewReader(encodeBytes(t, m, enc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := m.Bounds()
+		for y := range b.Dy() {
+			for x := range b.Dx() {
+				c := want.Convert(m.At(b.Min.X+x, b.Min.Y+y))
+				if g := got.At(x, y); g != c {
+					t.Fatalf("%T: pixel (%d, %d): got %v, want %v", m, x, y, g, c)
+				}
+			}
+		}
+	})
+}